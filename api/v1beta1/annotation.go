@@ -1,5 +1,38 @@
 package v1beta1
 
+import metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 const (
 	ArgoExternalLink = "link.argocd.argoproj.io/external-link"
+	// AdoptAnnotation binds a resource to an existing Spacelift resource, its value is the Spacelift ID.
+	AdoptAnnotation = "app.spacelift.io/adopt"
+	// DryRunAnnotation reports the changes to the resource instead of applying them to Spacelift when set to "true".
+	DryRunAnnotation = "app.spacelift.io/dry-run"
+	// PausedAnnotation stops the reconciliation of the resource when set to "true".
+	// It can also be set on a namespace to pause every resource of the namespace.
+	PausedAnnotation = "app.spacelift.io/paused"
+	// RetryDestroyAnnotation triggers the destroy task of a stack being deleted again when set to "true",
+	// once the previous one did not succeed. It is removed when the new task is triggered.
+	RetryDestroyAnnotation = "app.spacelift.io/retry-destroy"
 )
+
+// AdoptId returns the ID of the existing Spacelift resource the object should be bound to,
+// or an empty string when the resource is managed from scratch by the operator.
+func AdoptId(obj metav1.Object) string {
+	return obj.GetAnnotations()[AdoptAnnotation]
+}
+
+// IsDryRun returns true if the changes to the resource must only be reported, see DryRunAnnotation.
+func IsDryRun(obj metav1.Object) bool {
+	return obj.GetAnnotations()[DryRunAnnotation] == "true"
+}
+
+// IsPaused returns true if the reconciliation of the resource or namespace is paused, see PausedAnnotation.
+func IsPaused(obj metav1.Object) bool {
+	return obj.GetAnnotations()[PausedAnnotation] == "true"
+}
+
+// IsRetryDestroy returns true if a destroy task that did not succeed must be triggered again, see RetryDestroyAnnotation.
+func IsRetryDestroy(obj metav1.Object) bool {
+	return obj.GetAnnotations()[RetryDestroyAnnotation] == "true"
+}
@@ -0,0 +1,156 @@
+package v1beta1
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// ConditionReady is true when the resource is in sync with Spacelift and all its dependencies are ready
+	ConditionReady = "Ready"
+	// ConditionSynced is true when the last create or update in Spacelift succeeded
+	ConditionSynced = "Synced"
+	// ConditionDependenciesReady is true when all the resources referenced by the spec exist and are ready
+	ConditionDependenciesReady = "DependenciesReady"
+	// ConditionError is true when the last reconciliation failed
+	ConditionError = "Error"
+	// ConditionDrifted is true when the resource has been changed in spacelift and no longer matches the spec
+	ConditionDrifted = "Drifted"
+	// ConditionDryRun is true when the resource is reconciled in dry-run mode, its changes are reported in the status
+	// instead of being applied to Spacelift
+	ConditionDryRun = "DryRun"
+	// ConditionPaused is true when the reconciliation is paused by the paused annotation of the resource or of its namespace.
+	// It becomes false once resumed, and is removed when the resource has been fully synced again.
+	ConditionPaused = "Paused"
+)
+
+const (
+	ReasonSynced            = "Synced"
+	ReasonCreateFailed      = "CreateFailed"
+	ReasonUpdateFailed      = "UpdateFailed"
+	ReasonValidationFailed  = "ValidationFailed"
+	ReasonSpaceliftError    = "SpaceliftError"
+	ReasonAdoptionFailed    = "AdoptionFailed"
+	ReasonDependenciesReady = "DependenciesReady"
+	ReasonSpaceNotFound     = "SpaceNotFound"
+	ReasonSpaceNotReady     = "SpaceNotReady"
+	ReasonStackNotFound     = "StackNotFound"
+	ReasonStackNotReady     = "StackNotReady"
+	ReasonContextNotFound   = "ContextNotFound"
+	ReasonContextNotReady   = "ContextNotReady"
+	ReasonSecretNotFound    = "SecretNotFound"
+	ReasonSecretKeyNotFound = "SecretKeyNotFound"
+	ReasonDriftDetected     = "DriftDetected"
+	ReasonInSync            = "InSync"
+	ReasonChangesPending    = "ChangesPending"
+	ReasonNoChanges         = "NoChanges"
+	ReasonObserved          = "Observed"
+	ReasonObserveFailed     = "ObserveFailed"
+	ReasonPaused            = "Paused"
+	ReasonResumed           = "Resumed"
+	ReasonOrphanedChildren  = "OrphanedChildren"
+)
+
+// ConditionedObject is a resource reporting standard conditions in its status.
+// +kubebuilder:object:generate=false
+type ConditionedObject interface {
+	metav1.Object
+	runtime.Object
+	StatusConditions() *[]metav1.Condition
+	SetObservedGeneration(int64)
+}
+
+// MarkSynced reports that the resource is in sync with Spacelift.
+func MarkSynced(obj ConditionedObject) {
+	setConditions(obj, metav1.ConditionTrue, ReasonSynced, "",
+		ConditionReady, ConditionSynced)
+	setConditions(obj, metav1.ConditionTrue, ReasonDependenciesReady, "",
+		ConditionDependenciesReady)
+	setConditions(obj, metav1.ConditionFalse, ReasonSynced, "",
+		ConditionError)
+	// Changes planned in dry-run mode have been applied
+	meta.RemoveStatusCondition(obj.StatusConditions(), ConditionDryRun)
+	if dryRunObj, ok := obj.(DryRunObject); ok {
+		*dryRunObj.StatusPlannedChanges() = nil
+	}
+	// Changes made while the reconciliation was paused have been applied
+	meta.RemoveStatusCondition(obj.StatusConditions(), ConditionPaused)
+	// The resource is managed again, it is no longer only observed
+	if observedObj, ok := obj.(ObservedObject); ok {
+		observedObj.SetObserved(nil)
+	}
+}
+
+// MarkObserved reports that the resource mirrors the Spacelift resource set in its status, without changing it.
+func MarkObserved(obj ConditionedObject) {
+	setConditions(obj, metav1.ConditionTrue, ReasonObserved, "",
+		ConditionReady, ConditionSynced)
+	setConditions(obj, metav1.ConditionFalse, ReasonObserved, "",
+		ConditionError)
+	meta.RemoveStatusCondition(obj.StatusConditions(), ConditionPaused)
+}
+
+// MarkDependenciesNotReady reports that the resource waits for one of the resources it references.
+func MarkDependenciesNotReady(obj ConditionedObject, reason, message string) {
+	setConditions(obj, metav1.ConditionFalse, reason, message,
+		ConditionReady, ConditionDependenciesReady)
+}
+
+// MarkSyncFailed reports that the resource could not be synced with Spacelift.
+func MarkSyncFailed(obj ConditionedObject, reason, message string) {
+	setConditions(obj, metav1.ConditionFalse, reason, message,
+		ConditionReady, ConditionSynced)
+	setConditions(obj, metav1.ConditionTrue, reason, message,
+		ConditionError)
+}
+
+// MarkDrifted reports the fields of the resource that have been changed in spacelift.
+func MarkDrifted(obj ConditionedObject, fields []string) {
+	setConditions(obj, metav1.ConditionTrue, ReasonDriftDetected, "Fields changed in Spacelift: "+strings.Join(fields, ", "),
+		ConditionDrifted)
+}
+
+// MarkInSync reports that the resource in spacelift matches the spec.
+func MarkInSync(obj ConditionedObject) {
+	setConditions(obj, metav1.ConditionFalse, ReasonInSync, "",
+		ConditionDrifted)
+}
+
+// MarkDryRun reports the changes that would be applied to Spacelift if the resource was not in dry-run mode.
+func MarkDryRun(obj ConditionedObject, fields []string) {
+	if len(fields) == 0 {
+		setConditions(obj, metav1.ConditionTrue, ReasonNoChanges, "The resource matches Spacelift",
+			ConditionDryRun)
+		return
+	}
+	setConditions(obj, metav1.ConditionTrue, ReasonChangesPending, "Fields to change in Spacelift: "+strings.Join(fields, ", "),
+		ConditionDryRun)
+}
+
+// MarkPaused reports that the reconciliation of the resource is paused, the message tells which annotation paused it.
+func MarkPaused(obj ConditionedObject, message string) {
+	setConditions(obj, metav1.ConditionTrue, ReasonPaused, message,
+		ConditionPaused)
+}
+
+// MarkResumed reports that the reconciliation of the resource is no longer paused.
+func MarkResumed(obj ConditionedObject) {
+	setConditions(obj, metav1.ConditionFalse, ReasonResumed, "",
+		ConditionPaused)
+}
+
+func setConditions(obj ConditionedObject, status metav1.ConditionStatus, reason, message string, types ...string) {
+	obj.SetObservedGeneration(obj.GetGeneration())
+	for _, t := range types {
+		meta.SetStatusCondition(obj.StatusConditions(), metav1.Condition{
+			Type:               t,
+			Status:             status,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: obj.GetGeneration(),
+		})
+	}
+}
@@ -71,6 +71,7 @@ type Attachment struct {
 
 // ContextSpec defines the desired state of Context
 // +kubebuilder:validation:XValidation:message=only one of spaceName or spaceId should be set,rule=has(self.spaceId) != has(self.spaceName)
+// +kubebuilder:validation:XValidation:rule="!has(self.managementPolicy) || self.managementPolicy != 'ObserveOnly' || !has(self.deletionPolicy) || self.deletionPolicy != 'Delete'",message="deletionPolicy can't be Delete when managementPolicy is ObserveOnly"
 type ContextSpec struct {
 	Name *string `json:"name,omitempty"`
 	// +kubebuilder:validation:MinLength=1
@@ -84,15 +85,59 @@ type ContextSpec struct {
 	Hooks        Hooks         `json:"hooks,omitempty"`
 	Environment  []Environment `json:"environment,omitempty"`
 	MountedFiles []MountedFile `json:"mountedFiles,omitempty"`
+
+	// AttachmentSelector attaches the context to the Stack resources of the namespace matching the labels.
+	// Stacks that no longer match are detached.
+	// +optional
+	AttachmentSelector *metav1.LabelSelector `json:"attachmentSelector,omitempty"`
+
+	// DeletionPolicy defines whether the context is deleted in Spacelift when this resource is deleted.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Orphan
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DriftPolicy defines what happens when the context is changed in Spacelift outside of the operator.
+	// +kubebuilder:validation:Enum=Correct;Report;Ignore
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// ManagementPolicy defines whether the operator creates and updates the context in Spacelift.
+	// ObserveOnly contexts are looked up by the adopt annotation or by the slug of their name, and never changed.
+	// +kubebuilder:validation:Enum=Full;ObserveOnly;CreateOnly
+	// +kubebuilder:default=Full
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
+	// AccountRef selects the Spacelift account of the context.
+	// The spacelift-credentials secret of the namespace is used when it is not set.
+	// +optional
+	AccountRef *AccountReference `json:"accountRef,omitempty"`
 }
 
 // ContextStatus defines the observed state of Context
 type ContextStatus struct {
 	Id string `json:"id"`
+	// ObservedGeneration is the generation of the spec last handled by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// PlannedChanges are the changes to apply to Spacelift, reported when the context is reconciled in dry-run mode
+	// +optional
+	PlannedChanges []FieldChange `json:"plannedChanges,omitempty"`
+	// Observed is the Spacelift context as last read by the operator, reported when the context is only observed
+	// +optional
+	Observed *ObservedState `json:"observed,omitempty"`
+	// SecretsHash is the hash of the versions of the secrets last pushed to Spacelift, their values are never stored
+	// +optional
+	SecretsHash string `json:"secretsHash,omitempty"`
+	// AttachedStackIds are the IDs of the stacks attached through the attachment selector or their spec.contexts
+	// +optional
+	AttachedStackIds []string `json:"attachedStackIds,omitempty"`
+	// Conditions describe the state of the last reconciliation, see the Condition* constants for their types
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 // Context is the Schema for the contexts API
 type Context struct {
@@ -110,12 +155,42 @@ func (c *Context) Name() string {
 	return c.ObjectMeta.Name
 }
 
+// Ready returns true once the context has been created in spacelift.
+func (c *Context) Ready() bool {
+	return c.Status.Id != ""
+}
+
 func (c *Context) SetContext(context *models.Context) {
 	if context.Id != "" {
 		c.Status.Id = context.Id
 	}
 }
 
+// GetAccountRef returns the account of the context, see AccountObject
+func (c *Context) GetAccountRef() *AccountReference {
+	return c.Spec.AccountRef
+}
+
+// StatusConditions returns the conditions of the context status, see ConditionedObject
+func (c *Context) StatusConditions() *[]metav1.Condition {
+	return &c.Status.Conditions
+}
+
+// StatusPlannedChanges returns the changes reported in dry-run mode, see DryRunObject
+func (c *Context) StatusPlannedChanges() *[]FieldChange {
+	return &c.Status.PlannedChanges
+}
+
+// SetObserved reports the Spacelift context read by the operator, see ObservedObject
+func (c *Context) SetObserved(observed *ObservedState) {
+	c.Status.Observed = observed
+}
+
+// SetObservedGeneration sets the generation of the spec last handled by the operator
+func (c *Context) SetObservedGeneration(generation int64) {
+	c.Status.ObservedGeneration = generation
+}
+
 //+kubebuilder:object:root=true
 
 // ContextList contains a list of Context
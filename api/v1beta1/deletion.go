@@ -0,0 +1,14 @@
+package v1beta1
+
+// SpaceliftFinalizer is set on resources that need cleanup in Spacelift before being removed from the cluster.
+const SpaceliftFinalizer = "app.spacelift.io/finalizer"
+
+// DeletionPolicy defines what happens to the Spacelift resource when the custom resource is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes the resource from Spacelift when the custom resource is deleted.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the resource in Spacelift when the custom resource is deleted.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
@@ -0,0 +1,14 @@
+package v1beta1
+
+// DriftPolicy defines what happens when the Spacelift resource no longer matches the spec,
+// for example after being edited in the Spacelift UI.
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect applies the spec again to revert the changes made in Spacelift.
+	DriftPolicyCorrect DriftPolicy = "Correct"
+	// DriftPolicyReport reports the drifted fields in the Drifted condition and leaves the Spacelift resource untouched.
+	DriftPolicyReport DriftPolicy = "Report"
+	// DriftPolicyIgnore never compares the Spacelift resource with the spec.
+	DriftPolicyIgnore DriftPolicy = "Ignore"
+)
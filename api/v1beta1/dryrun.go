@@ -0,0 +1,18 @@
+package v1beta1
+
+// FieldChange is a spec field whose value differs from the one in Spacelift.
+type FieldChange struct {
+	// Field is the name of the spec field, config elements of contexts and stacks are reported as environment.<id> and mountedFiles.<id>
+	Field string `json:"field"`
+	// Current is the value in Spacelift, empty when the resource does not exist yet
+	Current string `json:"current,omitempty"`
+	// Desired is the value in the spec, empty when the field is removed
+	Desired string `json:"desired,omitempty"`
+}
+
+// DryRunObject is a resource reporting in its status the changes it would apply to Spacelift in dry-run mode.
+// +kubebuilder:object:generate=false
+type DryRunObject interface {
+	ConditionedObject
+	StatusPlannedChanges() *[]FieldChange
+}
@@ -1,3 +1,51 @@
 package v1beta1
 
 const EventReasonStackOutputCreated = "StackOutputCreated"
+
+const (
+	EventReasonStackDeleted        = "StackDeleted"
+	EventReasonStackDeletionFailed = "StackDeletionFailed"
+	EventReasonStackOrphaned       = "StackOrphaned"
+	EventReasonStackDestroyStarted = "StackDestroyStarted"
+	EventReasonStackDestroyFailed  = "StackDestroyFailed"
+)
+
+const (
+	EventReasonSpaceDeleted         = "SpaceDeleted"
+	EventReasonSpaceDeletionFailed  = "SpaceDeletionFailed"
+	EventReasonSpaceDeletionWaiting = "SpaceDeletionWaiting"
+	EventReasonSpaceDeletionBlocked = "SpaceDeletionBlocked"
+)
+
+const (
+	EventReasonContextDeleted        = "ContextDeleted"
+	EventReasonContextDeletionFailed = "ContextDeletionFailed"
+)
+
+const (
+	EventReasonPolicyDeleted        = "PolicyDeleted"
+	EventReasonPolicyDeletionFailed = "PolicyDeletionFailed"
+)
+
+const (
+	EventReasonRunCanceled           = "RunCanceled"
+	EventReasonRunDiscarded          = "RunDiscarded"
+	EventReasonRunCancellationFailed = "RunCancellationFailed"
+)
+
+const (
+	EventReasonAdopted        = "Adopted"
+	EventReasonAdoptionFailed = "AdoptionFailed"
+)
+
+const (
+	EventReasonDriftDetected  = "DriftDetected"
+	EventReasonDriftCorrected = "DriftCorrected"
+)
+
+const EventReasonDryRun = "DryRun"
+
+const (
+	EventReasonObserved      = "Observed"
+	EventReasonObserveFailed = "ObserveFailed"
+)
@@ -0,0 +1,36 @@
+package v1beta1
+
+// ManagementPolicy defines which changes the operator is allowed to make to the Spacelift resource.
+type ManagementPolicy string
+
+const (
+	// ManagementPolicyFull creates the resource in Spacelift and keeps it in sync with the spec.
+	ManagementPolicyFull ManagementPolicy = "Full"
+	// ManagementPolicyObserveOnly only reads the existing Spacelift resource and reports it in the status.
+	// The resource is never created nor updated, and the spec is only used to find it.
+	ManagementPolicyObserveOnly ManagementPolicy = "ObserveOnly"
+	// ManagementPolicyCreateOnly creates the resource when it does not exist in Spacelift,
+	// once created it is only observed and changes to the spec are not applied.
+	ManagementPolicyCreateOnly ManagementPolicy = "CreateOnly"
+)
+
+// ObservedState is the Spacelift resource as last read by the operator.
+type ObservedState struct {
+	// Name is the name of the resource in Spacelift
+	Name string `json:"name,omitempty"`
+	// Space is the ID of the space the resource is in
+	Space string `json:"space,omitempty"`
+	// Labels are the labels of the resource in Spacelift
+	Labels []string `json:"labels,omitempty"`
+	// State is the state of the resource in Spacelift, only reported for stacks
+	State string `json:"state,omitempty"`
+	// URL is the link to the resource in the Spacelift UI
+	URL string `json:"url,omitempty"`
+}
+
+// ObservedObject is a resource reporting in its status the Spacelift resource it mirrors.
+// +kubebuilder:object:generate=false
+type ObservedObject interface {
+	ConditionedObject
+	SetObserved(*ObservedState)
+}
@@ -24,6 +24,7 @@ import (
 
 // PolicySpec defines the desired state of Policy
 // +kubebuilder:validation:XValidation:rule="(has(self.spaceName) != has(self.spaceId)) || (!has(self.spaceName) && !has(self.spaceId))",message="only one of spaceName or spaceId can be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.managementPolicy) || self.managementPolicy != 'ObserveOnly' || !has(self.deletionPolicy) || self.deletionPolicy != 'Delete'",message="deletionPolicy can't be Delete when managementPolicy is ObserveOnly"
 type PolicySpec struct {
 	// Name of the policy - should be unique in one account
 	Name *string `json:"name,omitempty"`
@@ -46,15 +47,55 @@ type PolicySpec struct {
 
 	AttachedStacksNames []string `json:"attachedStacksNames,omitempty"`
 	AttachedStacksIds   []string `json:"attachedStacksIds,omitempty"`
+	// StackSelector attaches the policy to the ready stacks of the namespace matching these labels.
+	// Stacks stop being attached once their labels don't match anymore.
+	// +optional
+	StackSelector *metav1.LabelSelector `json:"stackSelector,omitempty"`
+
+	// DeletionPolicy defines whether the policy is deleted in Spacelift when this resource is deleted.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Orphan
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DriftPolicy defines what happens when the policy is changed in Spacelift outside of the operator.
+	// +kubebuilder:validation:Enum=Correct;Report;Ignore
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// ManagementPolicy defines whether the operator creates and updates the policy in Spacelift.
+	// ObserveOnly policys are looked up by the adopt annotation or by the slug of their name, and never changed.
+	// +kubebuilder:validation:Enum=Full;ObserveOnly;CreateOnly
+	// +kubebuilder:default=Full
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
+	// AccountRef selects the Spacelift account of the policy.
+	// The spacelift-credentials secret of the namespace is used when it is not set.
+	// +optional
+	AccountRef *AccountReference `json:"accountRef,omitempty"`
 }
 
 // PolicyStatus defines the observed state of Policy
 type PolicyStatus struct {
 	Id string `json:"id"`
+	// ObservedGeneration is the generation of the spec last handled by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// PlannedChanges are the changes to apply to Spacelift, reported when the policy is reconciled in dry-run mode
+	// +optional
+	PlannedChanges []FieldChange `json:"plannedChanges,omitempty"`
+	// Observed is the Spacelift policy as last read by the operator, reported when the policy is only observed
+	// +optional
+	Observed *ObservedState `json:"observed,omitempty"`
+	// AttachedStacksIds are the IDs of the stacks attached through the spec or the stack selector
+	// +optional
+	AttachedStacksIds []string `json:"attachedStacksIds,omitempty"`
+	// Conditions describe the state of the last reconciliation, see the Condition* constants for their types
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 // Policy is the Schema for the policies API
 type Policy struct {
@@ -76,6 +117,31 @@ func (p *Policy) SetPolicy(policy models.Policy) {
 	p.Status.Id = policy.Id
 }
 
+// GetAccountRef returns the account of the policy, see AccountObject
+func (p *Policy) GetAccountRef() *AccountReference {
+	return p.Spec.AccountRef
+}
+
+// StatusConditions returns the conditions of the policy status, see ConditionedObject
+func (p *Policy) StatusConditions() *[]metav1.Condition {
+	return &p.Status.Conditions
+}
+
+// StatusPlannedChanges returns the changes reported in dry-run mode, see DryRunObject
+func (p *Policy) StatusPlannedChanges() *[]FieldChange {
+	return &p.Status.PlannedChanges
+}
+
+// SetObserved reports the Spacelift policy read by the operator, see ObservedObject
+func (p *Policy) SetObserved(observed *ObservedState) {
+	p.Status.Observed = observed
+}
+
+// SetObservedGeneration sets the generation of the spec last handled by the operator
+func (p *Policy) SetObservedGeneration(generation int64) {
+	p.Status.ObservedGeneration = generation
+}
+
 //+kubebuilder:object:root=true
 
 // PolicyList contains a list of Policy
@@ -23,13 +23,33 @@ import (
 )
 
 // RunSpec defines the desired state of Run
+// +kubebuilder:validation:XValidation:rule="!has(self.managementPolicy) || self.managementPolicy != 'ObserveOnly' || !has(self.onDelete) || self.onDelete != 'Cancel'",message="onDelete can't be Cancel when managementPolicy is ObserveOnly"
 type RunSpec struct {
 	// StackName is the name of the stack for this run, this is mandatory
 	// +kubebuilder:validation:MinLength=1
 	StackName                   string `json:"stackName"`
 	CreateSecretFromStackOutput bool   `json:"createSecretFromStackOutput,omitempty"`
+	// OnDelete defines what happens to the Spacelift run when this resource is deleted.
+	// Cancel cancels queued runs and discards unconfirmed ones, Leave lets the run continue.
+	// +kubebuilder:validation:Enum=Cancel;Leave
+	// +kubebuilder:default=Leave
+	OnDelete RunOnDelete `json:"onDelete,omitempty"`
+	// ManagementPolicy defines whether the operator triggers the run in Spacelift.
+	// ObserveOnly runs are looked up by the adopt annotation, their state is reported but they are never triggered.
+	// CreateOnly behaves like Full, since runs are never updated.
+	// +kubebuilder:validation:Enum=Full;ObserveOnly;CreateOnly
+	// +kubebuilder:default=Full
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
 }
 
+// RunOnDelete defines what happens to the Spacelift run when the Run resource is deleted.
+type RunOnDelete string
+
+const (
+	RunOnDeleteCancel RunOnDelete = "Cancel"
+	RunOnDeleteLeave  RunOnDelete = "Leave"
+)
+
 type RunState string
 
 const (
@@ -59,12 +79,23 @@ type RunStatus struct {
 	// Id is the run ULID on Spacelift
 	Id      string `json:"id,omitempty"`
 	StackId string `json:"stackId,omitempty"`
+	// AccountRef is the account of the stack of the run, recorded so the run can be stopped once the stack is gone
+	// +optional
+	AccountRef *AccountReference `json:"accountRef,omitempty"`
+	// ObservedGeneration is the generation of the spec last handled by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the state of the last reconciliation, see the Condition* constants for their types
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="State",type=string,JSONPath=".status.state"
 //+kubebuilder:printcolumn:name="Id",type=string,JSONPath=".status.id"
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 // Run is the Schema for the runs API
 type Run struct {
@@ -103,6 +134,21 @@ func (r *Run) SetRun(run *models.Run) {
 	r.Status.StackId = run.StackId
 }
 
+// GetAccountRef returns the account of the stack of the run, see AccountObject
+func (r *Run) GetAccountRef() *AccountReference {
+	return r.Status.AccountRef
+}
+
+// StatusConditions returns the conditions of the run status, see ConditionedObject
+func (r *Run) StatusConditions() *[]metav1.Condition {
+	return &r.Status.Conditions
+}
+
+// SetObservedGeneration sets the generation of the spec last handled by the operator
+func (r *Run) SetObservedGeneration(generation int64) {
+	r.Status.ObservedGeneration = generation
+}
+
 //+kubebuilder:object:root=true
 
 // RunList contains a list of Run
@@ -23,6 +23,7 @@ import (
 )
 
 // SpaceSpec defines the desired state of space
+// +kubebuilder:validation:XValidation:rule="!has(self.managementPolicy) || self.managementPolicy != 'ObserveOnly' || !has(self.deletionPolicy) || self.deletionPolicy != 'Delete'",message="deletionPolicy can't be Delete when managementPolicy is ObserveOnly"
 type SpaceSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	ParentSpace     string    `json:"parentSpace"`
@@ -30,10 +31,26 @@ type SpaceSpec struct {
 	Description     string    `json:"description,omitempty"`
 	InheritEntities bool      `json:"inheritEntities,omitempty"`
 	Labels          *[]string `json:"labels,omitempty"`
+
+	// DeletionPolicy defines whether the space is deleted in Spacelift when this resource is deleted.
+	// The space is only deleted once all the stacks, contexts, policies and spaces it contains have been removed.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Orphan
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// ManagementPolicy defines whether the operator creates and updates the space in Spacelift.
+	// ObserveOnly spaces are looked up by the adopt annotation or by the slug of their name, and never changed.
+	// +kubebuilder:validation:Enum=Full;ObserveOnly;CreateOnly
+	// +kubebuilder:default=Full
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
+	// AccountRef selects the Spacelift account of the space.
+	// The spacelift-credentials secret of the namespace is used when it is not set.
+	// +optional
+	AccountRef *AccountReference `json:"accountRef,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 // Space is the Schema for the Spaces API
 type Space struct {
@@ -57,6 +74,19 @@ func (s *Space) Ready() bool {
 
 type SpaceStatus struct {
 	Id string `json:"id,omitempty"`
+	// ObservedGeneration is the generation of the spec last handled by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// PlannedChanges are the changes to apply to Spacelift, reported when the space is reconciled in dry-run mode
+	// +optional
+	PlannedChanges []FieldChange `json:"plannedChanges,omitempty"`
+	// Observed is the Spacelift space as last read by the operator, reported when the space is only observed
+	// +optional
+	Observed *ObservedState `json:"observed,omitempty"`
+	// Conditions describe the state of the last reconciliation, see the Condition* constants for their types
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 func (s *Space) SetSpace(space models.Space) {
@@ -65,6 +95,31 @@ func (s *Space) SetSpace(space models.Space) {
 	}
 }
 
+// GetAccountRef returns the account of the space, see AccountObject
+func (s *Space) GetAccountRef() *AccountReference {
+	return s.Spec.AccountRef
+}
+
+// StatusConditions returns the conditions of the space status, see ConditionedObject
+func (s *Space) StatusConditions() *[]metav1.Condition {
+	return &s.Status.Conditions
+}
+
+// StatusPlannedChanges returns the changes reported in dry-run mode, see DryRunObject
+func (s *Space) StatusPlannedChanges() *[]FieldChange {
+	return &s.Status.PlannedChanges
+}
+
+// SetObserved reports the Spacelift space read by the operator, see ObservedObject
+func (s *Space) SetObserved(observed *ObservedState) {
+	s.Status.Observed = observed
+}
+
+// SetObservedGeneration sets the generation of the spec last handled by the operator
+func (s *Space) SetObservedGeneration(generation int64) {
+	s.Status.ObservedGeneration = generation
+}
+
 //+kubebuilder:object:root=true
 
 // SpaceList contains a list of Space
@@ -0,0 +1,118 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AccountKindSpaceliftAccount references a SpaceliftAccount in the namespace of the resource
+	AccountKindSpaceliftAccount = "SpaceliftAccount"
+	// AccountKindClusterSpaceliftAccount references a ClusterSpaceliftAccount
+	AccountKindClusterSpaceliftAccount = "ClusterSpaceliftAccount"
+)
+
+// AccountReference selects the account holding the Spacelift credentials of a resource
+type AccountReference struct {
+	// Kind of the account, SpaceliftAccount from the namespace of the resource or ClusterSpaceliftAccount
+	// +kubebuilder:validation:Enum=SpaceliftAccount;ClusterSpaceliftAccount
+	// +kubebuilder:default=SpaceliftAccount
+	Kind string `json:"kind,omitempty"`
+	// Name of the account
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// AccountObject is implemented by the resources managed in a Spacelift account
+// +kubebuilder:object:generate=false
+type AccountObject interface {
+	GetNamespace() string
+	// GetAccountRef returns the account of the resource, nil means the spacelift-credentials secret of its namespace
+	GetAccountRef() *AccountReference
+}
+
+// SpaceliftAccountSpec defines the desired state of SpaceliftAccount
+type SpaceliftAccountSpec struct {
+	// SecretName is the name of the secret in the namespace of the account holding the
+	// SPACELIFT_API_KEY_ENDPOINT, SPACELIFT_API_KEY_ID and SPACELIFT_API_KEY_SECRET keys
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=".spec.secretName"
+
+// SpaceliftAccount is the Schema for the spaceliftaccounts API.
+// It holds the credentials used by the resources of its namespace referencing it through spec.accountRef.
+type SpaceliftAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SpaceliftAccountSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// SpaceliftAccountList contains a list of SpaceliftAccount
+type SpaceliftAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SpaceliftAccount `json:"items"`
+}
+
+// SecretReference references a secret in any namespace
+type SecretReference struct {
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+}
+
+// ClusterSpaceliftAccountSpec defines the desired state of ClusterSpaceliftAccount
+type ClusterSpaceliftAccountSpec struct {
+	// SecretRef references the secret holding the
+	// SPACELIFT_API_KEY_ENDPOINT, SPACELIFT_API_KEY_ID and SPACELIFT_API_KEY_SECRET keys
+	SecretRef SecretReference `json:"secretRef"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Secret Namespace",type=string,JSONPath=".spec.secretRef.namespace"
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=".spec.secretRef.name"
+
+// ClusterSpaceliftAccount is the Schema for the clusterspaceliftaccounts API.
+// It holds credentials that can be referenced by resources of any namespace through spec.accountRef.
+type ClusterSpaceliftAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ClusterSpaceliftAccountSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterSpaceliftAccountList contains a list of ClusterSpaceliftAccount
+type ClusterSpaceliftAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterSpaceliftAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SpaceliftAccount{}, &SpaceliftAccountList{}, &ClusterSpaceliftAccount{}, &ClusterSpaceliftAccountList{})
+}
@@ -24,6 +24,10 @@ import (
 
 // StackSpec defines the desired state of Stack
 // +kubebuilder:validation:XValidation:rule="has(self.spaceName) != has(self.spaceId)",message="only one of spaceName or spaceId can be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.managementPolicy) || self.managementPolicy != 'ObserveOnly' || !has(self.deletionPolicy) || self.deletionPolicy != 'Delete'",message="deletionPolicy can't be Delete when managementPolicy is ObserveOnly"
+// +kubebuilder:validation:XValidation:rule="!has(self.cloudIntegrations) || self.cloudIntegrations.filter(i, has(i.gcp)).size() <= 1",message="only one gcp integration can be set"
+// +kubebuilder:validation:XValidation:rule="!has(self.awsIntegration) || !has(self.cloudIntegrations) || !self.cloudIntegrations.exists(i, has(i.aws) && i.aws.id == self.awsIntegration.id)",message="awsIntegration can't also be listed in cloudIntegrations"
+// +kubebuilder:validation:XValidation:rule="!has(self.destroyOnDelete) || !self.destroyOnDelete || !has(self.vendorConfig) || (!has(self.vendorConfig.ansible) && !has(self.vendorConfig.cloudFormation) && !has(self.vendorConfig.kubernetes) && (!has(self.vendorConfig.terraform) || !has(self.vendorConfig.terraform.workflowTool) || self.vendorConfig.terraform.workflowTool != 'CUSTOM'))",message="destroyOnDelete is only supported for Terraform, OpenTofu, Terragrunt and Pulumi stacks"
 type StackSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	CommitSHA *string `json:"commitSHA,omitempty"`
@@ -65,6 +69,85 @@ type StackSpec struct {
 	AWSIntegration         *AWSIntegration `json:"awsIntegration,omitempty"`
 	// In our API managesStateFile is not part of StackInput
 	ManagesStateFile *bool `json:"managesStateFile,omitempty"`
+
+	// DependsOn lists the stacks this stack depends on, dependencies removed from the list are deleted in Spacelift.
+	// +optional
+	DependsOn []StackDependency `json:"dependsOn,omitempty"`
+
+	// Environment lists the environment variables set on the stack, variables removed from the list are deleted in Spacelift.
+	// +optional
+	Environment []Environment `json:"environment,omitempty"`
+	// MountedFiles lists the files mounted on the stack, files removed from the list are deleted in Spacelift.
+	// +optional
+	MountedFiles []MountedFile `json:"mountedFiles,omitempty"`
+	// Contexts lists the contexts attached to the stack, contexts removed from the list are detached in Spacelift.
+	// +optional
+	Contexts []StackContext `json:"contexts,omitempty"`
+	// CloudIntegrations lists the cloud integrations of the stack along with awsIntegration,
+	// integrations removed from the list are detached in Spacelift.
+	// +optional
+	CloudIntegrations []CloudIntegration `json:"cloudIntegrations,omitempty"`
+
+	// DeletionPolicy defines whether the stack is deleted in Spacelift when this resource is deleted.
+	// Stacks protected from deletion are always left in Spacelift.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Orphan
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// DestroyOnDelete triggers a destroy task on the stack and waits for it to finish before deleting the stack.
+	// It only applies when deletionPolicy is Delete, and is only supported for Terraform, OpenTofu, Terragrunt and Pulumi stacks.
+	DestroyOnDelete *bool `json:"destroyOnDelete,omitempty"`
+
+	// DriftPolicy defines what happens when the stack is changed in Spacelift outside of the operator.
+	// +kubebuilder:validation:Enum=Correct;Report;Ignore
+	// +kubebuilder:default=Correct
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// ManagementPolicy defines whether the operator creates and updates the stack in Spacelift.
+	// ObserveOnly stacks are looked up by the adopt annotation or by the slug of their name, and never changed.
+	// +kubebuilder:validation:Enum=Full;ObserveOnly;CreateOnly
+	// +kubebuilder:default=Full
+	ManagementPolicy ManagementPolicy `json:"managementPolicy,omitempty"`
+	// AccountRef selects the Spacelift account of the stack.
+	// The spacelift-credentials secret of the namespace is used when it is not set.
+	// +optional
+	AccountRef *AccountReference `json:"accountRef,omitempty"`
+}
+
+// StackDependency is a stack that must run before this stack, referenced by its Stack resource or its Spacelift ID.
+// +kubebuilder:validation:XValidation:rule="has(self.stackName) != has(self.stackId)",message="only one of stackName or stackId can be set"
+type StackDependency struct {
+	// StackName is the name of a Stack resource of the same namespace, the stack waits for it to be ready.
+	// +optional
+	StackName *string `json:"stackName,omitempty"`
+	// StackId is the ID of a stack in Spacelift.
+	// +optional
+	StackId *string `json:"stackId,omitempty"`
+	// References pass the outputs of the dependency to this stack.
+	// +optional
+	References []StackDependencyReference `json:"references,omitempty"`
+}
+
+// StackDependencyReference passes an output of a dependency to an input of the stack.
+type StackDependencyReference struct {
+	// Output is the ID of the output of the dependency.
+	// +kubebuilder:validation:MinLength=1
+	Output string `json:"output"`
+	// Input is the name of the environment variable the output is set to.
+	// +kubebuilder:validation:MinLength=1
+	Input string `json:"input"`
+}
+
+// StackContext is a context attached to the stack, referenced by its Context resource or its Spacelift ID.
+// +kubebuilder:validation:XValidation:rule="has(self.contextName) != has(self.contextId)",message="only one of contextName or contextId can be set"
+type StackContext struct {
+	// ContextName is the name of a Context resource of the same namespace, the stack waits for it to be ready.
+	// +optional
+	ContextName *string `json:"contextName,omitempty"`
+	// ContextId is the ID of a context in Spacelift.
+	// +optional
+	ContextId *string `json:"contextId,omitempty"`
+	// Priority of the context, contexts with a lower priority take precedence.
+	// +optional
+	Priority *int `json:"priority,omitempty"`
 }
 
 type VendorConfig struct {
@@ -115,6 +198,31 @@ type TerragruntConfig struct {
 // StackStatus defines the observed state of Stack
 type StackStatus struct {
 	Id string `json:"id,omitempty"`
+	// DestroyRunId is the ID of the destroy task triggered before deleting the stack
+	DestroyRunId string `json:"destroyRunId,omitempty"`
+	// DestroyRunState is the state of the destroy task triggered before deleting the stack
+	DestroyRunState RunState `json:"destroyRunState,omitempty"`
+	// DestroyRunGeneration is the generation of the stack when the destroy task was triggered
+	DestroyRunGeneration int64 `json:"destroyRunGeneration,omitempty"`
+	// ObservedGeneration is the generation of the spec last handled by the operator
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// PlannedChanges are the changes to apply to Spacelift, reported when the stack is reconciled in dry-run mode
+	// +optional
+	PlannedChanges []FieldChange `json:"plannedChanges,omitempty"`
+	// Observed is the Spacelift stack as last read by the operator, reported when the stack is only observed
+	// +optional
+	Observed *ObservedState `json:"observed,omitempty"`
+	// SecretsHash is the hash of the versions of the secrets last pushed to Spacelift, their values are never stored
+	// +optional
+	SecretsHash string `json:"secretsHash,omitempty"`
+	// AttachedContextIds are the IDs of the contexts attached through spec.contexts, the ones removed from the spec are detached
+	// +optional
+	AttachedContextIds []string `json:"attachedContextIds,omitempty"`
+	// Conditions describe the state of the last reconciliation, see the Condition* constants for their types
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 type Commit struct {
@@ -128,6 +236,7 @@ type Commit struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 // Stack is the Schema for the stacks API
 type Stack struct {
@@ -149,6 +258,24 @@ func (s *Stack) Ready() bool {
 	return s.Status.Id != ""
 }
 
+// ShouldDestroyOnDelete returns true if the stack resources must be destroyed before deleting the stack
+func (s *Stack) ShouldDestroyOnDelete() bool {
+	return s.Spec.DestroyOnDelete != nil && *s.Spec.DestroyOnDelete
+}
+
+// IsDestroyTerminated returns true if the destroy task is in a terminal state
+func (s *Stack) IsDestroyTerminated() bool {
+	_, found := terminalStates[s.Status.DestroyRunState]
+	return found
+}
+
+// ShouldRetryDestroy returns true if the destroy task did not succeed and must be triggered again,
+// because the spec changed since it was triggered or the retry-destroy annotation is set
+func (s *Stack) ShouldRetryDestroy() bool {
+	return s.IsDestroyTerminated() && s.Status.DestroyRunState != RunStateFinished &&
+		(s.Generation != s.Status.DestroyRunGeneration || IsRetryDestroy(s))
+}
+
 // SetStack is used to sync the k8s CRD with a spacelift stack model.
 // It basically takes care of updating all status fields
 func (s *Stack) SetStack(stack models.Stack) {
@@ -157,12 +284,66 @@ func (s *Stack) SetStack(stack models.Stack) {
 	}
 }
 
+// GetAccountRef returns the account of the stack, see AccountObject
+func (s *Stack) GetAccountRef() *AccountReference {
+	return s.Spec.AccountRef
+}
+
+// StatusConditions returns the conditions of the stack status, see ConditionedObject
+func (s *Stack) StatusConditions() *[]metav1.Condition {
+	return &s.Status.Conditions
+}
+
+// StatusPlannedChanges returns the changes reported in dry-run mode, see DryRunObject
+func (s *Stack) StatusPlannedChanges() *[]FieldChange {
+	return &s.Status.PlannedChanges
+}
+
+// SetObserved reports the Spacelift stack read by the operator, see ObservedObject
+func (s *Stack) SetObserved(observed *ObservedState) {
+	s.Status.Observed = observed
+}
+
+// SetObservedGeneration sets the generation of the spec last handled by the operator
+func (s *Stack) SetObservedGeneration(generation int64) {
+	s.Status.ObservedGeneration = generation
+}
+
 type AWSIntegration struct {
 	Id    string `json:"id"`
 	Read  bool   `json:"read"`
 	Write bool   `json:"write"`
 }
 
+// CloudIntegration is a cloud integration of the stack, exactly one of aws, azure or gcp must be set.
+// +kubebuilder:validation:XValidation:rule="(has(self.aws) ? 1 : 0) + (has(self.azure) ? 1 : 0) + (has(self.gcp) ? 1 : 0) == 1",message="exactly one of aws, azure or gcp must be set"
+type CloudIntegration struct {
+	// AWS attaches an AWS integration to the stack.
+	// +optional
+	AWS *AWSIntegration `json:"aws,omitempty"`
+	// Azure attaches an Azure integration to the stack.
+	// +optional
+	Azure *AzureIntegration `json:"azure,omitempty"`
+	// GCP creates a service account for the stack in Google Cloud.
+	// +optional
+	GCP *GCPIntegration `json:"gcp,omitempty"`
+}
+
+type AzureIntegration struct {
+	Id    string `json:"id"`
+	Read  bool   `json:"read"`
+	Write bool   `json:"write"`
+	// SubscriptionId overrides the default subscription ID of the integration.
+	// +optional
+	SubscriptionId *string `json:"subscriptionId,omitempty"`
+}
+
+type GCPIntegration struct {
+	// TokenScopes are the OAuth scopes of the tokens generated for the service account of the stack.
+	// +kubebuilder:validation:MinItems=1
+	TokenScopes []string `json:"tokenScopes"`
+}
+
 //+kubebuilder:object:root=true
 
 // StackList contains a list of Stack
@@ -17,19 +17,23 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	kubezap "sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -40,8 +44,11 @@ import (
 	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
 	"github.com/spacelift-io/spacelift-operator/internal/logging/encoders"
+	"github.com/spacelift-io/spacelift-operator/internal/metrics"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/watcher"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/webhook"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 var (
@@ -60,11 +67,37 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var resyncPeriod time.Duration
+	var webhookAddr, webhookSecretNamespace, webhookSecretName string
+	var webhookPollInterval time.Duration
+	var tracingOptions tracing.Options
+	var dryRun bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&resyncPeriod, "resync-period", 10*time.Minute,
+		"How often stacks, contexts and policies are compared with Spacelift to detect drift. "+
+			"Set to 0 to disable periodic resync.")
+	flag.StringVar(&webhookAddr, "webhook-bind-address", "",
+		"The address the Spacelift webhook receiver binds to. Leave empty to disable it and only poll run states.")
+	flag.StringVar(&webhookSecretNamespace, "webhook-secret-namespace", "",
+		"The namespace of the secret holding the Spacelift webhook secret.")
+	flag.StringVar(&webhookSecretName, "webhook-secret-name", "spacelift-webhook",
+		"The name of the secret holding the Spacelift webhook secret.")
+	flag.DurationVar(&webhookPollInterval, "webhook-poll-interval", time.Minute,
+		"How often run states are polled as a fallback when the webhook receiver is enabled.")
+	flag.StringVar(&tracingOptions.Exporter, "tracing-exporter", tracing.ExporterNone,
+		"The exporter of OpenTelemetry traces, either otlp-grpc or otlp-http. Leave empty to disable tracing.")
+	flag.StringVar(&tracingOptions.Endpoint, "tracing-endpoint", "",
+		"The host and port of the OTLP collector. Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable.")
+	flag.BoolVar(&tracingOptions.Insecure, "tracing-insecure", false,
+		"Send traces to the OTLP collector without TLS.")
+	flag.Float64Var(&tracingOptions.SampleRatio, "tracing-sample-ratio", 1,
+		"The ratio of reconciliations traced, between 0 and 1.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Report the changes to stacks, spaces, contexts and policies in their status instead of applying them to Spacelift.")
 	opts := kubezap.Options{
 		Level: zap.NewAtomicLevelAt(zapcore.Level(-logging.Level2)),
 	}
@@ -84,6 +117,13 @@ func main() {
 	ctrl.SetLogger(kubezap.New(zapOptions))
 	setupLog.Info("Logger initialized", "level", opts.Level)
 
+	ctx := ctrl.SetupSignalHandler()
+	shutdownTracing, err := tracing.Setup(ctx, tracingOptions)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
@@ -107,6 +147,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := repository.IndexStacks(ctx, mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to index stacks")
+		os.Exit(1)
+	}
+
 	runRepo := repository.NewRunRepository(mgr.GetClient(), mgr.GetScheme())
 	stackRepo := repository.NewStackRepository(mgr.GetClient(), mgr.GetScheme())
 	stackOutputRepo := repository.NewStackOutputRepository(mgr.GetClient(), mgr.GetScheme(), mgr.GetEventRecorderFor("stack-output-repository"))
@@ -114,44 +159,80 @@ func main() {
 	contextRepo := repository.NewContextRepository(mgr.GetClient(), mgr.GetScheme())
 	secretRepo := repository.NewSecretRepository(mgr.GetClient())
 	policyRepo := repository.NewPolicyRepository(mgr.GetClient(), mgr.GetScheme())
+	namespaceRepo := repository.NewNamespaceRepository(mgr.GetClient())
 	spaceliftRunRepo := spaceliftRepository.NewRunRepository(mgr.GetClient())
 	spaceliftStackRepo := spaceliftRepository.NewStackRepository(mgr.GetClient())
 	spaceliftContextRepo := spaceliftRepository.NewContextRepository(mgr.GetClient())
 	spaceliftPolicyRepo := spaceliftRepository.NewPolicyRepository(mgr.GetClient())
-	runWatcher := watcher.NewRunWatcher(runRepo, spaceliftRunRepo)
+	runWatcher := watcher.NewRunWatcher(runRepo, stackRepo, namespaceRepo, spaceliftRunRepo)
+
+	if webhookAddr != "" {
+		if webhookSecretNamespace == "" {
+			setupLog.Error(nil, "--webhook-secret-namespace is required when the webhook receiver is enabled")
+			os.Exit(1)
+		}
+		secretName := types.NamespacedName{Namespace: webhookSecretNamespace, Name: webhookSecretName}
+		if err := mgr.Add(webhook.NewReceiver(webhookAddr, secretName, secretRepo, runRepo, stackRepo, namespaceRepo)); err != nil {
+			setupLog.Error(err, "unable to set up webhook receiver")
+			os.Exit(1)
+		}
+		// Webhooks push run states, polling is only kept in case a notification is lost
+		runWatcher.FallbackInterval = webhookPollInterval
+	}
 
 	if err = (&controller.RunReconciler{
 		RunRepository:            runRepo,
+		NamespaceRepository:      namespaceRepo,
 		StackRepository:          stackRepo,
 		StackOutputRepository:    stackOutputRepo,
 		SpaceliftRunRepository:   spaceliftRunRepo,
 		SpaceliftStackRepository: spaceliftStackRepo,
 		RunWatcher:               runWatcher,
+		EventRecorder:            mgr.GetEventRecorderFor("run-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Run")
 		os.Exit(1)
 	}
 	if err = (&controller.StackReconciler{
 		StackRepository:          stackRepo,
+		NamespaceRepository:      namespaceRepo,
 		SpaceRepository:          spaceRepo,
+		SecretRepository:         secretRepo,
+		ContextRepository:        contextRepo,
 		SpaceliftStackRepository: spaceliftStackRepo,
+		SpaceliftRunRepository:   spaceliftRunRepo,
+		RunWatcher:               runWatcher,
+		EventRecorder:            mgr.GetEventRecorderFor("stack-controller"),
+		ResyncPeriod:             resyncPeriod,
+		DryRun:                   dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Stack")
 		os.Exit(1)
 	}
 	if err = (&controller.SpaceReconciler{
 		SpaceRepository:          spaceRepo,
+		NamespaceRepository:      namespaceRepo,
+		StackRepository:          stackRepo,
+		ContextRepository:        contextRepo,
+		PolicyRepository:         policyRepo,
 		SpaceliftSpaceRepository: spaceliftRepository.NewSpaceRepository(mgr.GetClient()),
+		EventRecorder:            mgr.GetEventRecorderFor("space-controller"),
+		ResyncPeriod:             resyncPeriod,
+		DryRun:                   dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Space")
 		os.Exit(1)
 	}
 	if err = (&controller.ContextReconciler{
 		ContextRepository:          contextRepo,
+		NamespaceRepository:        namespaceRepo,
 		StackRepository:            stackRepo,
 		SpaceRepository:            spaceRepo,
 		SecretRepository:           secretRepo,
 		SpaceliftContextRepository: spaceliftContextRepo,
+		EventRecorder:              mgr.GetEventRecorderFor("context-controller"),
+		ResyncPeriod:               resyncPeriod,
+		DryRun:                     dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Context")
 		os.Exit(1)
@@ -159,14 +240,23 @@ func main() {
 	if err = (&controller.PolicyReconciler{
 		StackRepository:           stackRepo,
 		PolicyRepository:          policyRepo,
+		NamespaceRepository:       namespaceRepo,
 		SpaceRepository:           spaceRepo,
 		SpaceliftPolicyRepository: spaceliftPolicyRepo,
+		EventRecorder:             mgr.GetEventRecorderFor("policy-controller"),
+		ResyncPeriod:              resyncPeriod,
+		DryRun:                    dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Policy")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
 
+	if err := ctrlmetrics.Registry.Register(metrics.NewRunStateCollector(mgr.GetClient())); err != nil {
+		setupLog.Error(err, "unable to register run metrics")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -177,7 +267,15 @@ func main() {
 	}
 
 	setupLog.Info("starting manager", "version", build.Version)
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctx)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
+	cancel()
+
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+// adopt binds a resource that has no spacelift ID yet to the spacelift resource set in its adopt annotation.
+// The ID is only set in memory and gets persisted with the next status update.
+// It returns true if the resource is being adopted during this reconciliation.
+func adopt(obj client.Object, statusId *string) bool {
+	adoptId := v1beta1.AdoptId(obj)
+	if adoptId == "" || *statusId != "" {
+		return false
+	}
+	*statusId = adoptId
+	return true
+}
+
+// adoptionFailed reports that the spacelift resource to adopt does not exist.
+// A resource meant to be adopted is never created, the annotation has to be fixed first.
+// The status ID is cleared so the resource is not considered ready by the resources depending on it.
+func adoptionFailed(ctx context.Context, recorder record.EventRecorder, obj v1beta1.ConditionedObject, statusId *string, updateStatus func() error, kind string) (ctrl.Result, error) {
+	err := errors.Errorf("%s %s to adopt does not exist in spacelift", kind, v1beta1.AdoptId(obj))
+	log.FromContext(ctx).Error(err, "Unable to adopt resource")
+	recorder.Event(obj, v1.EventTypeWarning, v1beta1.EventReasonAdoptionFailed, err.Error())
+	*statusId = ""
+	markFailed(ctx, obj, updateStatus, v1beta1.ReasonAdoptionFailed, err)
+	return ctrl.Result{}, err
+}
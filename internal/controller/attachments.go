@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"slices"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+)
+
+// stackAttachmentChanged only lets through the stacks whose attachment to a context or a policy may have changed:
+// new and deleted stacks, stacks that just became ready, and changes to their labels or to spec.contexts.
+var stackAttachmentChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldStack, ok := e.ObjectOld.(*v1beta1.Stack)
+		if !ok {
+			return false
+		}
+		newStack, ok := e.ObjectNew.(*v1beta1.Stack)
+		return ok && (oldStack.Ready() != newStack.Ready() ||
+			!reflect.DeepEqual(oldStack.Labels, newStack.Labels) ||
+			!reflect.DeepEqual(oldStack.Spec.Contexts, newStack.Spec.Contexts))
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return true },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// enqueueAttachedContexts returns a handler reconciling the contexts of the stack namespace that the stack lists
+// in spec.contexts by name or by ID, that are still attached to the stack, so stacks that don't match anymore are detached,
+// or that select the stack with their attachment selector. Only the contexts with a selector are matched against the stack labels.
+func enqueueAttachedContexts(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		stack, ok := obj.(*v1beta1.Stack)
+		if !ok {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, stackContext := range stack.Spec.Contexts {
+			if stackContext.ContextName != nil {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: stack.Namespace, Name: *stackContext.ContextName}})
+			}
+			if stackContext.ContextId != nil {
+				requests = append(requests, listRequests(ctx, c, &v1beta1.ContextList{},
+					client.InNamespace(stack.Namespace), client.MatchingFields{contextIdIndex: *stackContext.ContextId})...)
+			}
+		}
+		if stack.Status.Id != "" {
+			requests = append(requests, listRequests(ctx, c, &v1beta1.ContextList{},
+				client.InNamespace(stack.Namespace), client.MatchingFields{attachedStackIdsIndex: stack.Status.Id})...)
+		}
+
+		var selecting v1beta1.ContextList
+		if err := c.List(ctx, &selecting, client.InNamespace(stack.Namespace), client.MatchingFields{attachmentSelectorIndex: "true"}); err != nil {
+			log.FromContext(ctx).Error(err, "Unable to list the resources to reconcile")
+			return requests
+		}
+		for _, context := range selecting.Items {
+			if selectsStack(context.Spec.AttachmentSelector, stack) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&context)})
+			}
+		}
+		return requests
+	})
+}
+
+// enqueueSelectingPolicies returns a handler reconciling the policies of the stack namespace that select the stack
+// with their stack selector, or that are still attached to the stack, so stacks that don't match anymore are detached.
+func enqueueSelectingPolicies(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		stack, ok := obj.(*v1beta1.Stack)
+		if !ok {
+			return nil
+		}
+		var list v1beta1.PolicyList
+		if err := c.List(ctx, &list, client.InNamespace(stack.Namespace)); err != nil {
+			log.FromContext(ctx).Error(err, "Unable to list the resources to reconcile")
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, policy := range list.Items {
+			if selectsStack(policy.Spec.StackSelector, stack) || attachedTo(policy.Status.AttachedStacksIds, stack) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
+			}
+		}
+		return requests
+	})
+}
+
+// selectsStack returns true when the label selector is set and matches the labels of the stack.
+func selectsStack(labelSelector *metav1.LabelSelector, stack *v1beta1.Stack) bool {
+	if labelSelector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	return err == nil && selector.Matches(labels.Set(stack.Labels))
+}
+
+// attachedTo returns true when the stack is one of the attached stacks recorded in the status of a context or a policy.
+func attachedTo(attachedStackIds []string, stack *v1beta1.Stack) bool {
+	return stack.Status.Id != "" && slices.Contains(attachedStackIds, stack.Status.Id)
+}
+
+// selectedStackIds returns the IDs of the ready stacks of the namespace matching the selector.
+// Stacks that are not ready yet are skipped, they are attached once they become ready.
+func selectedStackIds(ctx context.Context, stacks *repository.StackRepository, namespace string, selector labels.Selector) ([]string, error) {
+	selected, err := stacks.ListBySelector(ctx, namespace, selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the stacks matching the stack selector")
+	}
+	var ids []string
+	for _, stack := range selected {
+		if stack.Ready() {
+			ids = append(ids, stack.Status.Id)
+		}
+	}
+	return ids, nil
+}
+
+// stackAttachments returns the attachments of the context to the ready stacks matching the attachment selector, if any,
+// and to the ready stacks listing the context in spec.contexts, by name or by ID, with their priority.
+// They are sent along with spec.attachments, so updating the context in spacelift keeps them attached.
+func stackAttachments(ctx context.Context, stacks *repository.StackRepository, context *v1beta1.Context, selector labels.Selector) ([]v1beta1.Attachment, error) {
+	var candidates []v1beta1.Stack
+	if selector != nil {
+		selected, err := stacks.ListBySelector(ctx, context.Namespace, selector)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to list the stacks matching the attachment selector")
+		}
+		candidates = append(candidates, selected...)
+	}
+	referencing, err := stacks.ListByContext(ctx, context)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the stacks attaching the context")
+	}
+	candidates = append(candidates, referencing...)
+
+	var attachments []v1beta1.Attachment
+	for _, stack := range candidates {
+		if !stack.Ready() {
+			continue
+		}
+		attached := func(a v1beta1.Attachment) bool { return a.StackId != nil && *a.StackId == stack.Status.Id }
+		if slices.ContainsFunc(context.Spec.Attachments, attached) || slices.ContainsFunc(attachments, attached) {
+			continue
+		}
+		attachment := v1beta1.Attachment{StackId: &stack.Status.Id}
+		for _, stackContext := range stack.Spec.Contexts {
+			if (stackContext.ContextName != nil && *stackContext.ContextName == context.ObjectMeta.Name) ||
+				(stackContext.ContextId != nil && context.Status.Id != "" && *stackContext.ContextId == context.Status.Id) {
+				attachment.Priority = stackContext.Priority
+			}
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// stackIds returns the sorted IDs of the stacks of the attachments.
+func stackIds(attachments []v1beta1.Attachment) []string {
+	var ids []string
+	for _, attachment := range attachments {
+		if attachment.StackId != nil {
+			ids = append(ids, *attachment.StackId)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
+	"github.com/spacelift-io/spacelift-operator/tests/integration"
+)
+
+func Test_stackAttachmentChanged(t *testing.T) {
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"env": "prod"}}}
+	ready := stack.DeepCopy()
+	ready.Status.Id = "stack-id"
+	relabeled := stack.DeepCopy()
+	relabeled.Labels["env"] = "dev"
+	withContext := stack.DeepCopy()
+	withContext.Spec.Contexts = []v1beta1.StackContext{{ContextName: utils.AddressOf("context")}}
+	described := stack.DeepCopy()
+	described.Spec.Description = utils.AddressOf("description")
+
+	assert.True(t, stackAttachmentChanged.Create(event.CreateEvent{Object: stack}))
+	assert.True(t, stackAttachmentChanged.Delete(event.DeleteEvent{Object: stack}))
+	assert.True(t, stackAttachmentChanged.Update(event.UpdateEvent{ObjectOld: stack, ObjectNew: ready}))
+	assert.True(t, stackAttachmentChanged.Update(event.UpdateEvent{ObjectOld: stack, ObjectNew: relabeled}))
+	assert.True(t, stackAttachmentChanged.Update(event.UpdateEvent{ObjectOld: stack, ObjectNew: withContext}))
+	assert.False(t, stackAttachmentChanged.Update(event.UpdateEvent{ObjectOld: stack, ObjectNew: described}))
+}
+
+func newAttachmentsClient(t *testing.T) *fake.ClientBuilder {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	require.NoError(t, repository.IndexStacks(context.Background(), integration.FakeIndexer{Builder: builder}))
+	return builder
+}
+
+func Test_enqueueAttachedContexts(t *testing.T) {
+	k8sClient := newAttachmentsClient(t).
+		WithObjects(
+			&v1beta1.Context{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "selecting"},
+				Spec:       v1beta1.ContextSpec{AttachmentSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			},
+			&v1beta1.Context{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"},
+				Spec:       v1beta1.ContextSpec{AttachmentSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}}},
+			},
+			&v1beta1.Context{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "referenced"}},
+			&v1beta1.Context{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "referenced-by-id"},
+				Status:     v1beta1.ContextStatus{Id: "context-id"},
+			},
+			&v1beta1.Context{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "attached"},
+				Spec:       v1beta1.ContextSpec{AttachmentSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "dev"}}},
+				Status:     v1beta1.ContextStatus{AttachedStackIds: []string{"stack-id"}},
+			},
+			&v1beta1.Context{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "selecting"},
+				Spec:       v1beta1.ContextSpec{AttachmentSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			},
+			&v1beta1.Context{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unrelated"},
+				Status:     v1beta1.ContextStatus{Id: "unrelated-id", AttachedStackIds: []string{"other-stack-id"}},
+			},
+		).
+		WithIndex(&v1beta1.Context{}, contextIdIndex, indexContextId).
+		WithIndex(&v1beta1.Context{}, attachedStackIdsIndex, indexAttachedStackIds).
+		WithIndex(&v1beta1.Context{}, attachmentSelectorIndex, indexAttachmentSelector).
+		Build()
+
+	handler := enqueueAttachedContexts(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stack", Labels: map[string]string{"env": "prod"}},
+		Spec: v1beta1.StackSpec{Contexts: []v1beta1.StackContext{
+			{ContextName: utils.AddressOf("referenced")},
+			{ContextId: utils.AddressOf("context-id")},
+		}},
+		Status: v1beta1.StackStatus{Id: "stack-id"},
+	}
+	handler.Create(context.Background(), event.CreateEvent{Object: stack}, queue)
+
+	// The context still attached to the stack is reconciled to detach it
+	require.Equal(t, 4, queue.Len())
+	var names []types.NamespacedName
+	for queue.Len() > 0 {
+		request, _ := queue.Get()
+		names = append(names, request.NamespacedName)
+		queue.Done(request)
+	}
+	assert.ElementsMatch(t, []types.NamespacedName{
+		{Namespace: "default", Name: "selecting"},
+		{Namespace: "default", Name: "referenced"},
+		{Namespace: "default", Name: "referenced-by-id"},
+		{Namespace: "default", Name: "attached"},
+	}, names)
+}
+
+func Test_stackAttachments(t *testing.T) {
+	stack := func(name, id string, stackLabels map[string]string, contexts ...v1beta1.StackContext) *v1beta1.Stack {
+		return &v1beta1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: stackLabels},
+			Spec:       v1beta1.StackSpec{Contexts: contexts},
+			Status:     v1beta1.StackStatus{Id: id},
+		}
+	}
+	k8sClient := newAttachmentsClient(t).
+		WithObjects(
+			stack("selected", "selected-id", map[string]string{"env": "prod"}),
+			stack("not-ready", "", map[string]string{"env": "prod"}),
+			stack("listed", "listed-id", map[string]string{"env": "prod"}),
+			stack("other", "other-id", map[string]string{"env": "dev"}),
+			stack("referencing", "referencing-id", nil, v1beta1.StackContext{ContextName: utils.AddressOf("context"), Priority: utils.AddressOf(3)}),
+			stack("referencing-by-id", "referencing-by-id-id", nil, v1beta1.StackContext{ContextId: utils.AddressOf("context-id")}),
+		).
+		Build()
+
+	c := &v1beta1.Context{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "context"},
+		Spec: v1beta1.ContextSpec{
+			Attachments: []v1beta1.Attachment{{StackId: utils.AddressOf("listed-id")}},
+		},
+		Status: v1beta1.ContextStatus{Id: "context-id"},
+	}
+	selector := labels.SelectorFromSet(labels.Set{"env": "prod"})
+	attachments, err := stackAttachments(context.Background(), repository.NewStackRepository(k8sClient, nil), c, selector)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []v1beta1.Attachment{
+		{StackId: utils.AddressOf("selected-id")},
+		{StackId: utils.AddressOf("referencing-id"), Priority: utils.AddressOf(3)},
+		{StackId: utils.AddressOf("referencing-by-id-id")},
+	}, attachments)
+
+	// Without a selector, only the stacks listing the context are attached
+	attachments, err = stackAttachments(context.Background(), repository.NewStackRepository(k8sClient, nil), c, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []v1beta1.Attachment{
+		{StackId: utils.AddressOf("referencing-id"), Priority: utils.AddressOf(3)},
+		{StackId: utils.AddressOf("referencing-by-id-id")},
+	}, attachments)
+}
+
+func Test_stackIds(t *testing.T) {
+	ids := stackIds([]v1beta1.Attachment{
+		{StackId: utils.AddressOf("b")},
+		{ModuleId: utils.AddressOf("module")},
+		{StackId: utils.AddressOf("a")},
+	})
+	assert.Equal(t, []string{"a", "b"}, ids)
+	assert.Nil(t, stackIds(nil))
+}
+
+func Test_enqueueSelectingPolicies(t *testing.T) {
+	k8sClient := newAttachmentsClient(t).
+		WithObjects(
+			&v1beta1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "prod"},
+				Spec:       v1beta1.PolicySpec{StackSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}},
+			},
+			&v1beta1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dev"},
+				Spec:       v1beta1.PolicySpec{StackSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "dev"}}},
+			},
+			&v1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unselecting"}},
+			&v1beta1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "prod"},
+				Spec:       v1beta1.PolicySpec{StackSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}},
+			},
+		).
+		Build()
+
+	handler := enqueueSelectingPolicies(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stack", Labels: map[string]string{"tier": "prod"}}}
+	relabeled := stack.DeepCopy()
+	relabeled.Labels["tier"] = "dev"
+	// Both the policy the stack is no longer selected by and the one now selecting it are reconciled
+	handler.Update(context.Background(), event.UpdateEvent{ObjectOld: stack, ObjectNew: relabeled}, queue)
+
+	require.Equal(t, 2, queue.Len())
+	var names []types.NamespacedName
+	for queue.Len() > 0 {
+		request, _ := queue.Get()
+		names = append(names, request.NamespacedName)
+		queue.Done(request)
+	}
+	assert.ElementsMatch(t, []types.NamespacedName{
+		{Namespace: "default", Name: "prod"},
+		{Namespace: "default", Name: "dev"},
+	}, names)
+}
+
+func Test_enqueueSelectingPolicies_attachedStack(t *testing.T) {
+	k8sClient := newAttachmentsClient(t).
+		WithObjects(
+			&v1beta1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "attached"},
+				Spec:       v1beta1.PolicySpec{StackSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}},
+				Status:     v1beta1.PolicyStatus{AttachedStacksIds: []string{"stack-id"}},
+			},
+			&v1beta1.Policy{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"},
+				Spec:       v1beta1.PolicySpec{StackSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}},
+				Status:     v1beta1.PolicyStatus{AttachedStacksIds: []string{"other-stack-id"}},
+			},
+		).
+		Build()
+
+	handler := enqueueSelectingPolicies(k8sClient)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	// The stack is not selected anymore, the policy it is still attached to is reconciled to detach it
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stack", Labels: map[string]string{"tier": "dev"}},
+		Status:     v1beta1.StackStatus{Id: "stack-id"},
+	}
+	handler.Create(context.Background(), event.CreateEvent{Object: stack}, queue)
+
+	require.Equal(t, 1, queue.Len())
+	request, _ := queue.Get()
+	assert.Equal(t, types.NamespacedName{Namespace: "default", Name: "attached"}, request.NamespacedName)
+	queue.Done(request)
+}
+
+func Test_selectedStackIds(t *testing.T) {
+	stack := func(name, id, tier string) *v1beta1.Stack {
+		return &v1beta1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: map[string]string{"tier": tier}},
+			Status:     v1beta1.StackStatus{Id: id},
+		}
+	}
+	k8sClient := newAttachmentsClient(t).
+		WithObjects(
+			stack("prod", "prod-id", "prod"),
+			stack("not-ready", "", "prod"),
+			stack("dev", "dev-id", "dev"),
+		).
+		Build()
+
+	selector := labels.SelectorFromSet(labels.Set{"tier": "prod"})
+	ids, err := selectedStackIds(context.Background(), repository.NewStackRepository(k8sClient, nil), "default", selector)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod-id"}, ids)
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+)
+
+// markWaiting reports in the resource status that one of its dependencies is missing or not ready yet.
+func markWaiting(ctx context.Context, obj v1beta1.ConditionedObject, updateStatus func() error, reason, message string) {
+	v1beta1.MarkDependenciesNotReady(obj, reason, message)
+	persistConditions(ctx, updateStatus)
+}
+
+// markFailed reports in the resource status that it could not be synced with spacelift.
+func markFailed(ctx context.Context, obj v1beta1.ConditionedObject, updateStatus func() error, reason string, err error) {
+	v1beta1.MarkSyncFailed(obj, reason, err.Error())
+	persistConditions(ctx, updateStatus)
+}
+
+// syncFailed reports a failed create or update in spacelift, both in the resource status and as a warning event,
+// so the error is visible to users applying manifests through GitOps tools.
+// Inputs rejected by spacelift are reported with the ValidationFailed reason, as they fail until the spec is fixed.
+func syncFailed(ctx context.Context, recorder record.EventRecorder, obj v1beta1.ConditionedObject, updateStatus func() error, reason string, err error) (ctrl.Result, error) {
+	if spaceliftclient.KindOf(err) == spaceliftclient.ErrorKindValidation {
+		reason = v1beta1.ReasonValidationFailed
+	}
+	message := spaceliftclient.Message(err)
+	recorder.Event(obj, v1.EventTypeWarning, reason, message)
+	v1beta1.MarkSyncFailed(obj, reason, message)
+	persistConditions(ctx, updateStatus)
+	return requeueOnError(err)
+}
+
+// persistConditions saves the conditions set on a resource that is about to be requeued.
+// Failures are only logged since the conditions will be reported again on the next reconciliation.
+func persistConditions(ctx context.Context, updateStatus func() error) {
+	if err := updateStatus(); err != nil && !k8sErrors.IsConflict(err) && !k8sErrors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Unable to update resource conditions")
+	}
+}
+
+// requeueOnError decides how a resource is requeued after a failed spacelift request.
+// Rate limited requests are requeued after the delay requested by the API, and other retryable or unknown errors
+// are returned so the resource is requeued with a backoff. Requests rejected by the API, or referencing missing
+// spacelift resources, fail again until the resource is changed, so they are not retried.
+func requeueOnError(err error) (ctrl.Result, error) {
+	switch spaceliftclient.KindOf(err) {
+	case spaceliftclient.ErrorKindValidation, spaceliftclient.ErrorKindNotFound:
+		return ctrl.Result{}, nil
+	case spaceliftclient.ErrorKindRateLimited:
+		if retryAfter := spaceliftclient.RetryAfter(err); retryAfter > 0 {
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+	}
+	return ctrl.Result{}, err
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+)
+
+func Test_requeueOnError(t *testing.T) {
+	clientErr := func(kind spaceliftclient.ErrorKind, retryAfter time.Duration) error {
+		return errors.Wrap(&spaceliftclient.Error{Kind: kind, RetryAfter: retryAfter, Err: errors.New("failed")}, "unable to create stack")
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantResult ctrl.Result
+		wantErr    bool
+	}{
+		{name: "transient", err: clientErr(spaceliftclient.ErrorKindTransient, 0), wantErr: true},
+		{name: "rate limited", err: clientErr(spaceliftclient.ErrorKindRateLimited, time.Minute), wantResult: ctrl.Result{RequeueAfter: time.Minute}},
+		{name: "rate limited without delay", err: clientErr(spaceliftclient.ErrorKindRateLimited, 0), wantErr: true},
+		{name: "auth", err: clientErr(spaceliftclient.ErrorKindAuth, 0), wantErr: true},
+		{name: "validation", err: clientErr(spaceliftclient.ErrorKindValidation, 0)},
+		{name: "not found", err: clientErr(spaceliftclient.ErrorKindNotFound, 0)},
+		{name: "unknown", err: errors.New("failed"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := requeueOnError(tt.err)
+			assert.Equal(t, tt.wantResult, result)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_syncFailed(t *testing.T) {
+	graphqlErrs := graphql.GraphQLErrors{{Message: "branch does not exist", Path: []interface{}{"stackCreate", "branch"}}}
+	validationErr := errors.Wrap(&spaceliftclient.Error{Kind: spaceliftclient.ErrorKindValidation, Err: graphqlErrs}, "unable to create stack")
+	transientErr := errors.Wrap(&spaceliftclient.Error{Kind: spaceliftclient.ErrorKindTransient, Err: errors.New("connection reset")}, "unable to create stack")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantReason  string
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:        "validation",
+			err:         validationErr,
+			wantReason:  v1beta1.ReasonValidationFailed,
+			wantMessage: "stackCreate.branch: branch does not exist",
+		},
+		{
+			name:        "transient",
+			err:         transientErr,
+			wantReason:  v1beta1.ReasonCreateFailed,
+			wantMessage: "unable to create stack: connection reset",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "stack", Generation: 1}}
+			recorder := record.NewFakeRecorder(1)
+			updated := false
+
+			_, err := syncFailed(context.Background(), recorder, stack, func() error { updated = true; return nil }, v1beta1.ReasonCreateFailed, tt.err)
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.True(t, updated)
+
+			synced := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionSynced)
+			require.NotNil(t, synced)
+			assert.Equal(t, metav1.ConditionFalse, synced.Status)
+			assert.Equal(t, tt.wantReason, synced.Reason)
+			assert.Equal(t, tt.wantMessage, synced.Message)
+			assert.Equal(t, "Warning "+tt.wantReason+" "+tt.wantMessage, <-recorder.Events)
+		})
+	}
+}
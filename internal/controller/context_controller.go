@@ -18,12 +18,19 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -33,22 +40,31 @@ import (
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/drift"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
-	"github.com/spacelift-io/spacelift-operator/internal/utils"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 // ContextReconciler reconciles a Context object
 type ContextReconciler struct {
 	SpaceliftContextRepository spaceliftRepository.ContextRepository
 	ContextRepository          *repository.ContextRepository
+	NamespaceRepository        *repository.NamespaceRepository
 	StackRepository            *repository.StackRepository
 	SpaceRepository            *repository.SpaceRepository
 	SecretRepository           *repository.SecretRepository
+	EventRecorder              record.EventRecorder
+	// ResyncPeriod is the delay after which a synced resource is compared with spacelift again, 0 disables it
+	ResyncPeriod time.Duration
+	// DryRun reports the changes to every context instead of applying them to spacelift
+	DryRun bool
 }
 
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=contexts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=contexts/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=contexts/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -72,17 +88,58 @@ func (r *ContextReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Nothing is changed in spacelift while the reconciliation is paused, not even deletions
+	updateStatus := func() error { return r.ContextRepository.UpdateStatus(ctx, context) }
+	if paused, err := handlePause(ctx, r.NamespaceRepository, context, updateStatus); paused || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !context.DeletionTimestamp.IsZero() {
+		return r.handleDeleteContext(ctx, context)
+	}
+
+	// The finalizer is only needed when the context has to be cleaned up in Spacelift.
+	// This must happen before the spec is used as a DTO below, since it updates the whole resource.
+	if context.Spec.DeletionPolicy == v1beta1.DeletionPolicyDelete {
+		if err := r.ContextRepository.AddFinalizer(ctx, context); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Context finalizer update, let's try again.")
+				return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			logger.Error(err, "Error adding finalizer to context.")
+			return ctrl.Result{}, err
+		}
+	}
+
 	logger = logger.WithValues(logging.ContextName, context.Spec.Name)
 	log.IntoContext(ctx, logger)
 
+	// Observed contexts are never created nor updated, so their dependencies and secrets don't need to be resolved
+	if context.Spec.ManagementPolicy == v1beta1.ManagementPolicyObserveOnly {
+		if context.Status.Id == "" {
+			context.Status.Id = observeId(context, context.Name())
+		}
+		spaceliftContext, err := r.SpaceliftContextRepository.Get(ctx, context)
+		if errors.Is(err, spaceliftRepository.ErrContextNotFound) {
+			return observeFailed(ctx, r.EventRecorder, context, &context.Status.Id, updateStatus, "context", context.Status.Id)
+		}
+		if err != nil {
+			err = errors.Wrap(err, "unable to retrieve context from spacelift")
+			markFailed(ctx, context, updateStatus, v1beta1.ReasonSpaceliftError, err)
+			return requeueOnError(err)
+		}
+		return r.observeContext(ctx, context, spaceliftContext)
+	}
+
 	// A context should always be linked to a valid space
 	if context.Spec.SpaceName != nil {
 		logger := logger.WithValues(logging.SpaceName, *context.Spec.SpaceName)
 		space, err := r.SpaceRepository.Get(ctx, types.NamespacedName{Namespace: context.Namespace, Name: *context.Spec.SpaceName})
 		if err != nil {
 			if k8sErrors.IsNotFound(err) {
-				logger.Info("Unable to find space for context, will retry in 10 seconds")
-				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+				logger.Info("Unable to find space for context, waiting for it to be created")
+				markWaiting(ctx, context, updateStatus, v1beta1.ReasonSpaceNotFound, fmt.Sprintf("Space %s not found", *context.Spec.SpaceName))
+				return ctrl.Result{}, nil
 			}
 			logger.Error(err, "Error fetching space for context.")
 			return ctrl.Result{}, err
@@ -96,8 +153,9 @@ func (r *ContextReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 
 		if !space.Ready() {
-			logger.Info("Space is not ready, will retry in 3 seconds")
-			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+			logger.Info("Space is not ready, waiting for it")
+			markWaiting(ctx, context, updateStatus, v1beta1.ReasonSpaceNotReady, fmt.Sprintf("Space %s is not ready", *context.Spec.SpaceName))
+			return ctrl.Result{}, nil
 		}
 		// This set the space ID in the spec object to be reused in the graphql mutation.
 		// We kind of use the context spec as a DTO here, but since we never update the spec in the controller
@@ -116,104 +174,113 @@ func (r *ContextReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			})
 			if err != nil {
 				if k8sErrors.IsNotFound(err) {
-					logger.Info("Unable to find stack for context, will retry in 10 seconds")
-					return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+					logger.Info("Unable to find stack for context, waiting for it to be created")
+					markWaiting(ctx, context, updateStatus, v1beta1.ReasonStackNotFound, fmt.Sprintf("Stack %s not found", *attachment.StackName))
+					return ctrl.Result{}, nil
 				}
 				logger.Error(err, "Error fetching stack for context.")
 				return ctrl.Result{}, err
 			}
 			if !stack.Ready() {
-				logger.Info("Stack is not ready, will retry in 3 seconds")
-				return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+				logger.Info("Stack is not ready, waiting for it")
+				markWaiting(ctx, context, updateStatus, v1beta1.ReasonStackNotReady, fmt.Sprintf("Stack %s is not ready", *attachment.StackName))
+				return ctrl.Result{}, nil
 			}
 			// This set the stack ID in the spec object to be reused in the graphql mutation.
 			context.Spec.Attachments[i].StackId = &stack.Status.Id
 		}
 	}
 
-	for i, environment := range context.Spec.Environment {
-		if environment.ValueFromSecret != nil {
-			logger := logger.WithValues(
-				logging.SecretName, environment.ValueFromSecret.Name,
-				logging.EnvironmentId, environment.Id,
-			)
-			secret, err := r.SecretRepository.Get(ctx, types.NamespacedName{
-				Namespace: context.Namespace,
-				Name:      environment.ValueFromSecret.Name,
-			})
-			if err != nil {
-				if k8sErrors.IsNotFound(err) {
-					logger.Info("Unable to find secret for context environment variable, will retry in 3 seconds.")
-					return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
-				}
-				logger.Error(err, "Error fetching secret for context environment variable.")
-				return ctrl.Result{}, err
-			}
-			if _, keyExist := secret.Data[environment.ValueFromSecret.Key]; !keyExist {
-				err := errors.New("key does not exist for secret")
-				logger.WithValues(
-					logging.SecretKey, environment.ValueFromSecret.Key,
-				).Error(err, "Error fetching mountedFile secret for context.")
-				return ctrl.Result{}, err
-			}
-			valueFromSecret := string(secret.Data[environment.ValueFromSecret.Key])
-			context.Spec.Environment[i].Value = &valueFromSecret
-			context.Spec.Environment[i].Secret = utils.AddressOf(true)
+	var selector labels.Selector
+	if context.Spec.AttachmentSelector != nil {
+		if selector, err = metav1.LabelSelectorAsSelector(context.Spec.AttachmentSelector); err != nil {
+			// The selector can't become valid until the spec is fixed, so there is no point in retrying
+			markFailed(ctx, context, updateStatus, v1beta1.ReasonValidationFailed, errors.Wrap(err, "invalid attachment selector"))
+			return ctrl.Result{}, nil
 		}
 	}
+	attachments, err := stackAttachments(ctx, r.StackRepository, context, selector)
+	if err != nil {
+		logger.Error(err, "Unable to list the stacks to attach the context to")
+		return ctrl.Result{}, err
+	}
+	context.Spec.Attachments = append(context.Spec.Attachments, attachments...)
+	attachedStackIds := stackIds(attachments)
 
-	for i, mountedFile := range context.Spec.MountedFiles {
-		if mountedFile.ValueFromSecret != nil {
-			logger := logger.WithValues(logging.SecretName, mountedFile.ValueFromSecret.Name)
-			secret, err := r.SecretRepository.Get(ctx, types.NamespacedName{
-				Namespace: context.Namespace,
-				Name:      mountedFile.ValueFromSecret.Name,
-			})
-			if err != nil {
-				if k8sErrors.IsNotFound(err) {
-					logger.Info("Unable to find secret for context mounted file, will retry in 3 seconds.")
-					return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
-				}
-				logger.Error(err, "Error fetching secret for context mounted file.")
-				return ctrl.Result{}, err
-			}
-			if _, keyExist := secret.Data[mountedFile.ValueFromSecret.Key]; !keyExist {
-				err := errors.New("key does not exist for secret")
-				logger.WithValues(
-					logging.SecretKey, mountedFile.ValueFromSecret.Key,
-				).Error(err, "Error fetching mounted file secret for context.")
-				return ctrl.Result{}, err
-			}
-			valueFromSecret := string(secret.Data[mountedFile.ValueFromSecret.Key])
-			context.Spec.MountedFiles[i].Value = &valueFromSecret
-			context.Spec.MountedFiles[i].Secret = utils.AddressOf(true)
-		}
+	versions, waiting, err := resolveSecretValues(ctx, r.SecretRepository, context, "context", context.Spec.Environment, context.Spec.MountedFiles, updateStatus)
+	if waiting || err != nil {
+		return ctrl.Result{}, err
 	}
 
-	_, err = r.SpaceliftContextRepository.Get(ctx, context)
+	secretsHash := hashSecretValues(context.Spec.Environment, context.Spec.MountedFiles, versions)
+	adopting := adopt(context, &context.Status.Id)
+
+	spaceliftContext, err := r.SpaceliftContextRepository.Get(ctx, context)
 	if err != nil && !errors.Is(err, spaceliftRepository.ErrContextNotFound) {
-		return ctrl.Result{}, errors.Wrap(err, "unable to retrieve context from spacelift")
+		err = errors.Wrap(err, "unable to retrieve context from spacelift")
+		markFailed(ctx, context, updateStatus, v1beta1.ReasonSpaceliftError, err)
+		return requeueOnError(err)
 	}
 
 	// Context does not exist in Spacelift, let's create it
 	if errors.Is(err, spaceliftRepository.ErrContextNotFound) {
-		return r.handleCreateContext(ctx, context)
+		if v1beta1.AdoptId(context) != "" {
+			return adoptionFailed(ctx, r.EventRecorder, context, &context.Status.Id, updateStatus, "context")
+		}
+		if isDryRun(r.DryRun, context) {
+			reportDryRun(ctx, r.EventRecorder, context, "Context", false, drift.ContextChanges(context, &models.Context{}), updateStatus)
+			return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+		}
+		return r.handleCreateContext(ctx, context, secretsHash, attachedStackIds)
+	}
+
+	if adopting {
+		logger.WithValues(logging.ContextId, context.Status.Id).Info("Context adopted")
+		r.EventRecorder.Eventf(context, v1.EventTypeNormal, v1beta1.EventReasonAdopted, "Context is bound to the existing Spacelift context %s", context.Status.Id)
+	}
+
+	// Once created, create only contexts are left untouched
+	if context.Spec.ManagementPolicy == v1beta1.ManagementPolicyCreateOnly {
+		return r.observeContext(ctx, context, spaceliftContext)
+	}
+
+	if isDryRun(r.DryRun, context) {
+		reportDryRun(ctx, r.EventRecorder, context, "Context", true, drift.ContextChanges(context, spaceliftContext), updateStatus)
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+
+	// Values of secrets can't be read back from spacelift, so a changed secret is only noticed through the hash of its values
+	if isResync(context) && secretsHash != context.Status.SecretsHash {
+		logger.Info("Secret values changed, updating context")
+		return r.handleUpdateContext(ctx, context, secretsHash, attachedStackIds)
+	}
+
+	// Stacks are attached through their labels or their spec without a change to the context generation
+	if isResync(context) && !slices.Equal(attachedStackIds, context.Status.AttachedStackIds) {
+		logger.Info("Attached stacks changed, updating context")
+		return r.handleUpdateContext(ctx, context, secretsHash, attachedStackIds)
+	}
+
+	driftedFields := func() []string { return drift.Context(context, spaceliftContext) }
+	if !handleDrift(ctx, r.EventRecorder, context, context.Spec.DriftPolicy, driftedFields, updateStatus) {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
 	}
 
-	return r.handleUpdateContext(ctx, context)
+	return r.handleUpdateContext(ctx, context, secretsHash, attachedStackIds)
 }
 
-func (r *ContextReconciler) handleCreateContext(ctx context.Context, context *v1beta1.Context) (ctrl.Result, error) {
+func (r *ContextReconciler) handleCreateContext(ctx context.Context, context *v1beta1.Context, secretsHash string, attachedStackIds []string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	spaceliftContext, err := r.SpaceliftContextRepository.Create(ctx, context)
 	if err != nil {
 		logger.Error(err, "Unable to create the context in spacelift")
-		// TODO: Implement better error handling and retry errors that could be retried
-		return ctrl.Result{}, nil
+		return syncFailed(ctx, r.EventRecorder, context, func() error { return r.ContextRepository.UpdateStatus(ctx, context) }, v1beta1.ReasonCreateFailed, err)
 	}
 
 	context.SetContext(spaceliftContext)
+	context.Status.SecretsHash = secretsHash
+	context.Status.AttachedStackIds = attachedStackIds
 	res, err := r.updateContextStatus(ctx, context)
 
 	logger.WithValues(
@@ -223,16 +290,18 @@ func (r *ContextReconciler) handleCreateContext(ctx context.Context, context *v1
 	return res, err
 }
 
-func (r *ContextReconciler) handleUpdateContext(ctx context.Context, context *v1beta1.Context) (ctrl.Result, error) {
+func (r *ContextReconciler) handleUpdateContext(ctx context.Context, context *v1beta1.Context, secretsHash string, attachedStackIds []string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	spaceliftUpdatedContext, err := r.SpaceliftContextRepository.Update(ctx, context)
 	if err != nil {
 		logger.Error(err, "Unable to update the context in spacelift")
-		return ctrl.Result{}, err
+		return syncFailed(ctx, r.EventRecorder, context, func() error { return r.ContextRepository.UpdateStatus(ctx, context) }, v1beta1.ReasonUpdateFailed, err)
 	}
 
 	context.SetContext(spaceliftUpdatedContext)
+	context.Status.SecretsHash = secretsHash
+	context.Status.AttachedStackIds = attachedStackIds
 	res, err := r.updateContextStatus(ctx, context)
 
 	logger.WithValues(
@@ -245,6 +314,8 @@ func (r *ContextReconciler) handleUpdateContext(ctx context.Context, context *v1
 func (r *ContextReconciler) updateContextStatus(ctx context.Context, context *v1beta1.Context) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
+	v1beta1.MarkSynced(context)
+	v1beta1.MarkInSync(context)
 	if err := r.ContextRepository.UpdateStatus(ctx, context); err != nil {
 		if k8sErrors.IsConflict(err) {
 			logger.Info("Conflict on Context status update, let's try again.")
@@ -253,21 +324,83 @@ func (r *ContextReconciler) updateContextStatus(ctx context.Context, context *v1
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// observeContext reports the spacelift context in the status without changing it.
+func (r *ContextReconciler) observeContext(ctx context.Context, context *v1beta1.Context, spaceliftContext *models.Context) (ctrl.Result, error) {
+	context.SetContext(spaceliftContext)
+	observed := &v1beta1.ObservedState{
+		Name:   spaceliftContext.Name,
+		Space:  spaceliftContext.SpaceId,
+		Labels: spaceliftContext.Labels,
+		URL:    spaceliftContext.Url,
+	}
+	updateStatus := func() error { return r.ContextRepository.UpdateStatus(ctx, context) }
+	return observe(ctx, r.EventRecorder, context, "Context", spaceliftContext.Id, observed, updateStatus, r.ResyncPeriod)
+}
+
+func (r *ContextReconciler) handleDeleteContext(ctx context.Context, context *v1beta1.Context) (ctrl.Result, error) {
+	return finalize(ctx, r.EventRecorder, context, context.Spec.DeletionPolicy, spaceliftDeletion{
+		kind:  "Context",
+		id:    context.Status.Id,
+		idKey: logging.ContextId,
+		get: func() error {
+			_, err := r.SpaceliftContextRepository.Get(ctx, context)
+			return err
+		},
+		notFound:        spaceliftRepository.ErrContextNotFound,
+		delete:          func() error { return r.SpaceliftContextRepository.Delete(ctx, context) },
+		removeFinalizer: func() error { return r.ContextRepository.RemoveFinalizer(ctx, context) },
+		deletedReason:   v1beta1.EventReasonContextDeleted,
+		failedReason:    v1beta1.EventReasonContextDeletionFailed,
+	})
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ContextReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &v1beta1.Context{}, spaceNameIndex, indexSpaceName); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Context{}, attachmentStackIndex, indexAttachmentStacks); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Context{}, secretRefIndex, indexSecretRefs); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Context{}, contextIdIndex, indexContextId); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Context{}, attachedStackIdsIndex, indexAttachedStackIds); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Context{}, attachmentSelectorIndex, indexAttachmentSelector); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1beta1.Context{}).
-		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
-		WithEventFilter(predicate.Funcs{
+		For(&v1beta1.Context{}, builder.WithPredicates(predicate.Funcs{
 			// Always handle new resource creation
 			CreateFunc: func(event.CreateEvent) bool { return true },
-			// Always handle resource update
-			UpdateFunc: func(e event.UpdateEvent) bool { return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() },
-			// We don't care about context removal
+			// Always handle resource update, any update while the context is being deleted, and the context being paused or resumed
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+					!e.ObjectNew.GetDeletionTimestamp().IsZero() ||
+					pauseChanged(e)
+			},
+			// Context removal is handled by the finalizer, once the resource is gone there is nothing left to do
 			DeleteFunc: func(event.DeleteEvent) bool { return false },
-		}).
-		Complete(r)
+		})).
+		Watches(&v1beta1.Space{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.ContextList{} }, spaceNameIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1beta1.Stack{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.ContextList{} }, attachmentStackIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1beta1.Stack{}, enqueueAttachedContexts(mgr.GetClient()),
+			builder.WithPredicates(stackAttachmentChanged)).
+		Watches(&v1.Secret{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.ContextList{} }, secretRefIndex),
+			builder.WithPredicates(secretChanged)).
+		Watches(&v1.Namespace{}, enqueueNamespace(mgr.GetClient(), func() client.ObjectList { return &v1beta1.ContextList{} }),
+			builder.WithPredicates(namespacePauseChanged)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: 10}).
+		Complete(tracing.Reconciler("Context", r))
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap/zaptest/observer"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -31,6 +32,7 @@ type ContextControllerTestSuite struct {
 	integration.WithSpaceSuiteHelper
 	integration.WithStackSuiteHelper
 	integration.WithContextSuiteHelper
+	integration.WithEventHelper
 }
 
 func (s *ContextControllerTestSuite) SetupSuite() {
@@ -40,12 +42,15 @@ func (s *ContextControllerTestSuite) SetupSuite() {
 		s.SpaceRepo = repository.NewSpaceRepository(mgr.GetClient())
 		s.SecretRepo = repository.NewSecretRepository(mgr.GetClient())
 		s.FakeSpaceliftContextRepo = new(mocks.ContextRepository)
+		s.NamespaceRepo = repository.NewNamespaceRepository(mgr.GetClient())
 		err := (&controller.ContextReconciler{
 			SpaceliftContextRepository: s.FakeSpaceliftContextRepo,
+			NamespaceRepository:        s.NamespaceRepo,
 			ContextRepository:          s.ContextRepo,
 			StackRepository:            s.StackRepo,
 			SpaceRepository:            s.SpaceRepo,
 			SecretRepository:           s.SecretRepo,
+			EventRecorder:              mgr.GetEventRecorderFor("context-controller"),
 		}).SetupWithManager(mgr)
 		s.Require().NoError(err)
 	}
@@ -58,6 +63,9 @@ func (s *ContextControllerTestSuite) SetupSuite() {
 	s.WithStackSuiteHelper = integration.WithStackSuiteHelper{
 		IntegrationTestSuite: &s.IntegrationTestSuite,
 	}
+	s.WithEventHelper = integration.WithEventHelper{
+		IntegrationTestSuite: &s.IntegrationTestSuite,
+	}
 	s.IntegrationTestSuite.SetupSuite()
 }
 
@@ -299,7 +307,7 @@ func (s *ContextControllerTestSuite) TestContextCreation_OK_SpaceNotReady() {
 
 	var logs *observer.ObservedLogs
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Unable to find space for context, will retry in 10 seconds")
+		logs = s.Logs.FilterMessage("Unable to find space for context, waiting for it to be created")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 	s.Assert().Equal("test-space", logs.All()[0].ContextMap()[logging.SpaceName])
@@ -310,7 +318,7 @@ func (s *ContextControllerTestSuite) TestContextCreation_OK_SpaceNotReady() {
 	defer s.DeleteSpace(space)
 
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Space is not ready, will retry in 3 seconds")
+		logs = s.Logs.FilterMessage("Space is not ready, waiting for it")
 		return logs.Len() == 1
 	}, 12*time.Second, integration.DefaultInterval)
 	s.Assert().Equal("test-space", logs.All()[0].ContextMap()[logging.SpaceName])
@@ -374,7 +382,7 @@ func (s *ContextControllerTestSuite) TestContextCreation_OK_AttachedStackNotRead
 
 	var logs *observer.ObservedLogs
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Unable to find stack for context, will retry in 10 seconds")
+		logs = s.Logs.FilterMessage("Unable to find stack for context, waiting for it to be created")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 	s.Assert().Equal("test-stack", logs.All()[0].ContextMap()[logging.StackName])
@@ -385,7 +393,7 @@ func (s *ContextControllerTestSuite) TestContextCreation_OK_AttachedStackNotRead
 	defer s.DeleteStack(stack)
 
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Stack is not ready, will retry in 3 seconds")
+		logs = s.Logs.FilterMessage("Stack is not ready, waiting for it")
 		return logs.Len() == 1
 	}, 10*time.Second, integration.DefaultInterval)
 	s.Assert().Equal("test-stack", logs.All()[0].ContextMap()[logging.StackName])
@@ -460,11 +468,21 @@ func (s *ContextControllerTestSuite) TestContextCreation_OK_SecretNotReady() {
 
 	var logs *observer.ObservedLogs
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Unable to find secret for context environment variable, will retry in 3 seconds.")
+		logs = s.Logs.FilterMessage("Unable to find secret for context, waiting for it to be created")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 	s.Assert().Equal("test-secret", logs.All()[0].ContextMap()[logging.SecretName])
 
+	s.Require().Eventually(func() bool {
+		context, err := s.ContextRepo.Get(s.Context(), types.NamespacedName{Namespace: c.Namespace, Name: c.ObjectMeta.Name})
+		s.Require().NoError(err)
+		dependencies := meta.FindStatusCondition(context.Status.Conditions, v1beta1.ConditionDependenciesReady)
+		return dependencies != nil &&
+			dependencies.Status == metav1.ConditionFalse &&
+			dependencies.Reason == v1beta1.ReasonSecretNotFound &&
+			dependencies.Message == "Secret test-secret not found"
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
 	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
 		Return(nil, spaceliftRepository.ErrContextNotFound)
 
@@ -510,6 +528,82 @@ func (s *ContextControllerTestSuite) TestContextCreation_OK_SecretNotReady() {
 	s.Assert().True(*contextSpecToCreate.MountedFiles[0].Secret)
 }
 
+func (s *ContextControllerTestSuite) TestContextUpdate_SecretRotated() {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-rotated-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{
+			"test_secret": []byte("secret_value"),
+		},
+		Type: v1.SecretTypeOpaque,
+	}
+	err := s.Client().Create(s.Context(), secret)
+	s.Require().NoError(err)
+	defer s.Client().Delete(s.Context(), secret)
+
+	var updatedValues []string
+	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(&models.Context{Id: "test-context-id"}, nil)
+	s.FakeSpaceliftContextRepo.EXPECT().Update(mock.Anything, mock.Anything).
+		Run(func(_ context.Context, c *v1beta1.Context) {
+			updatedValues = append(updatedValues, *c.Spec.Environment[0].Value)
+		}).Times(2).
+		Return(&models.Context{Id: "test-context-id"}, nil)
+	c := &v1beta1.Context{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Context",
+			APIVersion: v1beta1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-context",
+			Namespace: "default",
+		},
+		Spec: v1beta1.ContextSpec{
+			SpaceId: utils.AddressOf("test-space"),
+			Environment: []v1beta1.Environment{
+				{
+					Id: "test_secret_id",
+					ValueFromSecret: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{
+							Name: "test-rotated-secret",
+						},
+						Key: "test_secret",
+					},
+				},
+			},
+		},
+	}
+
+	s.Logs.TakeAll()
+	err = s.CreateContext(c)
+	s.Require().NoError(err)
+	defer s.DeleteContext(c)
+
+	var hash string
+	s.Require().Eventually(func() bool {
+		context, err := s.ContextRepo.Get(s.Context(), types.NamespacedName{Namespace: c.Namespace, Name: c.ObjectMeta.Name})
+		s.Require().NoError(err)
+		hash = context.Status.SecretsHash
+		return meta.IsStatusConditionTrue(context.Status.Conditions, v1beta1.ConditionReady) && hash != ""
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	secret.Data["test_secret"] = []byte("rotated_value")
+	err = s.Client().Update(s.Context(), secret)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Secret values changed, updating context").Len() == 1 &&
+			s.Logs.FilterMessage("Context updated").Len() == 2
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Equal([]string{"secret_value", "rotated_value"}, updatedValues)
+
+	context, err := s.ContextRepo.Get(s.Context(), types.NamespacedName{Namespace: c.Namespace, Name: c.ObjectMeta.Name})
+	s.Require().NoError(err)
+	s.Assert().NotEqual(hash, context.Status.SecretsHash)
+}
+
 func (s *ContextControllerTestSuite) TestContextCreation_OK() {
 
 	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
@@ -613,6 +707,90 @@ func (s *ContextControllerTestSuite) TestContextUpdate_OK() {
 	s.Assert().Equal("test-context-id", context.Status.Id)
 }
 
+func (s *ContextControllerTestSuite) TestContextAdoption_OK() {
+
+	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.MatchedBy(func(context *v1beta1.Context) bool {
+		return context.Status.Id == "existing-context-id"
+	})).Once().Return(&models.Context{Id: "existing-context-id"}, nil)
+	// Create is not expected on the spacelift repository, the mock would fail otherwise
+	s.FakeSpaceliftContextRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(&models.Context{Id: "existing-context-id"}, nil)
+
+	s.Logs.TakeAll()
+	context := integration.DefaultValidContext
+	context.Annotations = map[string]string{v1beta1.AdoptAnnotation: "existing-context-id"}
+	err := s.CreateContext(&context)
+	s.Require().NoError(err)
+	defer s.DeleteContext(&context)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Context updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	logs := s.Logs.FilterMessage("Context adopted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("existing-context-id", logs.All()[0].ContextMap()[logging.ContextId])
+}
+
+func (s *ContextControllerTestSuite) TestContextDeletion_OK() {
+
+	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrContextNotFound)
+	s.FakeSpaceliftContextRepo.EXPECT().Create(mock.Anything, mock.Anything).Once().
+		Return(&models.Context{Id: "test-context-id"}, nil)
+
+	s.Logs.TakeAll()
+	context := integration.DefaultValidContext
+	context.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	err := s.CreateContext(&context)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Context created").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Context{Id: "test-context-id"}, nil)
+	s.FakeSpaceliftContextRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.DeleteContext(&context)
+
+	logs := s.Logs.FilterMessage("Context deleted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("test-context-id", logs.All()[0].ContextMap()[logging.ContextId])
+	events, err := s.FindEvents(types.NamespacedName{Namespace: context.Namespace, Name: context.ObjectMeta.Name}, v1beta1.EventReasonContextDeleted)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeNormal, events[0].Type)
+}
+
+func (s *ContextControllerTestSuite) TestContextDeletion_AlreadyDeletedOnSpacelift() {
+
+	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Context{Id: "test-context-id"}, nil)
+	s.FakeSpaceliftContextRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(&models.Context{Id: "test-context-id"}, nil)
+
+	s.Logs.TakeAll()
+	context := integration.DefaultValidContext
+	context.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	err := s.CreateContext(&context)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Context updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	// Delete is not expected on the spacelift repository, the mock would fail otherwise
+	s.FakeSpaceliftContextRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrContextNotFound)
+
+	s.DeleteContext(&context)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Context does not exist in spacelift, removing finalizer").Len())
+}
+
 func TestContextController(t *testing.T) {
 	suite.Run(t, new(ContextControllerTestSuite))
 }
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+// spaceliftDeletion describes how the finalizer of a resource deletes it in spacelift.
+type spaceliftDeletion struct {
+	// kind is the kind of the resource used in logs and events, e.g. Context
+	kind string
+	// id is the spacelift ID of the resource, logged with idKey. It is empty when the resource has never been created.
+	id    string
+	idKey string
+	// get looks the resource up in spacelift, it returns notFound when the resource is already gone
+	get      func() error
+	notFound error
+	// wait is called before the resource is deleted in spacelift, the deletion is postponed while it returns true
+	wait   func() (bool, ctrl.Result, error)
+	delete func() error
+	// removeFinalizer releases the resource once it no longer exists in spacelift
+	removeFinalizer             func() error
+	deletedReason, failedReason string
+}
+
+// finalize deletes a resource in spacelift when its deletion policy is Delete, and removes its finalizer once done.
+// Failures are reported as warning events and returned, so the deletion is retried with a backoff.
+func finalize(ctx context.Context, recorder record.EventRecorder, obj client.Object, deletionPolicy v1beta1.DeletionPolicy, d spaceliftDeletion) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	resource := strings.ToLower(d.kind)
+
+	if !controllerutil.ContainsFinalizer(obj, v1beta1.SpaceliftFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if deletionPolicy != v1beta1.DeletionPolicyDelete {
+		logger.Info(fmt.Sprintf("%s deletion policy is not Delete, leaving the %s in spacelift", d.kind, resource))
+		return removeFinalizer(ctx, d.kind, d.removeFinalizer)
+	}
+
+	if d.wait != nil {
+		if waiting, res, err := d.wait(); waiting || err != nil {
+			return res, err
+		}
+	}
+
+	// The resource has never been created in Spacelift, there is nothing to clean up
+	if d.id == "" {
+		return removeFinalizer(ctx, d.kind, d.removeFinalizer)
+	}
+
+	err := d.get()
+	if err != nil && !errors.Is(err, d.notFound) {
+		logger.Error(err, fmt.Sprintf("Unable to retrieve the %s from spacelift", resource))
+		recorder.Event(obj, v1.EventTypeWarning, d.failedReason, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	// The resource is already gone from Spacelift, we just need to release it
+	if errors.Is(err, d.notFound) {
+		logger.Info(fmt.Sprintf("%s does not exist in spacelift, removing finalizer", d.kind))
+		return removeFinalizer(ctx, d.kind, d.removeFinalizer)
+	}
+
+	if err := d.delete(); err != nil {
+		logger.Error(err, fmt.Sprintf("Unable to delete the %s in spacelift", resource))
+		recorder.Event(obj, v1.EventTypeWarning, d.failedReason, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	logger.WithValues(d.idKey, d.id).Info(fmt.Sprintf("%s deleted", d.kind))
+	recorder.Event(obj, v1.EventTypeNormal, d.deletedReason, fmt.Sprintf("%s has been deleted in Spacelift", d.kind))
+
+	return removeFinalizer(ctx, d.kind, d.removeFinalizer)
+}
+
+// removeFinalizer releases a resource deleted in kubernetes, conflicts are retried shortly after.
+func removeFinalizer(ctx context.Context, kind string, remove func() error) (ctrl.Result, error) {
+	if err := remove(); err != nil {
+		if k8sErrors.IsConflict(err) {
+			log.FromContext(ctx).Info(fmt.Sprintf("Conflict on %s finalizer removal, let's try again.", kind))
+			return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+)
+
+// Field indexes listing the resources that depend on a space, a stack or a secret of their namespace.
+// Each reconciler registers the indexes of its own resource, so dependents are reconciled as soon as a dependency is ready
+// instead of polling it. The stack indexes also used by other reconcilers are registered once by repository.IndexStacks.
+const (
+	spaceNameIndex       = ".spec.spaceName"
+	spaceIdIndex         = ".status.id"
+	stackNameIndex       = ".spec.stackName"
+	attachmentStackIndex = ".spec.attachments.stackName"
+	attachedStacksIndex  = ".spec.attachedStacksNames"
+	secretRefIndex       = ".spec.secretRefs"
+	dependsOnIndex       = ".spec.dependsOn.stackName"
+	contextNameIndex     = repository.StackContextNameIndex
+	// Indexes of the contexts looked up from the stacks attached to them
+	contextIdIndex          = ".status.id"
+	attachedStackIdsIndex   = ".status.attachedStackIds"
+	attachmentSelectorIndex = ".spec.attachmentSelector"
+)
+
+// indexField registers a field index on the resources watched by a reconciler.
+func indexField(mgr ctrl.Manager, obj client.Object, index string, extract client.IndexerFunc) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), obj, index, extract); err != nil {
+		return errors.Wrapf(err, "unable to index %T by %s", obj, index)
+	}
+	return nil
+}
+
+// readyObject is a dependency that becomes ready once it has been created in spacelift.
+type readyObject interface {
+	client.Object
+	Ready() bool
+}
+
+// dependencyReady only lets through the dependencies that have just been created, and the ones that just became ready.
+var dependencyReady = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldObj, ok := e.ObjectOld.(readyObject)
+		if !ok {
+			return false
+		}
+		newObj, ok := e.ObjectNew.(readyObject)
+		return ok && !oldObj.Ready() && newObj.Ready()
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+func indexSpaceName(obj client.Object) []string {
+	var spaceName *string
+	switch o := obj.(type) {
+	case *v1beta1.Stack:
+		spaceName = o.Spec.SpaceName
+	case *v1beta1.Context:
+		spaceName = o.Spec.SpaceName
+	case *v1beta1.Policy:
+		spaceName = o.Spec.SpaceName
+	}
+	if spaceName == nil {
+		return nil
+	}
+	return []string{*spaceName}
+}
+
+// indexSpaceId indexes the spaces by their spacelift ID, so the resources referencing a space by ID find it.
+func indexSpaceId(obj client.Object) []string {
+	space, ok := obj.(*v1beta1.Space)
+	if !ok || space.Status.Id == "" {
+		return nil
+	}
+	return []string{space.Status.Id}
+}
+
+func indexStackName(obj client.Object) []string {
+	run, ok := obj.(*v1beta1.Run)
+	if !ok {
+		return nil
+	}
+	return []string{run.Spec.StackName}
+}
+
+func indexAttachmentStacks(obj client.Object) []string {
+	context, ok := obj.(*v1beta1.Context)
+	if !ok {
+		return nil
+	}
+	var stacks []string
+	for _, attachment := range context.Spec.Attachments {
+		if attachment.StackName != nil {
+			stacks = append(stacks, *attachment.StackName)
+		}
+	}
+	return stacks
+}
+
+func indexContextId(obj client.Object) []string {
+	context, ok := obj.(*v1beta1.Context)
+	if !ok || context.Status.Id == "" {
+		return nil
+	}
+	return []string{context.Status.Id}
+}
+
+func indexAttachedStackIds(obj client.Object) []string {
+	context, ok := obj.(*v1beta1.Context)
+	if !ok {
+		return nil
+	}
+	return context.Status.AttachedStackIds
+}
+
+// indexAttachmentSelector indexes the contexts with an attachment selector under "true",
+// so only those are matched against the labels of a stack.
+func indexAttachmentSelector(obj client.Object) []string {
+	context, ok := obj.(*v1beta1.Context)
+	if !ok || context.Spec.AttachmentSelector == nil {
+		return nil
+	}
+	return []string{"true"}
+}
+
+func indexAttachedStacks(obj client.Object) []string {
+	policy, ok := obj.(*v1beta1.Policy)
+	if !ok {
+		return nil
+	}
+	return policy.Spec.AttachedStacksNames
+}
+
+func indexDependsOn(obj client.Object) []string {
+	stack, ok := obj.(*v1beta1.Stack)
+	if !ok {
+		return nil
+	}
+	var stacks []string
+	for _, dependency := range stack.Spec.DependsOn {
+		if dependency.StackName != nil {
+			stacks = append(stacks, *dependency.StackName)
+		}
+	}
+	return stacks
+}
+
+func indexSecretRefs(obj client.Object) []string {
+	var environment []v1beta1.Environment
+	var mountedFiles []v1beta1.MountedFile
+	switch o := obj.(type) {
+	case *v1beta1.Context:
+		environment, mountedFiles = o.Spec.Environment, o.Spec.MountedFiles
+	case *v1beta1.Stack:
+		environment, mountedFiles = o.Spec.Environment, o.Spec.MountedFiles
+	}
+	var secrets []string
+	for _, environment := range environment {
+		if environment.ValueFromSecret != nil {
+			secrets = append(secrets, environment.ValueFromSecret.Name)
+		}
+	}
+	for _, mountedFile := range mountedFiles {
+		if mountedFile.ValueFromSecret != nil {
+			secrets = append(secrets, mountedFile.ValueFromSecret.Name)
+		}
+	}
+	return secrets
+}
+
+// enqueueDependents returns a handler reconciling the resources of the dependency namespace, listed with newList,
+// that reference the dependency by its name in the given field index.
+func enqueueDependents(c client.Client, newList func() client.ObjectList, index string) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, dependency client.Object) []reconcile.Request {
+		return listRequests(ctx, c, newList(),
+			client.InNamespace(dependency.GetNamespace()),
+			client.MatchingFields{index: dependency.GetName()},
+		)
+	})
+}
+
+// enqueueDeletingSpace returns a handler reconciling the space being deleted that contains a stack, context, policy or space,
+// referenced by its name or by its ID, so the space notices when its children are gone.
+func enqueueDeletingSpace(c client.Client) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, child client.Object) []reconcile.Request {
+		var spaceName, spaceId *string
+		switch o := child.(type) {
+		case *v1beta1.Stack:
+			spaceName, spaceId = o.Spec.SpaceName, o.Spec.SpaceId
+		case *v1beta1.Context:
+			spaceName, spaceId = o.Spec.SpaceName, o.Spec.SpaceId
+		case *v1beta1.Policy:
+			spaceName, spaceId = o.Spec.SpaceName, o.Spec.SpaceId
+		case *v1beta1.Space:
+			spaceId = &o.Spec.ParentSpace
+		}
+
+		var spaces v1beta1.SpaceList
+		if spaceName != nil {
+			var space v1beta1.Space
+			err := c.Get(ctx, types.NamespacedName{Namespace: child.GetNamespace(), Name: *spaceName}, &space)
+			if client.IgnoreNotFound(err) != nil {
+				log.FromContext(ctx).Error(err, "Unable to get the space to reconcile")
+				return nil
+			}
+			if err == nil {
+				spaces.Items = append(spaces.Items, space)
+			}
+		}
+		if spaceId != nil && *spaceId != "" {
+			var byId v1beta1.SpaceList
+			if err := c.List(ctx, &byId, client.InNamespace(child.GetNamespace()), client.MatchingFields{spaceIdIndex: *spaceId}); err != nil {
+				log.FromContext(ctx).Error(err, "Unable to list the spaces to reconcile")
+				return nil
+			}
+			spaces.Items = append(spaces.Items, byId.Items...)
+		}
+
+		var requests []reconcile.Request
+		for _, space := range spaces.Items {
+			if !space.DeletionTimestamp.IsZero() {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&space)})
+			}
+		}
+		return requests
+	})
+}
+
+// spaceChildChanged only lets through the children of a space that are gone, and the ones whose spec changed,
+// since a changed deletion policy may unblock the deletion of their space.
+var spaceChildChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration()
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return true },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// listRequests returns a reconcile request for every resource of the list matching the options.
+func listRequests(ctx context.Context, c client.Client, list client.ObjectList, opts ...client.ListOption) []reconcile.Request {
+	if err := c.List(ctx, list, opts...); err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list the resources to reconcile")
+		return nil
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Unable to list the resources to reconcile")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(items))
+	for _, item := range items {
+		if obj, ok := item.(client.Object); ok {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
+)
+
+func Test_indexes(t *testing.T) {
+	stack := &v1beta1.Stack{Spec: v1beta1.StackSpec{SpaceName: utils.AddressOf("space")}}
+	assert.Equal(t, []string{"space"}, indexSpaceName(stack))
+	assert.Empty(t, indexSpaceName(&v1beta1.Stack{}))
+
+	stack.Spec.DependsOn = []v1beta1.StackDependency{
+		{StackName: utils.AddressOf("network")},
+		{StackId: utils.AddressOf("cluster-id")},
+	}
+	assert.Equal(t, []string{"network"}, indexDependsOn(stack))
+
+	stack.Spec.MountedFiles = []v1beta1.MountedFile{
+		{Id: "file", ValueFromSecret: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "stack-secret"}, Key: "file"}},
+	}
+	assert.Equal(t, []string{"stack-secret"}, indexSecretRefs(stack))
+
+	run := &v1beta1.Run{Spec: v1beta1.RunSpec{StackName: "stack"}}
+	assert.Equal(t, []string{"stack"}, indexStackName(run))
+
+	policy := &v1beta1.Policy{Spec: v1beta1.PolicySpec{AttachedStacksNames: []string{"stack-1", "stack-2"}}}
+	assert.Equal(t, []string{"stack-1", "stack-2"}, indexAttachedStacks(policy))
+
+	c := &v1beta1.Context{Spec: v1beta1.ContextSpec{
+		Attachments: []v1beta1.Attachment{
+			{StackName: utils.AddressOf("stack-1")},
+			{StackId: utils.AddressOf("stack-id")},
+		},
+		Environment: []v1beta1.Environment{
+			{Id: "A", Value: utils.AddressOf("a")},
+			{Id: "B", ValueFromSecret: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "secret-1"}, Key: "b"}},
+		},
+		MountedFiles: []v1beta1.MountedFile{
+			{Id: "file", ValueFromSecret: &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "secret-2"}, Key: "file"}},
+		},
+	}}
+	assert.Equal(t, []string{"stack-1"}, indexAttachmentStacks(c))
+	assert.Equal(t, []string{"secret-1", "secret-2"}, indexSecretRefs(c))
+}
+
+func Test_dependencyReady(t *testing.T) {
+	notReady := &v1beta1.Space{}
+	ready := &v1beta1.Space{Status: v1beta1.SpaceStatus{Id: "space-id"}}
+
+	assert.True(t, dependencyReady.Create(event.CreateEvent{Object: notReady}))
+	assert.True(t, dependencyReady.Update(event.UpdateEvent{ObjectOld: notReady, ObjectNew: ready}))
+	assert.False(t, dependencyReady.Update(event.UpdateEvent{ObjectOld: ready, ObjectNew: ready}))
+	assert.False(t, dependencyReady.Update(event.UpdateEvent{ObjectOld: notReady, ObjectNew: notReady}))
+	assert.False(t, dependencyReady.Update(event.UpdateEvent{ObjectOld: &v1.Secret{}, ObjectNew: &v1.Secret{}}))
+	assert.False(t, dependencyReady.Delete(event.DeleteEvent{Object: ready}))
+}
+
+func Test_enqueueDependents(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+	stack := func(namespace, name, spaceName string) *v1beta1.Stack {
+		return &v1beta1.Stack{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       v1beta1.StackSpec{SpaceName: utils.AddressOf(spaceName)},
+		}
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			stack("default", "stack-1", "space"),
+			stack("default", "stack-2", "other-space"),
+			stack("other", "stack-3", "space"),
+		).
+		WithIndex(&v1beta1.Stack{}, spaceNameIndex, indexSpaceName).
+		Build()
+
+	handler := enqueueDependents(k8sClient, func() client.ObjectList { return &v1beta1.StackList{} }, spaceNameIndex)
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	space := &v1beta1.Space{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "space"}}
+	handler.Create(context.Background(), event.CreateEvent{Object: space}, queue)
+
+	require.Equal(t, 1, queue.Len())
+	request, _ := queue.Get()
+	assert.Equal(t, types.NamespacedName{Namespace: "default", Name: "stack-1"}, request.NamespacedName)
+}
+
+func Test_enqueueDeletingSpace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+	space := func(name, id string, deleting bool) *v1beta1.Space {
+		space := &v1beta1.Space{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Status:     v1beta1.SpaceStatus{Id: id},
+		}
+		if deleting {
+			space.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			space.Finalizers = []string{v1beta1.SpaceliftFinalizer}
+		}
+		return space
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			space("space", "space-id", true),
+			space("parent", "parent-id", true),
+			space("other", "other-id", false),
+		).
+		WithIndex(&v1beta1.Space{}, spaceIdIndex, indexSpaceId).
+		Build()
+
+	testCases := []struct {
+		name     string
+		child    client.Object
+		expected []string
+	}{
+		{
+			name:     "stack referencing the space by name",
+			child:    &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.StackSpec{SpaceName: utils.AddressOf("space")}},
+			expected: []string{"space"},
+		},
+		{
+			name:     "context referencing the space by ID",
+			child:    &v1beta1.Context{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.ContextSpec{SpaceId: utils.AddressOf("parent-id")}},
+			expected: []string{"parent"},
+		},
+		{
+			name:  "policy of a space that is not deleted",
+			child: &v1beta1.Policy{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.PolicySpec{SpaceName: utils.AddressOf("other")}},
+		},
+		{
+			name:     "child space",
+			child:    &v1beta1.Space{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.SpaceSpec{ParentSpace: "parent-id"}},
+			expected: []string{"parent"},
+		},
+		{
+			name:  "space of another namespace",
+			child: &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "other"}, Spec: v1beta1.StackSpec{SpaceId: utils.AddressOf("space-id")}},
+		},
+	}
+
+	handler := enqueueDeletingSpace(k8sClient)
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+			defer queue.ShutDown()
+			handler.Delete(context.Background(), event.DeleteEvent{Object: testCase.child}, queue)
+
+			var actual []string
+			for queue.Len() > 0 {
+				request, _ := queue.Get()
+				actual = append(actual, request.Name)
+				queue.Done(request)
+			}
+			assert.Equal(t, testCase.expected, actual)
+		})
+	}
+}
+
+func Test_spaceChildChanged(t *testing.T) {
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Generation: 1}}
+	changed := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+
+	assert.False(t, spaceChildChanged.Create(event.CreateEvent{Object: stack}))
+	assert.True(t, spaceChildChanged.Update(event.UpdateEvent{ObjectOld: stack, ObjectNew: changed}))
+	assert.False(t, spaceChildChanged.Update(event.UpdateEvent{ObjectOld: stack, ObjectNew: stack}))
+	assert.True(t, spaceChildChanged.Delete(event.DeleteEvent{Object: stack}))
+}
@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"context"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+)
+
+// isResync returns true when the current spec has already been applied to spacelift,
+// meaning the reconciliation comes from the resync period and not from a spec change, a previous failure or a pause.
+func isResync(obj v1beta1.ConditionedObject) bool {
+	ready := meta.FindStatusCondition(*obj.StatusConditions(), v1beta1.ConditionReady)
+	return ready != nil && ready.Status == metav1.ConditionTrue && ready.ObservedGeneration == obj.GetGeneration() &&
+		meta.FindStatusCondition(*obj.StatusConditions(), v1beta1.ConditionPaused) == nil
+}
+
+// handleDrift compares a resynced resource with spacelift according to its drift policy.
+// It returns true when the spec has to be applied to spacelift.
+func handleDrift(
+	ctx context.Context,
+	recorder record.EventRecorder,
+	obj v1beta1.ConditionedObject,
+	policy v1beta1.DriftPolicy,
+	driftedFields func() []string,
+	updateStatus func() error,
+) bool {
+	if !isResync(obj) {
+		return true
+	}
+	if policy == v1beta1.DriftPolicyIgnore {
+		return false
+	}
+
+	logger := log.FromContext(ctx)
+	alreadyDrifted := meta.IsStatusConditionTrue(*obj.StatusConditions(), v1beta1.ConditionDrifted)
+	fields := driftedFields()
+	if len(fields) == 0 {
+		if alreadyDrifted {
+			logger.Info("Resource is back in sync with spacelift")
+			v1beta1.MarkInSync(obj)
+			persistConditions(ctx, updateStatus)
+		}
+		return false
+	}
+
+	logger = logger.WithValues(logging.DriftFields, fields)
+	if policy == v1beta1.DriftPolicyReport {
+		logger.Info("Drift detected")
+		if !alreadyDrifted {
+			recorder.Eventf(obj, v1.EventTypeWarning, v1beta1.EventReasonDriftDetected,
+				"Fields changed in Spacelift: %s", strings.Join(fields, ", "))
+		}
+		v1beta1.MarkDrifted(obj, fields)
+		persistConditions(ctx, updateStatus)
+		return false
+	}
+
+	logger.Info("Drift detected, applying the spec again")
+	recorder.Eventf(obj, v1.EventTypeNormal, v1beta1.EventReasonDriftCorrected,
+		"Fields changed in Spacelift have been reverted: %s", strings.Join(fields, ", "))
+	return true
+}
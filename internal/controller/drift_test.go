@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+func syncedStack(policy v1beta1.DriftPolicy) *v1beta1.Stack {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack", Generation: 2},
+		Spec:       v1beta1.StackSpec{DriftPolicy: policy},
+	}
+	v1beta1.MarkSynced(stack)
+	v1beta1.MarkInSync(stack)
+	return stack
+}
+
+func Test_handleDrift(t *testing.T) {
+	drifted := func() []string { return []string{"branch", "labels"} }
+	inSync := func() []string { return nil }
+
+	tests := []struct {
+		name          string
+		stack         func() *v1beta1.Stack
+		driftedFields func() []string
+		wantApply     bool
+		wantDrifted   bool
+		wantEvents    int
+		wantUpdates   int
+	}{
+		{
+			name: "spec changed",
+			stack: func() *v1beta1.Stack {
+				stack := syncedStack(v1beta1.DriftPolicyReport)
+				stack.Generation = 3
+				return stack
+			},
+			driftedFields: drifted,
+			wantApply:     true,
+		},
+		{
+			name: "previous sync failed",
+			stack: func() *v1beta1.Stack {
+				stack := syncedStack(v1beta1.DriftPolicyReport)
+				v1beta1.MarkSyncFailed(stack, v1beta1.ReasonUpdateFailed, "boom")
+				return stack
+			},
+			driftedFields: drifted,
+			wantApply:     true,
+		},
+		{
+			name:          "correct",
+			stack:         func() *v1beta1.Stack { return syncedStack(v1beta1.DriftPolicyCorrect) },
+			driftedFields: drifted,
+			wantApply:     true,
+			wantEvents:    1,
+		},
+		{
+			name:          "correct by default",
+			stack:         func() *v1beta1.Stack { return syncedStack("") },
+			driftedFields: drifted,
+			wantApply:     true,
+			wantEvents:    1,
+		},
+		{
+			name:          "correct in sync",
+			stack:         func() *v1beta1.Stack { return syncedStack(v1beta1.DriftPolicyCorrect) },
+			driftedFields: inSync,
+		},
+		{
+			name:          "report",
+			stack:         func() *v1beta1.Stack { return syncedStack(v1beta1.DriftPolicyReport) },
+			driftedFields: drifted,
+			wantDrifted:   true,
+			wantEvents:    1,
+			wantUpdates:   1,
+		},
+		{
+			name: "report already drifted",
+			stack: func() *v1beta1.Stack {
+				stack := syncedStack(v1beta1.DriftPolicyReport)
+				v1beta1.MarkDrifted(stack, []string{"branch", "labels"})
+				return stack
+			},
+			driftedFields: drifted,
+			wantDrifted:   true,
+			wantUpdates:   1,
+		},
+		{
+			name: "report back in sync",
+			stack: func() *v1beta1.Stack {
+				stack := syncedStack(v1beta1.DriftPolicyReport)
+				v1beta1.MarkDrifted(stack, []string{"branch"})
+				return stack
+			},
+			driftedFields: inSync,
+			wantUpdates:   1,
+		},
+		{
+			name:  "ignore",
+			stack: func() *v1beta1.Stack { return syncedStack(v1beta1.DriftPolicyIgnore) },
+			driftedFields: func() []string {
+				t.Fatal("drift must not be computed when ignored")
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := tt.stack()
+			recorder := record.NewFakeRecorder(10)
+			updates := 0
+			updateStatus := func() error {
+				updates++
+				return nil
+			}
+
+			apply := handleDrift(context.Background(), recorder, stack, stack.Spec.DriftPolicy, tt.driftedFields, updateStatus)
+
+			assert.Equal(t, tt.wantApply, apply)
+			assert.Len(t, recorder.Events, tt.wantEvents)
+			assert.Equal(t, tt.wantUpdates, updates)
+			assert.Equal(t, tt.wantDrifted, meta.IsStatusConditionTrue(stack.Status.Conditions, v1beta1.ConditionDrifted))
+			if tt.wantDrifted {
+				condition := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionDrifted)
+				require.NotNil(t, condition)
+				assert.Equal(t, "Fields changed in Spacelift: branch, labels", condition.Message)
+			}
+		})
+	}
+}
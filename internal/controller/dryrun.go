@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/drift"
+)
+
+// isDryRun returns true when the changes to the resource must be reported instead of being applied to spacelift,
+// either because the operator runs with --dry-run or because the resource has the dry-run annotation.
+func isDryRun(global bool, obj v1beta1.DryRunObject) bool {
+	return global || v1beta1.IsDryRun(obj)
+}
+
+// reportDryRun records the changes that would be applied to spacelift in the resource status.
+// An event is only sent when the changes differ from the ones already reported, so resyncs don't flood the resource events.
+func reportDryRun(
+	ctx context.Context,
+	recorder record.EventRecorder,
+	obj v1beta1.DryRunObject,
+	kind string,
+	exists bool,
+	changes []v1beta1.FieldChange,
+	updateStatus func() error,
+) {
+	fields := drift.Fields(changes)
+	log.FromContext(ctx).WithValues(logging.DryRunFields, fields).Info("Dry run, changes are not applied to spacelift")
+
+	planned := obj.StatusPlannedChanges()
+	if !slices.Equal(*planned, changes) && len(changes) > 0 {
+		if exists {
+			recorder.Eventf(obj, v1.EventTypeNormal, v1beta1.EventReasonDryRun,
+				"%s would be updated in Spacelift: %s", kind, strings.Join(fields, ", "))
+		} else {
+			recorder.Eventf(obj, v1.EventTypeNormal, v1beta1.EventReasonDryRun,
+				"%s would be created in Spacelift with %s", kind, strings.Join(fields, ", "))
+		}
+	}
+	*planned = changes
+	v1beta1.MarkDryRun(obj, fields)
+	persistConditions(ctx, updateStatus)
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+func Test_reportDryRun(t *testing.T) {
+	changes := []v1beta1.FieldChange{
+		{Field: "branch", Current: "main", Desired: "feature"},
+		{Field: "labels", Desired: "a,b"},
+	}
+
+	tests := []struct {
+		name        string
+		planned     []v1beta1.FieldChange
+		changes     []v1beta1.FieldChange
+		wantReason  string
+		wantMessage string
+		wantEvents  int
+	}{
+		{
+			name:        "new changes",
+			changes:     changes,
+			wantReason:  v1beta1.ReasonChangesPending,
+			wantMessage: "Fields to change in Spacelift: branch, labels",
+			wantEvents:  1,
+		},
+		{
+			name:        "changes already reported",
+			planned:     changes,
+			changes:     changes,
+			wantReason:  v1beta1.ReasonChangesPending,
+			wantMessage: "Fields to change in Spacelift: branch, labels",
+		},
+		{
+			name:        "no changes",
+			planned:     changes,
+			wantReason:  v1beta1.ReasonNoChanges,
+			wantMessage: "The resource matches Spacelift",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stack := &v1beta1.Stack{
+				ObjectMeta: metav1.ObjectMeta{Name: "stack", Generation: 1},
+				Status:     v1beta1.StackStatus{PlannedChanges: tt.planned},
+			}
+			recorder := record.NewFakeRecorder(10)
+			updates := 0
+			updateStatus := func() error {
+				updates++
+				return nil
+			}
+
+			reportDryRun(context.Background(), recorder, stack, "Stack", true, tt.changes, updateStatus)
+
+			assert.Equal(t, tt.changes, stack.Status.PlannedChanges)
+			assert.Len(t, recorder.Events, tt.wantEvents)
+			assert.Equal(t, 1, updates)
+			condition := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionDryRun)
+			require.NotNil(t, condition)
+			assert.Equal(t, metav1.ConditionTrue, condition.Status)
+			assert.Equal(t, tt.wantReason, condition.Reason)
+			assert.Equal(t, tt.wantMessage, condition.Message)
+		})
+	}
+}
+
+func Test_reportDryRun_ClearedOnSync(t *testing.T) {
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "stack"}}
+	reportDryRun(context.Background(), record.NewFakeRecorder(10), stack, "Stack", false,
+		[]v1beta1.FieldChange{{Field: "name", Desired: "stack"}}, func() error { return nil })
+
+	v1beta1.MarkSynced(stack)
+
+	assert.Empty(t, stack.Status.PlannedChanges)
+	assert.Nil(t, meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionDryRun))
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/slug"
+)
+
+// observeId returns the ID of the spacelift resource mirrored by an observed resource.
+// The adopt annotation takes precedence, otherwise the resource is looked up by the slug of its name.
+func observeId(obj client.Object, name string) string {
+	if id := v1beta1.AdoptId(obj); id != "" {
+		return id
+	}
+	return slug.SafeSlug(name)
+}
+
+// observe reports the spacelift resource in the status of a resource that must not be changed by the operator.
+// An event is only sent when the resource starts being observed, so resyncs don't flood the resource events.
+func observe(
+	ctx context.Context,
+	recorder record.EventRecorder,
+	obj v1beta1.ObservedObject,
+	kind, id string,
+	observed *v1beta1.ObservedState,
+	updateStatus func() error,
+	resyncPeriod time.Duration,
+) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	ready := meta.FindStatusCondition(*obj.StatusConditions(), v1beta1.ConditionReady)
+	if ready == nil || ready.Reason != v1beta1.ReasonObserved {
+		logger.Info("Resource observed")
+		recorder.Eventf(obj, v1.EventTypeNormal, v1beta1.EventReasonObserved, "%s mirrors the Spacelift %s %s", kind, kind, id)
+	}
+
+	obj.SetObserved(observed)
+	v1beta1.MarkObserved(obj)
+	if err := updateStatus(); err != nil {
+		if k8sErrors.IsConflict(err) {
+			logger.Info("Conflict on status update, let's try again.")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+}
+
+// observeFailed reports that the spacelift resource to observe does not exist.
+// An observed resource is never created, it is looked up again with a backoff until it exists.
+// The status ID is cleared so the resource is not considered ready by the resources depending on it.
+func observeFailed(ctx context.Context, recorder record.EventRecorder, obj v1beta1.ConditionedObject, statusId *string, updateStatus func() error, kind, id string) (ctrl.Result, error) {
+	err := errors.Errorf("%s %s to observe does not exist in spacelift", kind, id)
+	log.FromContext(ctx).Error(err, "Unable to observe resource")
+	recorder.Event(obj, v1.EventTypeWarning, v1beta1.EventReasonObserveFailed, err.Error())
+	*statusId = ""
+	markFailed(ctx, obj, updateStatus, v1beta1.ReasonObserveFailed, err)
+	return ctrl.Result{}, err
+}
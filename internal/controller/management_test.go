@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+func Test_observeId(t *testing.T) {
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "stack"}}
+	assert.Equal(t, "my-stack", observeId(stack, "My Stack"))
+
+	stack.Annotations = map[string]string{v1beta1.AdoptAnnotation: "existing-stack"}
+	assert.Equal(t, "existing-stack", observeId(stack, "My Stack"))
+}
+
+func Test_observe(t *testing.T) {
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Name: "stack", Generation: 1}}
+	recorder := record.NewFakeRecorder(10)
+	updates := 0
+	updateStatus := func() error {
+		updates++
+		return nil
+	}
+	observed := &v1beta1.ObservedState{
+		Name:   "Stack",
+		Space:  "root",
+		Labels: []string{"a"},
+		State:  "FINISHED",
+		URL:    "https://example.app.spacelift.io/stack/stack",
+	}
+
+	res, err := observe(context.Background(), recorder, stack, "Stack", "stack", observed, updateStatus, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, res.RequeueAfter)
+	assert.Equal(t, observed, stack.Status.Observed)
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, 1, updates)
+	ready := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionReady)
+	require.NotNil(t, ready)
+	assert.Equal(t, metav1.ConditionTrue, ready.Status)
+	assert.Equal(t, v1beta1.ReasonObserved, ready.Reason)
+
+	// Resyncs only refresh the status
+	_, err = observe(context.Background(), recorder, stack, "Stack", "stack", observed, updateStatus, time.Minute)
+	require.NoError(t, err)
+	assert.Len(t, recorder.Events, 1)
+	assert.Equal(t, 2, updates)
+
+	// Switching back to a managed stack clears the observed state
+	v1beta1.MarkSynced(stack)
+	assert.Nil(t, stack.Status.Observed)
+}
+
+func Test_observeFailed(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack"},
+		Status:     v1beta1.StackStatus{Id: "stack"},
+	}
+	recorder := record.NewFakeRecorder(10)
+
+	_, err := observeFailed(context.Background(), recorder, stack, &stack.Status.Id, func() error { return nil }, "stack", "stack")
+	assert.EqualError(t, err, "stack stack to observe does not exist in spacelift")
+	assert.Empty(t, stack.Status.Id)
+	assert.Len(t, recorder.Events, 1)
+	ready := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionReady)
+	require.NotNil(t, ready)
+	assert.Equal(t, metav1.ConditionFalse, ready.Status)
+	assert.Equal(t, v1beta1.ReasonObserveFailed, ready.Reason)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+)
+
+// handlePause reports in the resource status that its reconciliation is paused, by its own paused annotation or by the one of its namespace.
+// Once resumed, the Paused condition becomes false and the next reconciliation applies the spec again, see isResync.
+// It returns true when the reconciliation must stop.
+func handlePause(ctx context.Context, namespaces *repository.NamespaceRepository, obj v1beta1.ConditionedObject, updateStatus func() error) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	var message string
+	if v1beta1.IsPaused(obj) {
+		message = fmt.Sprintf("Reconciliation is paused by the %s annotation", v1beta1.PausedAnnotation)
+	} else {
+		namespacePaused, err := namespaces.IsPaused(ctx, obj.GetNamespace())
+		if err != nil {
+			logger.Error(err, "Unable to retrieve Namespace from kube API.")
+			return false, err
+		}
+		if namespacePaused {
+			message = fmt.Sprintf("Reconciliation is paused by the %s annotation of namespace %s", v1beta1.PausedAnnotation, obj.GetNamespace())
+		}
+	}
+
+	wasPaused := meta.IsStatusConditionTrue(*obj.StatusConditions(), v1beta1.ConditionPaused)
+	if message == "" {
+		if wasPaused {
+			logger.Info("Reconciliation resumed")
+			v1beta1.MarkResumed(obj)
+			persistConditions(ctx, updateStatus)
+		}
+		return false, nil
+	}
+
+	if !wasPaused {
+		logger.Info("Reconciliation paused")
+	}
+	v1beta1.MarkPaused(obj, message)
+	persistConditions(ctx, updateStatus)
+	return true, nil
+}
+
+// pauseChanged returns true when the resource has been paused or resumed by its paused annotation.
+func pauseChanged(e event.UpdateEvent) bool {
+	return v1beta1.IsPaused(e.ObjectOld) != v1beta1.IsPaused(e.ObjectNew)
+}
+
+// namespacePauseChanged only lets through the namespaces that have been paused or resumed.
+var namespacePauseChanged = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	UpdateFunc:  pauseChanged,
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// enqueueNamespace returns a handler reconciling every resource of a namespace, listed with newList.
+// It is used to pause or resume the resources when the paused annotation of their namespace changes.
+func enqueueNamespace(c client.Client, newList func() client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, namespace client.Object) []reconcile.Request {
+		return listRequests(ctx, c, newList(), client.InNamespace(namespace.GetName()))
+	})
+}
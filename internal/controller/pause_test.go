@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+)
+
+func Test_handlePause(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace).Build()
+	namespaces := repository.NewNamespaceRepository(k8sClient)
+
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stack", Generation: 1}}
+	updates := 0
+	updateStatus := func() error {
+		updates++
+		return nil
+	}
+
+	v1beta1.MarkSynced(stack)
+	require.True(t, isResync(stack))
+
+	// Nothing to do for a stack that has never been paused
+	paused, err := handlePause(context.Background(), namespaces, stack, updateStatus)
+	require.NoError(t, err)
+	assert.False(t, paused)
+	assert.Equal(t, 0, updates)
+	assert.Nil(t, meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionPaused))
+
+	// Paused by its own annotation
+	stack.Annotations = map[string]string{v1beta1.PausedAnnotation: "true"}
+	paused, err = handlePause(context.Background(), namespaces, stack, updateStatus)
+	require.NoError(t, err)
+	assert.True(t, paused)
+	assert.Equal(t, 1, updates)
+	assert.True(t, meta.IsStatusConditionTrue(stack.Status.Conditions, v1beta1.ConditionPaused))
+
+	// Paused by the annotation of its namespace
+	stack.Annotations = nil
+	namespace.Annotations = map[string]string{v1beta1.PausedAnnotation: "true"}
+	require.NoError(t, k8sClient.Update(context.Background(), namespace))
+	paused, err = handlePause(context.Background(), namespaces, stack, updateStatus)
+	require.NoError(t, err)
+	assert.True(t, paused)
+	condition := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionPaused)
+	require.NotNil(t, condition)
+	assert.Contains(t, condition.Message, "namespace default")
+
+	// Resumed, the spec must be applied again in full
+	namespace.Annotations = nil
+	require.NoError(t, k8sClient.Update(context.Background(), namespace))
+	paused, err = handlePause(context.Background(), namespaces, stack, updateStatus)
+	require.NoError(t, err)
+	assert.False(t, paused)
+	condition = meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionPaused)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionFalse, condition.Status)
+	assert.Equal(t, v1beta1.ReasonResumed, condition.Reason)
+	assert.False(t, isResync(stack))
+
+	// The condition is removed once synced
+	v1beta1.MarkSynced(stack)
+	assert.Nil(t, meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionPaused))
+}
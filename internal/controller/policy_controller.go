@@ -18,13 +18,18 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"slices"
 	"time"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -33,21 +38,30 @@ import (
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/drift"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 // PolicyReconciler reconciles a Policy object
 type PolicyReconciler struct {
 	PolicyRepository          *repository.PolicyRepository
+	NamespaceRepository       *repository.NamespaceRepository
 	SpaceRepository           *repository.SpaceRepository
 	StackRepository           *repository.StackRepository
 	SpaceliftPolicyRepository spaceliftRepository.PolicyRepository
+	EventRecorder             record.EventRecorder
+	// ResyncPeriod is the delay after which a synced resource is compared with spacelift again, 0 disables it
+	ResyncPeriod time.Duration
+	// DryRun reports the changes to every policy instead of applying them to spacelift
+	DryRun bool
 }
 
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=policies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=policies/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=policies/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -70,6 +84,46 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Nothing is changed in spacelift while the reconciliation is paused, not even deletions
+	updateStatus := func() error { return r.PolicyRepository.UpdateStatus(ctx, policy) }
+	if paused, err := handlePause(ctx, r.NamespaceRepository, policy, updateStatus); paused || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !policy.DeletionTimestamp.IsZero() {
+		return r.handleDeletePolicy(ctx, policy)
+	}
+
+	// The finalizer is only needed when the policy has to be cleaned up in Spacelift.
+	// This must happen before the spec is used as a DTO below, since it updates the whole resource.
+	if policy.Spec.DeletionPolicy == v1beta1.DeletionPolicyDelete {
+		if err := r.PolicyRepository.AddFinalizer(ctx, policy); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Policy finalizer update, let's try again.")
+				return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			logger.Error(err, "Error adding finalizer to policy.")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Observed policies are never created nor updated, so their dependencies don't need to be resolved
+	if policy.Spec.ManagementPolicy == v1beta1.ManagementPolicyObserveOnly {
+		if policy.Status.Id == "" {
+			policy.Status.Id = observeId(policy, policy.Name())
+		}
+		spaceliftPolicy, err := r.SpaceliftPolicyRepository.Get(ctx, policy)
+		if errors.Is(err, spaceliftRepository.ErrPolicyNotFound) {
+			return observeFailed(ctx, r.EventRecorder, policy, &policy.Status.Id, updateStatus, "policy", policy.Status.Id)
+		}
+		if err != nil {
+			err = errors.Wrap(err, "unable to retrieve policy from spacelift")
+			markFailed(ctx, policy, updateStatus, v1beta1.ReasonSpaceliftError, err)
+			return requeueOnError(err)
+		}
+		return r.observePolicy(ctx, policy, spaceliftPolicy)
+	}
+
 	if policy.Spec.SpaceName != nil {
 		logger := logger.WithValues(
 			logging.SpaceName, *policy.Spec.SpaceName,
@@ -79,8 +133,9 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		space, err := r.SpaceRepository.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: *policy.Spec.SpaceName})
 		if err != nil {
 			if k8sErrors.IsNotFound(err) {
-				logger.Info("Unable to find space for policy, will retry in 10 seconds")
-				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+				logger.Info("Unable to find space for policy, waiting for it to be created")
+				markWaiting(ctx, policy, updateStatus, v1beta1.ReasonSpaceNotFound, fmt.Sprintf("Space %s not found", *policy.Spec.SpaceName))
+				return ctrl.Result{}, nil
 			}
 			logger.Error(err, "Error fetching space for policy.")
 			return ctrl.Result{}, err
@@ -94,8 +149,9 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 
 		if !space.Ready() {
-			logger.Info("Space is not ready, will retry in 3 seconds")
-			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+			logger.Info("Space is not ready, waiting for it")
+			markWaiting(ctx, policy, updateStatus, v1beta1.ReasonSpaceNotReady, fmt.Sprintf("Space %s is not ready", *policy.Spec.SpaceName))
+			return ctrl.Result{}, nil
 		}
 		// This set the space ID in the spec object to be reused in the graphql mutation.
 		// We kind of use the policy spec as a DTO here, but since we never update the spec in the controller
@@ -109,15 +165,17 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			stack, err := r.StackRepository.Get(ctx, types.NamespacedName{Namespace: policy.Namespace, Name: stackName})
 			if err != nil {
 				if k8sErrors.IsNotFound(err) {
-					logger.Info("Unable to find attached stack for policy, will retry in 10 seconds")
-					return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+					logger.Info("Unable to find attached stack for policy, waiting for it to be created")
+					markWaiting(ctx, policy, updateStatus, v1beta1.ReasonStackNotFound, fmt.Sprintf("Stack %s not found", stackName))
+					return ctrl.Result{}, nil
 				}
 				logger.Error(err, "Error fetching stack for policy.")
 				return ctrl.Result{}, err
 			}
 			if !stack.Ready() {
-				logger.Info("Stack is not ready, will retry in 3 seconds")
-				return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+				logger.Info("Stack is not ready, waiting for it")
+				markWaiting(ctx, policy, updateStatus, v1beta1.ReasonStackNotReady, fmt.Sprintf("Stack %s is not ready", stackName))
+				return ctrl.Result{}, nil
 			}
 			if !slices.Contains(policy.Spec.AttachedStacksIds, stack.Status.Id) {
 				policy.Spec.AttachedStacksIds = append(policy.Spec.AttachedStacksIds, stack.Status.Id)
@@ -125,26 +183,84 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 	}
 
-	_, err = r.SpaceliftPolicyRepository.Get(ctx, policy)
+	if policy.Spec.StackSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(policy.Spec.StackSelector)
+		if err != nil {
+			// The selector can't become valid until the spec is fixed, so there is no point in retrying
+			markFailed(ctx, policy, updateStatus, v1beta1.ReasonValidationFailed, errors.Wrap(err, "invalid stack selector"))
+			return ctrl.Result{}, nil
+		}
+		stackIds, err := selectedStackIds(ctx, r.StackRepository, policy.Namespace, selector)
+		if err != nil {
+			logger.Error(err, "Unable to list the stacks to attach the policy to")
+			return ctrl.Result{}, err
+		}
+		for _, stackId := range stackIds {
+			if !slices.Contains(policy.Spec.AttachedStacksIds, stackId) {
+				policy.Spec.AttachedStacksIds = append(policy.Spec.AttachedStacksIds, stackId)
+			}
+		}
+	}
+	attachedStacksIds := slices.Sorted(slices.Values(policy.Spec.AttachedStacksIds))
+
+	adopting := adopt(policy, &policy.Status.Id)
+
+	spaceliftPolicy, err := r.SpaceliftPolicyRepository.Get(ctx, policy)
 	if err != nil && !errors.Is(err, spaceliftRepository.ErrPolicyNotFound) {
-		return ctrl.Result{}, errors.Wrap(err, "unable to retrieve policy from spacelift")
+		err = errors.Wrap(err, "unable to retrieve policy from spacelift")
+		markFailed(ctx, policy, updateStatus, v1beta1.ReasonSpaceliftError, err)
+		return requeueOnError(err)
 	}
 
 	if errors.Is(err, spaceliftRepository.ErrPolicyNotFound) {
-		return r.handleCreatePolicy(ctx, policy)
+		if v1beta1.AdoptId(policy) != "" {
+			return adoptionFailed(ctx, r.EventRecorder, policy, &policy.Status.Id, updateStatus, "policy")
+		}
+		if isDryRun(r.DryRun, policy) {
+			reportDryRun(ctx, r.EventRecorder, policy, "Policy", false, drift.PolicyChanges(policy, &models.Policy{}), updateStatus)
+			return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+		}
+		return r.handleCreatePolicy(ctx, policy, attachedStacksIds)
+	}
+
+	if adopting {
+		logger.WithValues(logging.PolicyId, policy.Status.Id).Info("Policy adopted")
+		r.EventRecorder.Eventf(policy, v1.EventTypeNormal, v1beta1.EventReasonAdopted, "Policy is bound to the existing Spacelift policy %s", policy.Status.Id)
+	}
+
+	// Once created, create only policies are left untouched
+	if policy.Spec.ManagementPolicy == v1beta1.ManagementPolicyCreateOnly {
+		return r.observePolicy(ctx, policy, spaceliftPolicy)
+	}
+
+	if isDryRun(r.DryRun, policy) {
+		reportDryRun(ctx, r.EventRecorder, policy, "Policy", true, drift.PolicyChanges(policy, spaceliftPolicy), updateStatus)
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+
+	// Stacks are selected through their labels without a change to the policy generation
+	if isResync(policy) && !slices.Equal(attachedStacksIds, policy.Status.AttachedStacksIds) {
+		logger.Info("Attached stacks changed, updating policy")
+		return r.handleUpdatePolicy(ctx, policy, attachedStacksIds)
 	}
 
-	return r.handleUpdatePolicy(ctx, policy)
+	driftedFields := func() []string { return drift.Policy(policy, spaceliftPolicy) }
+	if !handleDrift(ctx, r.EventRecorder, policy, policy.Spec.DriftPolicy, driftedFields, updateStatus) {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+
+	return r.handleUpdatePolicy(ctx, policy, attachedStacksIds)
 }
 
-func (r *PolicyReconciler) handleCreatePolicy(ctx context.Context, policy *v1beta1.Policy) (ctrl.Result, error) {
+func (r *PolicyReconciler) handleCreatePolicy(ctx context.Context, policy *v1beta1.Policy, attachedStacksIds []string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	spaceliftPolicy, err := r.SpaceliftPolicyRepository.Create(ctx, policy)
 	if err != nil {
 		logger.Error(err, "Unable to create policy in spacelift")
-		return ctrl.Result{}, nil
+		return syncFailed(ctx, r.EventRecorder, policy, func() error { return r.PolicyRepository.UpdateStatus(ctx, policy) }, v1beta1.ReasonCreateFailed, err)
 	}
 
+	policy.Status.AttachedStacksIds = attachedStacksIds
 	res, err := r.updatePolicyStatus(ctx, policy, *spaceliftPolicy)
 
 	logger.WithValues(logging.PolicyId, spaceliftPolicy.Id).Info("Policy created")
@@ -152,15 +268,16 @@ func (r *PolicyReconciler) handleCreatePolicy(ctx context.Context, policy *v1bet
 	return res, err
 }
 
-func (r *PolicyReconciler) handleUpdatePolicy(ctx context.Context, policy *v1beta1.Policy) (ctrl.Result, error) {
+func (r *PolicyReconciler) handleUpdatePolicy(ctx context.Context, policy *v1beta1.Policy, attachedStacksIds []string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	spaceliftUpdatedPolicy, err := r.SpaceliftPolicyRepository.Update(ctx, policy)
 	if err != nil {
 		logger.Error(err, "Unable to update the policy in spacelift")
-		return ctrl.Result{}, err
+		return syncFailed(ctx, r.EventRecorder, policy, func() error { return r.PolicyRepository.UpdateStatus(ctx, policy) }, v1beta1.ReasonUpdateFailed, err)
 	}
 
+	policy.Status.AttachedStacksIds = attachedStacksIds
 	res, err := r.updatePolicyStatus(ctx, policy, *spaceliftUpdatedPolicy)
 
 	logger.WithValues(logging.PolicyId, spaceliftUpdatedPolicy.Id).Info("Policy updated")
@@ -172,6 +289,8 @@ func (r *PolicyReconciler) updatePolicyStatus(ctx context.Context, policy *v1bet
 	logger := log.FromContext(ctx)
 
 	policy.SetPolicy(spaceliftPolicy)
+	v1beta1.MarkSynced(policy)
+	v1beta1.MarkInSync(policy)
 	if err := r.PolicyRepository.UpdateStatus(ctx, policy); err != nil {
 		if k8sErrors.IsConflict(err) {
 			logger.Info("Conflict on Policy status update, let's try again.")
@@ -180,20 +299,68 @@ func (r *PolicyReconciler) updatePolicyStatus(ctx context.Context, policy *v1bet
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// observePolicy reports the spacelift policy in the status without changing it.
+func (r *PolicyReconciler) observePolicy(ctx context.Context, policy *v1beta1.Policy, spaceliftPolicy *models.Policy) (ctrl.Result, error) {
+	policy.SetPolicy(*spaceliftPolicy)
+	observed := &v1beta1.ObservedState{
+		Name:   spaceliftPolicy.Name,
+		Space:  spaceliftPolicy.SpaceId,
+		Labels: spaceliftPolicy.Labels,
+		URL:    spaceliftPolicy.Url,
+	}
+	updateStatus := func() error { return r.PolicyRepository.UpdateStatus(ctx, policy) }
+	return observe(ctx, r.EventRecorder, policy, "Policy", spaceliftPolicy.Id, observed, updateStatus, r.ResyncPeriod)
+}
+
+func (r *PolicyReconciler) handleDeletePolicy(ctx context.Context, policy *v1beta1.Policy) (ctrl.Result, error) {
+	return finalize(ctx, r.EventRecorder, policy, policy.Spec.DeletionPolicy, spaceliftDeletion{
+		kind:  "Policy",
+		id:    policy.Status.Id,
+		idKey: logging.PolicyId,
+		get: func() error {
+			_, err := r.SpaceliftPolicyRepository.Get(ctx, policy)
+			return err
+		},
+		notFound:        spaceliftRepository.ErrPolicyNotFound,
+		delete:          func() error { return r.SpaceliftPolicyRepository.Delete(ctx, policy) },
+		removeFinalizer: func() error { return r.PolicyRepository.RemoveFinalizer(ctx, policy) },
+		deletedReason:   v1beta1.EventReasonPolicyDeleted,
+		failedReason:    v1beta1.EventReasonPolicyDeletionFailed,
+	})
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &v1beta1.Policy{}, spaceNameIndex, indexSpaceName); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Policy{}, attachedStacksIndex, indexAttachedStacks); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1beta1.Policy{}).
-		WithEventFilter(predicate.Funcs{
+		For(&v1beta1.Policy{}, builder.WithPredicates(predicate.Funcs{
 			// Always handle new resource creation
 			CreateFunc: func(event.CreateEvent) bool { return true },
-			// Always handle resource update
-			UpdateFunc: func(e event.UpdateEvent) bool { return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() },
-			// We don't care about policy removal
+			// Always handle resource update, any update while the policy is being deleted, and the policy being paused or resumed
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+					!e.ObjectNew.GetDeletionTimestamp().IsZero() ||
+					pauseChanged(e)
+			},
+			// Policy removal is handled by the finalizer, once the resource is gone there is nothing left to do
 			DeleteFunc: func(event.DeleteEvent) bool { return false },
-		}).
-		Complete(r)
+		})).
+		Watches(&v1beta1.Space{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.PolicyList{} }, spaceNameIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1beta1.Stack{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.PolicyList{} }, attachedStacksIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1beta1.Stack{}, enqueueSelectingPolicies(mgr.GetClient()),
+			builder.WithPredicates(stackAttachmentChanged)).
+		Watches(&v1.Namespace{}, enqueueNamespace(mgr.GetClient(), func() client.ObjectList { return &v1beta1.PolicyList{} }),
+			builder.WithPredicates(namespacePauseChanged)).
+		Complete(tracing.Reconciler("Policy", r))
 }
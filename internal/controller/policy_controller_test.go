@@ -10,6 +10,8 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap/zaptest/observer"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -30,6 +32,7 @@ type PolicyControllerSuite struct {
 	integration.WithPolicySuiteHelper
 	integration.WithSpaceSuiteHelper
 	integration.WithStackSuiteHelper
+	integration.WithEventHelper
 }
 
 func (s *PolicyControllerSuite) SetupSuite() {
@@ -38,11 +41,14 @@ func (s *PolicyControllerSuite) SetupSuite() {
 		s.SpaceRepo = repository.NewSpaceRepository(mgr.GetClient())
 		s.StackRepo = repository.NewStackRepository(mgr.GetClient(), mgr.GetScheme())
 		s.PolicyRepo = repository.NewPolicyRepository(mgr.GetClient(), mgr.GetScheme())
+		s.NamespaceRepo = repository.NewNamespaceRepository(mgr.GetClient())
 		err := (&controller.PolicyReconciler{
 			PolicyRepository:          s.PolicyRepo,
+			NamespaceRepository:       s.NamespaceRepo,
 			SpaceRepository:           s.SpaceRepo,
 			StackRepository:           s.StackRepo,
 			SpaceliftPolicyRepository: s.FakeSpaceliftPolicyRepo,
+			EventRecorder:             mgr.GetEventRecorderFor("policy-controller"),
 		}).SetupWithManager(mgr)
 		s.Require().NoError(err)
 	}
@@ -56,6 +62,9 @@ func (s *PolicyControllerSuite) SetupSuite() {
 	s.WithStackSuiteHelper = integration.WithStackSuiteHelper{
 		IntegrationTestSuite: &s.IntegrationTestSuite,
 	}
+	s.WithEventHelper = integration.WithEventHelper{
+		IntegrationTestSuite: &s.IntegrationTestSuite,
+	}
 }
 
 func (s *PolicyControllerSuite) SetupTest() {
@@ -147,6 +156,18 @@ func (s *PolicyControllerSuite) TestPolicyCreation_UnableToCreateOnSpacelift() {
 	s.Require().Equal(1, logs.Len())
 	logs = s.Logs.FilterMessage("Policy created")
 	s.Require().Equal(0, logs.Len())
+
+	// Check that the failure is reported in the policy conditions
+	policy, err = s.PolicyRepo.Get(s.Context(), types.NamespacedName{
+		Namespace: policy.Namespace,
+		Name:      policy.ObjectMeta.Name,
+	})
+	s.Require().NoError(err)
+	synced := meta.FindStatusCondition(policy.Status.Conditions, v1beta1.ConditionSynced)
+	s.Require().NotNil(synced)
+	s.Assert().Equal(metav1.ConditionFalse, synced.Status)
+	s.Assert().Equal(v1beta1.ReasonCreateFailed, synced.Reason)
+	s.Assert().True(meta.IsStatusConditionTrue(policy.Status.Conditions, v1beta1.ConditionError))
 }
 
 func (s *PolicyControllerSuite) TestPolicyCreation_OK_AttachedStackNotReady() {
@@ -159,7 +180,7 @@ func (s *PolicyControllerSuite) TestPolicyCreation_OK_AttachedStackNotReady() {
 
 	var logs *observer.ObservedLogs
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Unable to find attached stack for policy, will retry in 10 seconds")
+		logs = s.Logs.FilterMessage("Unable to find attached stack for policy, waiting for it to be created")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 	s.Assert().Equal("test-stack", logs.All()[0].ContextMap()[logging.StackName])
@@ -169,7 +190,7 @@ func (s *PolicyControllerSuite) TestPolicyCreation_OK_AttachedStackNotReady() {
 	defer s.DeleteStack(stack)
 
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Stack is not ready, will retry in 3 seconds")
+		logs = s.Logs.FilterMessage("Stack is not ready, waiting for it")
 		return logs.Len() == 1
 	}, 12*time.Second, integration.DefaultInterval)
 	s.Assert().Equal("test-stack", logs.All()[0].ContextMap()[logging.StackName])
@@ -206,7 +227,7 @@ func (s *PolicyControllerSuite) TestPolicyCreation_OK_SpaceNotReady() {
 
 	var logs *observer.ObservedLogs
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Unable to find space for policy, will retry in 10 seconds")
+		logs = s.Logs.FilterMessage("Unable to find space for policy, waiting for it to be created")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 	s.Assert().Equal("test-space", logs.All()[0].ContextMap()[logging.SpaceName])
@@ -216,7 +237,7 @@ func (s *PolicyControllerSuite) TestPolicyCreation_OK_SpaceNotReady() {
 	defer s.DeleteSpace(space)
 
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Space is not ready, will retry in 3 seconds")
+		logs = s.Logs.FilterMessage("Space is not ready, waiting for it")
 		return logs.Len() == 1
 	}, 12*time.Second, integration.DefaultInterval)
 	s.Assert().Equal("test-space", logs.All()[0].ContextMap()[logging.SpaceName])
@@ -329,6 +350,143 @@ func (s *PolicyControllerSuite) TestPolicyUpdate_OK() {
 	s.Assert().Equal("test-policy-id", policy.Status.Id)
 }
 
+func (s *PolicyControllerSuite) TestPolicyUpdate_StackRelabeled() {
+	stack := integration.DefaultValidStack
+	stack.Labels = map[string]string{"tier": "prod"}
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(&stack)
+	stack.Status = integration.DefaultValidStackStatus
+	s.Require().NoError(s.StackRepo.UpdateStatus(s.Context(), &stack))
+
+	s.FakeSpaceliftPolicyRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrPolicyNotFound)
+	var policySpecToCreate v1beta1.PolicySpec
+	s.FakeSpaceliftPolicyRepo.EXPECT().Create(mock.Anything, mock.Anything).
+		Run(func(_ context.Context, p *v1beta1.Policy) {
+			policySpecToCreate = p.Spec
+		}).Once().
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+
+	p := integration.DefaultValidPolicy
+	p.Spec.StackSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "prod"}}
+	s.Require().NoError(s.CreatePolicy(&p))
+	defer s.DeletePolicy(&p)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Policy created").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Contains(policySpecToCreate.AttachedStacksIds, "test-stack-id")
+
+	s.FakeSpaceliftPolicyRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+	var policySpecToUpdate v1beta1.PolicySpec
+	s.FakeSpaceliftPolicyRepo.EXPECT().Update(mock.Anything, mock.Anything).
+		Run(func(_ context.Context, p *v1beta1.Policy) {
+			policySpecToUpdate = p.Spec
+		}).Once().
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+
+	// The stack is not selected anymore, the policy is reconciled to detach it
+	stack.Labels = map[string]string{"tier": "dev"}
+	s.Require().NoError(s.Client().Update(s.Context(), &stack))
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Attached stacks changed, updating policy").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Policy updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().NotContains(policySpecToUpdate.AttachedStacksIds, "test-stack-id")
+}
+
+func (s *PolicyControllerSuite) TestPolicyAdoption_NotFound() {
+
+	s.FakeSpaceliftPolicyRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(nil, spaceliftRepository.ErrPolicyNotFound)
+
+	s.Logs.TakeAll()
+	policy := integration.DefaultValidPolicy
+	policy.Annotations = map[string]string{v1beta1.AdoptAnnotation: "missing-policy-id"}
+	err := s.CreatePolicy(&policy)
+	s.Require().NoError(err)
+	defer s.DeletePolicy(&policy)
+
+	// The policy must not be created in spacelift, the mock would fail otherwise
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Unable to adopt resource").Len() > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	events, err := s.FindEvents(types.NamespacedName{Namespace: policy.Namespace, Name: policy.ObjectMeta.Name}, v1beta1.EventReasonAdoptionFailed)
+	s.Require().NoError(err)
+	s.Require().NotEmpty(events)
+	s.Assert().Equal(v1.EventTypeWarning, events[0].Type)
+}
+
+func (s *PolicyControllerSuite) TestPolicyDeletion_OK() {
+
+	s.FakeSpaceliftPolicyRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrPolicyNotFound)
+	s.FakeSpaceliftPolicyRepo.EXPECT().Create(mock.Anything, mock.Anything).Once().
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+
+	s.Logs.TakeAll()
+	policy := integration.DefaultValidPolicy
+	policy.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	err := s.CreatePolicy(&policy)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Policy created").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.FakeSpaceliftPolicyRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+	s.FakeSpaceliftPolicyRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.Require().NoError(s.DeletePolicy(&policy))
+	s.WaitUntilPolicyRemoved(&policy)
+
+	logs := s.Logs.FilterMessage("Policy deleted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("test-policy-id", logs.All()[0].ContextMap()[logging.PolicyId])
+	events, err := s.FindEvents(types.NamespacedName{Namespace: policy.Namespace, Name: policy.ObjectMeta.Name}, v1beta1.EventReasonPolicyDeleted)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeNormal, events[0].Type)
+}
+
+func (s *PolicyControllerSuite) TestPolicyDeletion_UnableToDeleteOnSpacelift() {
+
+	s.FakeSpaceliftPolicyRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+	s.FakeSpaceliftPolicyRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(&models.Policy{Id: "test-policy-id"}, nil)
+	failedDelete := s.FakeSpaceliftPolicyRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(errors.New("unable to delete resource on spacelift"))
+	s.FakeSpaceliftPolicyRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil).NotBefore(failedDelete)
+
+	s.Logs.TakeAll()
+	policy := integration.DefaultValidPolicy
+	policy.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	err := s.CreatePolicy(&policy)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Policy updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.Require().NoError(s.DeletePolicy(&policy))
+	s.WaitUntilPolicyRemoved(&policy)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Unable to delete the policy in spacelift").Len())
+	events, err := s.FindEvents(types.NamespacedName{Namespace: policy.Namespace, Name: policy.ObjectMeta.Name}, v1beta1.EventReasonPolicyDeletionFailed)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeWarning, events[0].Type)
+}
+
 func TestPolicyController(t *testing.T) {
 	suite.Run(t, new(PolicyControllerSuite))
 }
@@ -18,13 +18,19 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"time"
 
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -34,21 +40,25 @@ import (
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/watcher"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 // RunReconciler reconciles a Run object
 type RunReconciler struct {
 	RunRepository            *repository.RunRepository
+	NamespaceRepository      *repository.NamespaceRepository
 	StackRepository          *repository.StackRepository
 	StackOutputRepository    *repository.StackOutputRepository
 	SpaceliftRunRepository   spaceliftRepository.RunRepository
 	SpaceliftStackRepository spaceliftRepository.StackRepository
 	RunWatcher               *watcher.RunWatcher
+	EventRecorder            record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=runs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=runs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=runs/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -74,12 +84,35 @@ func (r *RunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	logger = logger.WithValues(logging.StackName, run.Spec.StackName)
 	log.IntoContext(ctx, logger)
 
+	// Nothing is changed in spacelift while the reconciliation is paused, not even deletions
+	updateStatus := func() error { return r.RunRepository.UpdateStatus(ctx, run) }
+	if paused, err := handlePause(ctx, r.NamespaceRepository, run, updateStatus); paused || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !run.DeletionTimestamp.IsZero() {
+		return r.handleDeleteRun(ctx, run)
+	}
+
+	// The finalizer is only needed when the run has to be stopped in Spacelift
+	if run.Spec.OnDelete == v1beta1.RunOnDeleteCancel {
+		if err := r.RunRepository.AddFinalizer(ctx, run); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Run finalizer update, let's try again.")
+				return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			logger.Error(err, "Error adding finalizer to run.")
+			return ctrl.Result{}, err
+		}
+	}
+
 	// A run should always be linked to a valid stack
 	stack, err := r.StackRepository.Get(ctx, types.NamespacedName{Namespace: run.Namespace, Name: run.Spec.StackName})
 	if err != nil {
 		if k8sErrors.IsNotFound(err) {
-			logger.Info("Unable to find stack for run, will retry in 10 seconds")
-			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+			logger.Info("Unable to find stack for run, waiting for it to be created")
+			markWaiting(ctx, run, updateStatus, v1beta1.ReasonStackNotFound, fmt.Sprintf("Stack %s not found", run.Spec.StackName))
+			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Error fetching stack for run.")
 		return ctrl.Result{}, err
@@ -94,8 +127,26 @@ func (r *RunReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	if !stack.Ready() {
-		logger.Info("Stack is not ready, will retry in 3 seconds")
-		return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		logger.Info("Stack is not ready, waiting for it")
+		markWaiting(ctx, run, updateStatus, v1beta1.ReasonStackNotReady, fmt.Sprintf("Stack %s is not ready", run.Spec.StackName))
+		return ctrl.Result{}, nil
+	}
+
+	// Runs live in the account of their stack, it is recorded so runs can still be stopped once their stack is gone
+	if !reflect.DeepEqual(run.Status.AccountRef, stack.Spec.AccountRef) {
+		run.Status.AccountRef = stack.Spec.AccountRef.DeepCopy()
+		if err := updateStatus(); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Run status update, let's try again.")
+				return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Observed runs are never triggered, they are bound to an existing run and watched like any other run
+	if run.IsNew() && run.Spec.ManagementPolicy == v1beta1.ManagementPolicyObserveOnly {
+		return r.observeRun(ctx, run, stack)
 	}
 
 	// If the run is new, then create it on spacelift and update the status
@@ -111,7 +162,7 @@ func (r *RunReconciler) handleNewRun(ctx context.Context, run *v1beta1.Run, stac
 	spaceliftRun, err := r.SpaceliftRunRepository.Create(ctx, stack)
 	if err != nil {
 		logger.Error(err, "Unable to create the run in spacelift")
-		return ctrl.Result{}, nil
+		return syncFailed(ctx, r.EventRecorder, run, func() error { return r.RunRepository.UpdateStatus(ctx, run) }, v1beta1.ReasonCreateFailed, err)
 	}
 
 	// Set initial annotations when a run is created
@@ -128,6 +179,7 @@ func (r *RunReconciler) handleNewRun(ctx context.Context, run *v1beta1.Run, stac
 	}
 
 	run.SetRun(spaceliftRun)
+	v1beta1.MarkSynced(run)
 	if err := r.RunRepository.UpdateStatus(ctx, run); err != nil {
 		if k8sErrors.IsConflict(err) {
 			logger.Info("Conflict on Run status update, let's try again.")
@@ -145,6 +197,53 @@ func (r *RunReconciler) handleNewRun(ctx context.Context, run *v1beta1.Run, stac
 	return ctrl.Result{}, nil
 }
 
+// observeRun binds the run to the spacelift run set in its adopt annotation and reports its state.
+func (r *RunReconciler) observeRun(ctx context.Context, run *v1beta1.Run, stack *v1beta1.Stack) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	updateStatus := func() error { return r.RunRepository.UpdateStatus(ctx, run) }
+
+	runId := v1beta1.AdoptId(run)
+	if runId == "" {
+		err := errors.Errorf("the ID of the run to observe must be set in the %s annotation", v1beta1.AdoptAnnotation)
+		logger.Error(err, "Unable to observe run")
+		r.EventRecorder.Event(run, v1.EventTypeWarning, v1beta1.EventReasonObserveFailed, err.Error())
+		markFailed(ctx, run, updateStatus, v1beta1.ReasonObserveFailed, err)
+		return ctrl.Result{}, nil
+	}
+
+	run.Status.Id = runId
+	run.Status.StackId = stack.Status.Id
+	spaceliftRun, err := r.SpaceliftRunRepository.Get(ctx, run)
+	if errors.Is(err, spaceliftRepository.ErrRunNotFound) {
+		return observeFailed(ctx, r.EventRecorder, run, &run.Status.Id, updateStatus, "run", runId)
+	}
+	if err != nil {
+		err = errors.Wrap(err, "unable to retrieve run from spacelift")
+		markFailed(ctx, run, updateStatus, v1beta1.ReasonSpaceliftError, err)
+		return requeueOnError(err)
+	}
+
+	// The status update triggers a new reconciliation, which starts watching the run
+	run.SetRun(spaceliftRun)
+	v1beta1.MarkObserved(run)
+	if err := updateStatus(); err != nil {
+		if k8sErrors.IsConflict(err) {
+			logger.Info("Conflict on Run status update, let's try again.")
+			return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	logger.WithValues(
+		logging.RunState, run.Status.State,
+		logging.RunId, run.Status.Id,
+		logging.StackId, run.Status.StackId,
+	).Info("Run observed")
+	r.EventRecorder.Eventf(run, v1.EventTypeNormal, v1beta1.EventReasonObserved, "Run mirrors the Spacelift run %s", run.Status.Id)
+
+	return ctrl.Result{}, nil
+}
+
 func (r *RunReconciler) handleRunUpdate(ctx context.Context, run *v1beta1.Run, stack *v1beta1.Stack) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
@@ -189,21 +288,85 @@ func (r *RunReconciler) updateStackOutputSecret(ctx context.Context, run *v1beta
 	return ctrl.Result{}, nil
 }
 
+// handleDeleteRun makes sure the run will not change any infrastructure before releasing the resource.
+// Only queued runs can be canceled and only unconfirmed runs can be discarded, runs in any other
+// non terminal state are watched until they reach one of those states.
+func (r *RunReconciler) handleDeleteRun(ctx context.Context, run *v1beta1.Run) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues(
+		logging.RunId, run.Status.Id,
+		logging.RunState, run.Status.State,
+	)
+
+	if !controllerutil.ContainsFinalizer(run, v1beta1.SpaceliftFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if run.Spec.OnDelete != v1beta1.RunOnDeleteCancel || run.IsNew() || run.IsTerminated() {
+		return r.removeRunFinalizer(ctx, run)
+	}
+
+	switch run.Status.State {
+	case v1beta1.RunStateQueued:
+		if err := r.SpaceliftRunRepository.Cancel(ctx, run); err != nil {
+			logger.Error(err, "Unable to cancel the run in spacelift")
+			r.EventRecorder.Event(run, v1.EventTypeWarning, v1beta1.EventReasonRunCancellationFailed, err.Error())
+			return ctrl.Result{}, err
+		}
+		logger.Info("Run canceled")
+		r.EventRecorder.Event(run, v1.EventTypeNormal, v1beta1.EventReasonRunCanceled, "Run has been canceled in Spacelift")
+	case v1beta1.RunStateUnconfirmed:
+		if err := r.SpaceliftRunRepository.Discard(ctx, run); err != nil {
+			logger.Error(err, "Unable to discard the run in spacelift")
+			r.EventRecorder.Event(run, v1.EventTypeWarning, v1beta1.EventReasonRunCancellationFailed, err.Error())
+			return ctrl.Result{}, err
+		}
+		logger.Info("Run discarded")
+		r.EventRecorder.Event(run, v1.EventTypeNormal, v1beta1.EventReasonRunDiscarded, "Run has been discarded in Spacelift")
+	default:
+		// The run watcher keeps updating the status, which triggers a new reconciliation
+		logger.Info("Run can't be stopped in its current state, waiting for it to change")
+		if !r.RunWatcher.IsWatched(run) {
+			if err := r.RunWatcher.Start(ctx, run); err != nil {
+				logger.Error(err, "Cannot start run watcher")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	return r.removeRunFinalizer(ctx, run)
+}
+
+func (r *RunReconciler) removeRunFinalizer(ctx context.Context, run *v1beta1.Run) (ctrl.Result, error) {
+	return removeFinalizer(ctx, "Run", func() error { return r.RunRepository.RemoveFinalizer(ctx, run) })
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *RunReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &v1beta1.Run{}, stackNameIndex, indexStackName); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1beta1.Run{}).
-		WithEventFilter(predicate.Funcs{
+		For(&v1beta1.Run{}, builder.WithPredicates(predicate.Funcs{
 			// Always handle new resource creation
 			CreateFunc: func(event.CreateEvent) bool { return true },
-			// Let's consider run immutables and only care about update on the status
+			// Let's consider run immutables and only care about update on the status, or when the run is being deleted, paused or resumed
 			UpdateFunc: func(e event.UpdateEvent) bool {
 				oldRun, _ := e.ObjectOld.(*v1beta1.Run)
 				newRun, _ := e.ObjectNew.(*v1beta1.Run)
-				return !reflect.DeepEqual(oldRun.Status, newRun.Status)
+				// Conditions only report the outcome of a reconciliation, they must not trigger a new one
+				oldStatus, newStatus := oldRun.Status, newRun.Status
+				oldStatus.Conditions, newStatus.Conditions = nil, nil
+				oldStatus.ObservedGeneration, newStatus.ObservedGeneration = 0, 0
+				return !reflect.DeepEqual(oldStatus, newStatus) || !newRun.DeletionTimestamp.IsZero() || pauseChanged(e)
 			},
-			// We don't care about run removal
+			// Run removal is handled by the finalizer, once the resource is gone there is nothing left to do
 			DeleteFunc: func(event.DeleteEvent) bool { return false },
-		}).
-		Complete(r)
+		})).
+		Watches(&v1beta1.Stack{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.RunList{} }, stackNameIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1.Namespace{}, enqueueNamespace(mgr.GetClient(), func() client.ObjectList { return &v1beta1.RunList{} }),
+			builder.WithPredicates(namespacePauseChanged)).
+		Complete(tracing.Reconciler("Run", r))
 }
@@ -8,7 +8,9 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap/zaptest/observer"
+	v1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -27,6 +29,7 @@ type RunControllerSuite struct {
 	integration.IntegrationTestSuite
 	integration.WithRunSuiteHelper
 	integration.WithStackSuiteHelper
+	integration.WithEventHelper
 }
 
 func (s *RunControllerSuite) SetupSuite() {
@@ -36,14 +39,17 @@ func (s *RunControllerSuite) SetupSuite() {
 		s.FakeSpaceliftRunRepo = new(mocks.RunRepository)
 		s.FakeSpaceliftStackRepo = new(mocks.StackRepository)
 		s.StackRepo = repository.NewStackRepository(mgr.GetClient(), mgr.GetScheme())
-		w := watcher.NewRunWatcher(s.RunRepo, s.FakeSpaceliftRunRepo)
+		s.NamespaceRepo = repository.NewNamespaceRepository(mgr.GetClient())
+		w := watcher.NewRunWatcher(s.RunRepo, s.StackRepo, s.NamespaceRepo, s.FakeSpaceliftRunRepo)
 		err := (&controller.RunReconciler{
 			RunRepository:            s.RunRepo,
+			NamespaceRepository:      s.NamespaceRepo,
 			StackRepository:          s.StackRepo,
 			StackOutputRepository:    stackOutputRepo,
 			SpaceliftRunRepository:   s.FakeSpaceliftRunRepo,
 			SpaceliftStackRepository: s.FakeSpaceliftStackRepo,
 			RunWatcher:               w,
+			EventRecorder:            mgr.GetEventRecorderFor("run-controller"),
 		}).SetupWithManager(mgr)
 		s.Require().NoError(err)
 	}
@@ -54,6 +60,9 @@ func (s *RunControllerSuite) SetupSuite() {
 	s.WithStackSuiteHelper = integration.WithStackSuiteHelper{
 		IntegrationTestSuite: &s.IntegrationTestSuite,
 	}
+	s.WithEventHelper = integration.WithEventHelper{
+		IntegrationTestSuite: &s.IntegrationTestSuite,
+	}
 }
 
 func (s *RunControllerSuite) SetupTest() {
@@ -140,29 +149,17 @@ func (s *RunControllerSuite) TestRunCreation_OK() {
 	// This is working by matching the run state with a mock argument matcher, then returns
 	// the next state.
 	s.FakeSpaceliftRunRepo.EXPECT().
-		Get(mock.Anything, mock.MatchedBy(func(run *v1beta1.Run) bool {
-			return run.Status.State == v1beta1.RunStateQueued
-		})).
-		Once().
-		Return(&models.Run{
-			State: "READY",
-		}, nil)
+		GetStates(mock.Anything, mock.Anything, integration.RunsInState(v1beta1.RunStateQueued)).
+		RunAndReturn(integration.ReturnRunStates("READY")).
+		Once()
 	s.FakeSpaceliftRunRepo.EXPECT().
-		Get(mock.Anything, mock.MatchedBy(func(run *v1beta1.Run) bool {
-			return run.Status.State == "READY"
-		})).
-		Once().
-		Return(&models.Run{
-			State: "APPLYING",
-		}, nil)
+		GetStates(mock.Anything, mock.Anything, integration.RunsInState("READY")).
+		RunAndReturn(integration.ReturnRunStates("APPLYING")).
+		Once()
 	s.FakeSpaceliftRunRepo.EXPECT().
-		Get(mock.Anything, mock.MatchedBy(func(run *v1beta1.Run) bool {
-			return run.Status.State == "APPLYING"
-		})).
-		Once().
-		Return(&models.Run{
-			State: string(v1beta1.RunStateFinished),
-		}, nil)
+		GetStates(mock.Anything, mock.Anything, integration.RunsInState("APPLYING")).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFinished)).
+		Once()
 
 	stack, err := s.CreateTestStackWithStatus()
 	s.Require().NoError(err)
@@ -187,6 +184,7 @@ func (s *RunControllerSuite) TestRunCreation_OK() {
 	// Assert that the Queued state has been applied
 	run = s.AssertRunState(run, "READY")
 	s.Require().NotNil(run.Annotations)
+	s.Assert().True(meta.IsStatusConditionTrue(run.Status.Conditions, v1beta1.ConditionReady))
 	s.Assert().Equal("http://example.com/test", run.Annotations[v1beta1.ArgoExternalLink])
 
 	// Assert that the state has been changed by the watcher
@@ -213,13 +211,9 @@ func (s *RunControllerSuite) TestRunCreation_OK_WithCreateSecretFromStackOutput(
 	// We don't really need a real scenario here, we just want to check that secrets are created
 
 	s.FakeSpaceliftRunRepo.EXPECT().
-		Get(mock.Anything, mock.MatchedBy(func(run *v1beta1.Run) bool {
-			return run.Status.State == v1beta1.RunStateQueued
-		})).
-		Once().
-		Return(&models.Run{
-			State: string(v1beta1.RunStateFinished),
-		}, nil)
+		GetStates(mock.Anything, mock.Anything, integration.RunsInState(v1beta1.RunStateQueued)).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFinished)).
+		Once()
 
 	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Return(&models.Stack{
 		Outputs: []models.StackOutput{
@@ -254,20 +248,14 @@ func (s *RunControllerSuite) TestRunCreation_OK_WithErrorDuringWatch() {
 	// mocks below will mimic the following state machine from Spacelift.
 	// QUEUED -> Error calling spacelift backend -> FINISHED
 	errCall := s.FakeSpaceliftRunRepo.EXPECT().
-		Get(mock.Anything, mock.MatchedBy(func(run *v1beta1.Run) bool {
-			return run.Status.State == v1beta1.RunStateQueued
-		})).
+		GetStates(mock.Anything, mock.Anything, integration.RunsInState(v1beta1.RunStateQueued)).
 		Once().
 		Return(nil, fmt.Errorf("temporary error fetching spacelift backend"))
 
 	s.FakeSpaceliftRunRepo.EXPECT().
-		Get(mock.Anything, mock.MatchedBy(func(run *v1beta1.Run) bool {
-			return run.Status.State == v1beta1.RunStateQueued
-		})).
-		Once().
-		Return(&models.Run{
-			State: string(v1beta1.RunStateFinished),
-		}, nil).NotBefore(errCall)
+		GetStates(mock.Anything, mock.Anything, integration.RunsInState(v1beta1.RunStateQueued)).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFinished)).
+		Once().NotBefore(errCall)
 
 	stack, err := s.CreateTestStackWithStatus()
 	s.Require().NoError(err)
@@ -301,6 +289,105 @@ func (s *RunControllerSuite) TestRunCreation_OK_WithErrorDuringWatch() {
 	s.Assert().Equal(1, logs.Len())
 }
 
+func (s *RunControllerSuite) TestRunDeletion_LeaveByDefault() {
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateQueued))
+
+	stack, err := s.CreateTestStackWithStatus()
+	s.Require().NoError(err)
+	defer s.DeleteStack(stack)
+
+	run, err := s.CreateTestRun()
+	s.Require().NoError(err)
+	run = s.AssertRunState(run, v1beta1.RunStateQueued)
+	s.Assert().Equal(v1beta1.RunOnDeleteLeave, run.Spec.OnDelete)
+	s.Assert().Empty(run.Finalizers)
+
+	// Cancel is not expected on the spacelift repository, the mock would fail otherwise
+	s.DeleteRun(run)
+}
+
+func (s *RunControllerSuite) TestRunDeletion_CancelQueuedRun() {
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateQueued))
+	s.FakeSpaceliftRunRepo.EXPECT().Cancel(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	stack, err := s.CreateTestStackWithStatus()
+	s.Require().NoError(err)
+	defer s.DeleteStack(stack)
+
+	s.Logs.TakeAll()
+	run := integration.DefaultValidRun
+	run.Spec.OnDelete = v1beta1.RunOnDeleteCancel
+	err = s.CreateRun(&run)
+	s.Require().NoError(err)
+	refreshedRun := s.AssertRunState(&run, v1beta1.RunStateQueued)
+	s.Require().Contains(refreshedRun.Finalizers, v1beta1.SpaceliftFinalizer)
+
+	s.DeleteRun(refreshedRun)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Run canceled").Len())
+	events, err := s.FindEvents(types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, v1beta1.EventReasonRunCanceled)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeNormal, events[0].Type)
+}
+
+func (s *RunControllerSuite) TestRunDeletion_DiscardUnconfirmedRun() {
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateUnconfirmed))
+	s.FakeSpaceliftRunRepo.EXPECT().Discard(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	stack, err := s.CreateTestStackWithStatus()
+	s.Require().NoError(err)
+	defer s.DeleteStack(stack)
+
+	s.Logs.TakeAll()
+	run := integration.DefaultValidRun
+	run.Spec.OnDelete = v1beta1.RunOnDeleteCancel
+	err = s.CreateRun(&run)
+	s.Require().NoError(err)
+	refreshedRun := s.AssertRunState(&run, v1beta1.RunStateUnconfirmed)
+
+	s.DeleteRun(refreshedRun)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Run discarded").Len())
+	events, err := s.FindEvents(types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, v1beta1.EventReasonRunDiscarded)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeNormal, events[0].Type)
+}
+
+func (s *RunControllerSuite) TestRunDeletion_UnableToCancelOnSpacelift() {
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateQueued))
+	failedCancel := s.FakeSpaceliftRunRepo.EXPECT().Cancel(mock.Anything, mock.Anything).Once().
+		Return(fmt.Errorf("unable to cancel run on spacelift"))
+	s.FakeSpaceliftRunRepo.EXPECT().Cancel(mock.Anything, mock.Anything).Once().
+		Return(nil).NotBefore(failedCancel)
+
+	stack, err := s.CreateTestStackWithStatus()
+	s.Require().NoError(err)
+	defer s.DeleteStack(stack)
+
+	s.Logs.TakeAll()
+	run := integration.DefaultValidRun
+	run.Spec.OnDelete = v1beta1.RunOnDeleteCancel
+	err = s.CreateRun(&run)
+	s.Require().NoError(err)
+	refreshedRun := s.AssertRunState(&run, v1beta1.RunStateQueued)
+
+	s.DeleteRun(refreshedRun)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Unable to cancel the run in spacelift").Len())
+	events, err := s.FindEvents(types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, v1beta1.EventReasonRunCancellationFailed)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeWarning, events[0].Type)
+}
+
 func TestRunController(t *testing.T) {
 	suite.Run(t, new(RunControllerSuite))
 }
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
+)
+
+// errSecretKeyNotFound is returned when a secret does not have the key referenced by a resource.
+var errSecretKeyNotFound = errors.New("key does not exist for secret")
+
+// secretChanged only lets through the secrets that have just been created, and the ones whose data changed,
+// so the values read from a rotated secret are pushed to spacelift.
+var secretChanged = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return true },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldSecret, ok := e.ObjectOld.(*v1.Secret)
+		if !ok {
+			return false
+		}
+		newSecret, ok := e.ObjectNew.(*v1.Secret)
+		return ok && !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// secretVersions are the versions of the secrets values are read from, by secret name.
+type secretVersions map[string]string
+
+// secretValue returns the value of a key of a secret of the namespace, and the version of the secret made of its UID and
+// resource version. A missing secret is reported with a not found error, and a missing key with errSecretKeyNotFound.
+func secretValue(ctx context.Context, secrets *repository.SecretRepository, namespace string, selector *v1.SecretKeySelector) (string, string, error) {
+	secret, err := secrets.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name})
+	if err != nil {
+		return "", "", err
+	}
+	value, ok := secret.Data[selector.Key]
+	if !ok {
+		return "", "", errSecretKeyNotFound
+	}
+	return string(value), string(secret.UID) + "/" + secret.ResourceVersion, nil
+}
+
+// resolveSecretValues sets the values of the environment variables and mounted files read from secrets in the spec of a
+// stack or context, so they can be pushed to spacelift as write only config elements, and returns the versions of the secrets
+// they are read from. It returns true when a secret or one of its keys is missing, the resource is then marked as waiting for it.
+func resolveSecretValues(
+	ctx context.Context,
+	secrets *repository.SecretRepository,
+	obj v1beta1.ConditionedObject,
+	kind string,
+	environment []v1beta1.Environment,
+	mountedFiles []v1beta1.MountedFile,
+	updateStatus func() error,
+) (secretVersions, bool, error) {
+	versions := secretVersions{}
+	resolve := func(id string, selector *v1.SecretKeySelector) (*string, bool, error) {
+		logger := log.FromContext(ctx).WithValues(logging.SecretName, selector.Name, logging.EnvironmentId, id)
+		value, version, err := secretValue(ctx, secrets, obj.GetNamespace(), selector)
+		switch {
+		case k8sErrors.IsNotFound(err):
+			logger.Info(fmt.Sprintf("Unable to find secret for %s, waiting for it to be created", kind))
+			markWaiting(ctx, obj, updateStatus, v1beta1.ReasonSecretNotFound, fmt.Sprintf("Secret %s not found", selector.Name))
+			return nil, false, nil
+		case errors.Is(err, errSecretKeyNotFound):
+			logger.WithValues(logging.SecretKey, selector.Key).Info(fmt.Sprintf("Unable to find key in secret for %s, waiting for it to be added", kind))
+			markWaiting(ctx, obj, updateStatus, v1beta1.ReasonSecretKeyNotFound,
+				fmt.Sprintf("Key %s not found in secret %s", selector.Key, selector.Name))
+			return nil, false, nil
+		case err != nil:
+			logger.Error(err, fmt.Sprintf("Error fetching secret for %s.", kind))
+			return nil, false, err
+		}
+		versions[selector.Name] = version
+		return &value, true, nil
+	}
+
+	for i := range environment {
+		if environment[i].ValueFromSecret == nil {
+			continue
+		}
+		value, ok, err := resolve(environment[i].Id, environment[i].ValueFromSecret)
+		if !ok {
+			return nil, true, err
+		}
+		environment[i].Value = value
+		environment[i].Secret = utils.AddressOf(true)
+	}
+	for i := range mountedFiles {
+		if mountedFiles[i].ValueFromSecret == nil {
+			continue
+		}
+		value, ok, err := resolve(mountedFiles[i].Id, mountedFiles[i].ValueFromSecret)
+		if !ok {
+			return nil, true, err
+		}
+		mountedFiles[i].Value = value
+		mountedFiles[i].Secret = utils.AddressOf(true)
+	}
+	return versions, false, nil
+}
+
+// hashSecretValues returns a hash of the secrets and keys the environment variables and mounted files are read from,
+// or an empty string when none of them references a secret.
+// Only the versions of the secrets are hashed, so the status never depends on their values.
+func hashSecretValues(environment []v1beta1.Environment, mountedFiles []v1beta1.MountedFile, versions secretVersions) string {
+	hash := sha256.New()
+	found := false
+	for _, environment := range environment {
+		if selector := environment.ValueFromSecret; selector != nil {
+			fmt.Fprintf(hash, "environment/%d/%s/%s/%s/%s\n", len(environment.Id), environment.Id, selector.Name, selector.Key, versions[selector.Name])
+			found = true
+		}
+	}
+	for _, mountedFile := range mountedFiles {
+		if selector := mountedFile.ValueFromSecret; selector != nil {
+			fmt.Fprintf(hash, "mountedFiles/%d/%s/%s/%s/%s\n", len(mountedFile.Id), mountedFile.Id, selector.Name, selector.Key, versions[selector.Name])
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
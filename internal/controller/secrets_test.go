@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
+)
+
+func Test_secretChanged(t *testing.T) {
+	secret := &v1.Secret{Data: map[string][]byte{"key": []byte("value")}}
+	rotated := &v1.Secret{Data: map[string][]byte{"key": []byte("rotated")}}
+
+	assert.True(t, secretChanged.Create(event.CreateEvent{Object: secret}))
+	assert.True(t, secretChanged.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: rotated}))
+	assert.False(t, secretChanged.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: secret.DeepCopy()}))
+	assert.False(t, secretChanged.Delete(event.DeleteEvent{Object: secret}))
+}
+
+func Test_hashSecretValues(t *testing.T) {
+	selector := &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: "secret"}, Key: "key"}
+	context := func(key string) *v1beta1.Context {
+		return &v1beta1.Context{Spec: v1beta1.ContextSpec{
+			Environment: []v1beta1.Environment{
+				{Id: "PLAIN", Value: utils.AddressOf("plain")},
+				{Id: "SECRET", Value: utils.AddressOf("value"), ValueFromSecret: selector},
+			},
+			MountedFiles: []v1beta1.MountedFile{
+				{Id: "file", Value: utils.AddressOf("value"), ValueFromSecret: &v1.SecretKeySelector{LocalObjectReference: selector.LocalObjectReference, Key: key}},
+			},
+		}}
+	}
+	hashContext := func(c *v1beta1.Context, versions secretVersions) string {
+		return hashSecretValues(c.Spec.Environment, c.Spec.MountedFiles, versions)
+	}
+	versions := secretVersions{"secret": "uid/1"}
+
+	hash := hashContext(context("file"), versions)
+	assert.NotEmpty(t, hash)
+	assert.Equal(t, hash, hashContext(context("file"), versions))
+	assert.NotEqual(t, hash, hashContext(context("other-file"), versions))
+	assert.NotEqual(t, hash, hashContext(context("file"), secretVersions{"secret": "uid/2"}))
+
+	// Values read from secrets are never hashed, only the versions of the secrets
+	rotated := context("file")
+	rotated.Spec.Environment[1].Value = utils.AddressOf("rotated")
+	assert.Equal(t, hash, hashContext(rotated, versions))
+
+	// Plain values are compared with spacelift, they are not part of the hash
+	assert.Empty(t, hashSecretValues([]v1beta1.Environment{{Id: "PLAIN", Value: utils.AddressOf("plain")}}, nil, versions))
+}
+
+func Test_secretValue(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "secret", UID: "uid"},
+		Data:       map[string][]byte{"key": []byte("value")},
+	}).Build()
+	secrets := repository.NewSecretRepository(k8sClient)
+	selector := func(name, key string) *v1.SecretKeySelector {
+		return &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: name}, Key: key}
+	}
+
+	value, version, err := secretValue(context.Background(), secrets, "default", selector("secret", "key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, "uid/999", version)
+
+	_, _, err = secretValue(context.Background(), secrets, "default", selector("secret", "missing"))
+	assert.ErrorIs(t, err, errSecretKeyNotFound)
+
+	_, _, err = secretValue(context.Background(), secrets, "default", selector("missing", "key"))
+	assert.True(t, k8sErrors.IsNotFound(err))
+}
+
+func Test_resolveSecretValues(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "secret", UID: "uid"},
+		Data:       map[string][]byte{"key": []byte("value"), "file": []byte("content")},
+	}).Build()
+	secrets := repository.NewSecretRepository(k8sClient)
+	selector := func(name, key string) *v1.SecretKeySelector {
+		return &v1.SecretKeySelector{LocalObjectReference: v1.LocalObjectReference{Name: name}, Key: key}
+	}
+	updateStatus := func() error { return nil }
+
+	t.Run("values read from secrets", func(t *testing.T) {
+		c := &v1beta1.Context{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.ContextSpec{
+			Environment:  []v1beta1.Environment{{Id: "PLAIN", Value: utils.AddressOf("plain")}, {Id: "SECRET", ValueFromSecret: selector("secret", "key")}},
+			MountedFiles: []v1beta1.MountedFile{{Id: "file", ValueFromSecret: selector("secret", "file")}},
+		}}
+		versions, waiting, err := resolveSecretValues(context.Background(), secrets, c, "context", c.Spec.Environment, c.Spec.MountedFiles, updateStatus)
+		require.NoError(t, err)
+		assert.False(t, waiting)
+		assert.Equal(t, secretVersions{"secret": "uid/999"}, versions)
+		assert.Equal(t, "plain", *c.Spec.Environment[0].Value)
+		assert.Nil(t, c.Spec.Environment[0].Secret)
+		assert.Equal(t, "value", *c.Spec.Environment[1].Value)
+		assert.True(t, *c.Spec.Environment[1].Secret)
+		assert.Equal(t, "content", *c.Spec.MountedFiles[0].Value)
+		assert.True(t, *c.Spec.MountedFiles[0].Secret)
+	})
+
+	for name, missing := range map[string]*v1.SecretKeySelector{
+		"missing secret": selector("missing", "key"),
+		"missing key":    selector("secret", "missing"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "default"}, Spec: v1beta1.StackSpec{
+				Environment: []v1beta1.Environment{{Id: "SECRET", ValueFromSecret: missing}},
+			}}
+			versions, waiting, err := resolveSecretValues(context.Background(), secrets, stack, "stack", stack.Spec.Environment, stack.Spec.MountedFiles, updateStatus)
+			require.NoError(t, err)
+			assert.True(t, waiting)
+			assert.Nil(t, versions)
+			dependencies := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionDependenciesReady)
+			require.NotNil(t, dependencies)
+			assert.Equal(t, metav1.ConditionFalse, dependencies.Status)
+		})
+	}
+}
@@ -18,11 +18,17 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -31,20 +37,32 @@ import (
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/drift"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 // SpaceReconciler reconciles a Space object
 type SpaceReconciler struct {
 	SpaceRepository          *repository.SpaceRepository
+	NamespaceRepository      *repository.NamespaceRepository
+	StackRepository          *repository.StackRepository
+	ContextRepository        *repository.ContextRepository
+	PolicyRepository         *repository.PolicyRepository
 	SpaceliftSpaceRepository spaceliftRepository.SpaceRepository
+	EventRecorder            record.EventRecorder
+	// ResyncPeriod is the delay after which an observed space is read from spacelift again, 0 disables it
+	ResyncPeriod time.Duration
+	// DryRun reports the changes to every space instead of applying them to spacelift
+	DryRun bool
 }
 
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=spaces,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=spaces/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=spaces/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=create;delete;get;list;patch;update;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *SpaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
@@ -62,15 +80,75 @@ func (r *SpaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	_, err = r.SpaceliftSpaceRepository.Get(ctx, space)
+	// Nothing is changed in spacelift while the reconciliation is paused, not even deletions
+	updateStatus := func() error { return r.SpaceRepository.UpdateStatus(ctx, space) }
+	if paused, err := handlePause(ctx, r.NamespaceRepository, space, updateStatus); paused || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !space.DeletionTimestamp.IsZero() {
+		return r.handleDeleteSpace(ctx, space)
+	}
+
+	// The finalizer is only needed when the space has to be cleaned up in Spacelift.
+	if space.Spec.DeletionPolicy == v1beta1.DeletionPolicyDelete {
+		if err := r.SpaceRepository.AddFinalizer(ctx, space); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Space finalizer update, let's try again.")
+				return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			logger.Error(err, "Error adding finalizer to space.")
+			return ctrl.Result{}, err
+		}
+	}
+
+	observing := space.Spec.ManagementPolicy == v1beta1.ManagementPolicyObserveOnly
+	if observing && space.Status.Id == "" {
+		space.Status.Id = observeId(space, space.Name())
+	}
+	adopting := adopt(space, &space.Status.Id)
+
+	spaceliftSpace, err := r.SpaceliftSpaceRepository.Get(ctx, space)
 	if err != nil && !errors.Is(err, spaceliftRepository.ErrSpaceNotFound) {
-		return ctrl.Result{}, errors.Wrap(err, "unable to retrieve space from spacelift")
+		err = errors.Wrap(err, "unable to retrieve space from spacelift")
+		markFailed(ctx, space, updateStatus, v1beta1.ReasonSpaceliftError, err)
+		return requeueOnError(err)
+	}
+
+	// Observed spaces are never created nor updated
+	if observing {
+		if errors.Is(err, spaceliftRepository.ErrSpaceNotFound) {
+			return observeFailed(ctx, r.EventRecorder, space, &space.Status.Id, updateStatus, "space", space.Status.Id)
+		}
+		return r.observeSpace(ctx, space, spaceliftSpace)
 	}
 
 	if errors.Is(err, spaceliftRepository.ErrSpaceNotFound) {
+		if v1beta1.AdoptId(space) != "" {
+			return adoptionFailed(ctx, r.EventRecorder, space, &space.Status.Id, updateStatus, "space")
+		}
+		if isDryRun(r.DryRun, space) {
+			reportDryRun(ctx, r.EventRecorder, space, "Space", false, drift.SpaceChanges(space, &models.Space{}), updateStatus)
+			return ctrl.Result{}, nil
+		}
 		return r.handleCreateSpace(ctx, space)
 	}
 
+	if adopting {
+		logger.WithValues(logging.SpaceId, space.Status.Id).Info("Space adopted")
+		r.EventRecorder.Eventf(space, v1.EventTypeNormal, v1beta1.EventReasonAdopted, "Space is bound to the existing Spacelift space %s", space.Status.Id)
+	}
+
+	// Once created, create only spaces are left untouched
+	if space.Spec.ManagementPolicy == v1beta1.ManagementPolicyCreateOnly {
+		return r.observeSpace(ctx, space, spaceliftSpace)
+	}
+
+	if isDryRun(r.DryRun, space) {
+		reportDryRun(ctx, r.EventRecorder, space, "Space", true, drift.SpaceChanges(space, spaceliftSpace), updateStatus)
+		return ctrl.Result{}, nil
+	}
+
 	return r.handleUpdateSpace(ctx, space)
 }
 
@@ -80,7 +158,7 @@ func (r *SpaceReconciler) handleCreateSpace(ctx context.Context, space *v1beta1.
 	spaceliftSpace, err := r.SpaceliftSpaceRepository.Create(ctx, space)
 	if err != nil {
 		logger.Error(err, "Unable to create space in spacelift")
-		return ctrl.Result{}, nil
+		return syncFailed(ctx, r.EventRecorder, space, func() error { return r.SpaceRepository.UpdateStatus(ctx, space) }, v1beta1.ReasonCreateFailed, err)
 	}
 
 	if space.Annotations == nil {
@@ -111,7 +189,7 @@ func (r *SpaceReconciler) handleUpdateSpace(ctx context.Context, space *v1beta1.
 	spaceliftUpdatedSpace, err := r.SpaceliftSpaceRepository.Update(ctx, space)
 	if err != nil {
 		logger.Error(err, "Unable to update the space in spacelift")
-		return ctrl.Result{}, err
+		return syncFailed(ctx, r.EventRecorder, space, func() error { return r.SpaceRepository.UpdateStatus(ctx, space) }, v1beta1.ReasonUpdateFailed, err)
 	}
 
 	res, err := r.updateSpaceStatus(ctx, space, *spaceliftUpdatedSpace)
@@ -125,6 +203,7 @@ func (r *SpaceReconciler) updateSpaceStatus(ctx context.Context, space *v1beta1.
 	logger := log.FromContext(ctx)
 
 	space.SetSpace(spaceliftSpace)
+	v1beta1.MarkSynced(space)
 	if err := r.SpaceRepository.UpdateStatus(ctx, space); err != nil {
 		if k8sErrors.IsConflict(err) {
 			logger.Info("Conflict on Space status update, let's try again.")
@@ -136,17 +215,164 @@ func (r *SpaceReconciler) updateSpaceStatus(ctx context.Context, space *v1beta1.
 	return ctrl.Result{}, nil
 }
 
+// observeSpace reports the spacelift space in the status without changing it.
+func (r *SpaceReconciler) observeSpace(ctx context.Context, space *v1beta1.Space, spaceliftSpace *models.Space) (ctrl.Result, error) {
+	space.SetSpace(*spaceliftSpace)
+	observed := &v1beta1.ObservedState{
+		Name:   spaceliftSpace.Name,
+		Space:  spaceliftSpace.ParentSpace,
+		Labels: spaceliftSpace.Labels,
+		URL:    spaceliftSpace.URL,
+	}
+	updateStatus := func() error { return r.SpaceRepository.UpdateStatus(ctx, space) }
+	return observe(ctx, r.EventRecorder, space, "Space", spaceliftSpace.ID, observed, updateStatus, r.ResyncPeriod)
+}
+
+func (r *SpaceReconciler) handleDeleteSpace(ctx context.Context, space *v1beta1.Space) (ctrl.Result, error) {
+	return finalize(ctx, r.EventRecorder, space, space.Spec.DeletionPolicy, spaceliftDeletion{
+		kind:  "Space",
+		id:    space.Status.Id,
+		idKey: logging.SpaceId,
+		get: func() error {
+			_, err := r.SpaceliftSpaceRepository.Get(ctx, space)
+			return err
+		},
+		notFound:        spaceliftRepository.ErrSpaceNotFound,
+		wait:            func() (bool, ctrl.Result, error) { return r.waitForSpaceChildren(ctx, space) },
+		delete:          func() error { return r.SpaceliftSpaceRepository.Delete(ctx, space) },
+		removeFinalizer: func() error { return r.SpaceRepository.RemoveFinalizer(ctx, space) },
+		deletedReason:   v1beta1.EventReasonSpaceDeleted,
+		failedReason:    v1beta1.EventReasonSpaceDeletionFailed,
+	})
+}
+
+// waitForSpaceChildren returns true while the space still has children.
+// Spacelift refuses to delete a space that still contains resources,
+// so the space stays in Terminating until all its children are gone.
+// Children left in Spacelift by their own deletion policy would block the space forever,
+// the deletion is reported as blocked instead until they are changed or the space is orphaned.
+func (r *SpaceReconciler) waitForSpaceChildren(ctx context.Context, space *v1beta1.Space) (bool, ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	children, orphaned, err := r.deleteSpaceChildren(ctx, space)
+	if err != nil {
+		logger.Error(err, "Unable to delete space children")
+		r.EventRecorder.Event(space, v1.EventTypeWarning, v1beta1.EventReasonSpaceDeletionFailed, err.Error())
+		return true, ctrl.Result{}, err
+	}
+	if len(orphaned) > 0 {
+		err := errors.Errorf("%s would be left in the space in Spacelift by their deletion policy, the space can't be deleted", strings.Join(orphaned, ", "))
+		logger.WithValues(logging.SpaceChildren, orphaned).Info("Space deletion is blocked by orphaned children")
+		r.EventRecorder.Event(space, v1.EventTypeWarning, v1beta1.EventReasonSpaceDeletionBlocked, err.Error())
+		markFailed(ctx, space, func() error { return r.SpaceRepository.UpdateStatus(ctx, space) }, v1beta1.ReasonOrphanedChildren, err)
+		return true, ctrl.Result{}, nil
+	}
+	if len(children) > 0 {
+		// The space is reconciled again by the deletion of its children
+		logger.WithValues(logging.SpaceChildren, children).Info("Space still has children, waiting for them to be deleted")
+		r.EventRecorder.Eventf(space, v1.EventTypeNormal, v1beta1.EventReasonSpaceDeletionWaiting,
+			"Waiting for %s to be deleted before deleting the space in Spacelift", strings.Join(children, ", "))
+		return true, ctrl.Result{}, nil
+	}
+	return false, ctrl.Result{}, nil
+}
+
+// deleteSpaceChildren returns the stacks, contexts, policies and spaces that still live in the space,
+// whether they reference it by name or by ID, and the ones among them that are not deleted in Spacelift
+// along with their resource: the Orphan deletion policy, which observed resources always have, and protected stacks.
+// Children owned by the space are deleted here: the garbage collector would only remove them
+// once the space itself is gone, which never happens while they block its finalizer.
+// Nothing is deleted while a child would be orphaned, since the space can't be deleted anyway.
+func (r *SpaceReconciler) deleteSpaceChildren(ctx context.Context, space *v1beta1.Space) ([]string, []string, error) {
+	var children, orphaned []string
+	var owned []func() error
+	add := func(kind string, child client.Object, deletionPolicy v1beta1.DeletionPolicy, remove func() error) {
+		name := fmt.Sprintf("%s/%s", kind, child.GetName())
+		children = append(children, name)
+		if deletionPolicy != v1beta1.DeletionPolicyDelete {
+			orphaned = append(orphaned, name)
+		}
+		if remove != nil && metav1.IsControlledBy(child, space) && child.GetDeletionTimestamp().IsZero() {
+			owned = append(owned, remove)
+		}
+	}
+
+	stacks, err := r.StackRepository.ListBySpace(ctx, space)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range stacks {
+		stack := &stacks[i]
+		deletionPolicy := stack.Spec.DeletionPolicy
+		if stack.Spec.ProtectFromDeletion != nil && *stack.Spec.ProtectFromDeletion {
+			deletionPolicy = v1beta1.DeletionPolicyOrphan
+		}
+		add("Stack", stack, deletionPolicy, func() error { return r.StackRepository.Delete(ctx, stack) })
+	}
+
+	contexts, err := r.ContextRepository.ListBySpace(ctx, space)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range contexts {
+		context := &contexts[i]
+		add("Context", context, context.Spec.DeletionPolicy, func() error { return r.ContextRepository.Delete(ctx, context) })
+	}
+
+	policies, err := r.PolicyRepository.ListBySpace(ctx, space)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range policies {
+		policy := &policies[i]
+		add("Policy", policy, policy.Spec.DeletionPolicy, func() error { return r.PolicyRepository.Delete(ctx, policy) })
+	}
+
+	if space.Status.Id != "" {
+		spaces, err := r.SpaceRepository.ListByParentSpace(ctx, space.Namespace, space.Status.Id)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range spaces {
+			add("Space", &spaces[i], spaces[i].Spec.DeletionPolicy, nil)
+		}
+	}
+
+	if len(orphaned) > 0 {
+		return children, orphaned, nil
+	}
+	for _, remove := range owned {
+		if err := remove(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return children, nil, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *SpaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &v1beta1.Space{}, spaceIdIndex, indexSpaceId); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1beta1.Space{}).
-		WithEventFilter(predicate.Funcs{
+		For(&v1beta1.Space{}, builder.WithPredicates(predicate.Funcs{
 			// Always handle new resource creation
 			CreateFunc: func(event.CreateEvent) bool { return true },
-			// Always handle resource update
-			UpdateFunc: func(e event.UpdateEvent) bool { return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() },
-			// We don't care about space removal
+			// Always handle resource update, any update while the space is being deleted, and the space being paused or resumed
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+					!e.ObjectNew.GetDeletionTimestamp().IsZero() ||
+					pauseChanged(e)
+			},
+			// Space removal is handled by the finalizer, once the resource is gone there is nothing left to do
 			DeleteFunc: func(event.DeleteEvent) bool { return false },
-		}).
-		Complete(r)
+		})).
+		Watches(&v1beta1.Stack{}, enqueueDeletingSpace(mgr.GetClient()), builder.WithPredicates(spaceChildChanged)).
+		Watches(&v1beta1.Context{}, enqueueDeletingSpace(mgr.GetClient()), builder.WithPredicates(spaceChildChanged)).
+		Watches(&v1beta1.Policy{}, enqueueDeletingSpace(mgr.GetClient()), builder.WithPredicates(spaceChildChanged)).
+		Watches(&v1beta1.Space{}, enqueueDeletingSpace(mgr.GetClient()), builder.WithPredicates(spaceChildChanged)).
+		Watches(&v1.Namespace{}, enqueueNamespace(mgr.GetClient(), func() client.ObjectList { return &v1beta1.SpaceList{} }),
+			builder.WithPredicates(namespacePauseChanged)).
+		Complete(tracing.Reconciler("Space", r))
 }
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap/zaptest/observer"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -19,21 +21,31 @@ import (
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/mocks"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
 	"github.com/spacelift-io/spacelift-operator/tests/integration"
 )
 
 type SpaceControllerSuite struct {
 	integration.IntegrationTestSuite
 	integration.WithSpaceSuiteHelper
+	integration.WithStackSuiteHelper
+	integration.WithEventHelper
 }
 
 func (s *SpaceControllerSuite) SetupSuite() {
 	s.SetupManager = func(mgr manager.Manager) {
 		s.FakeSpaceliftSpaceRepo = new(mocks.SpaceRepository)
 		s.SpaceRepo = repository.NewSpaceRepository(mgr.GetClient())
+		s.StackRepo = repository.NewStackRepository(mgr.GetClient(), mgr.GetScheme())
+		s.NamespaceRepo = repository.NewNamespaceRepository(mgr.GetClient())
 		err := (&controller.SpaceReconciler{
 			SpaceRepository:          s.SpaceRepo,
+			NamespaceRepository:      s.NamespaceRepo,
+			StackRepository:          s.StackRepo,
+			ContextRepository:        repository.NewContextRepository(mgr.GetClient(), mgr.GetScheme()),
+			PolicyRepository:         repository.NewPolicyRepository(mgr.GetClient(), mgr.GetScheme()),
 			SpaceliftSpaceRepository: s.FakeSpaceliftSpaceRepo,
+			EventRecorder:            mgr.GetEventRecorderFor("space-controller"),
 		}).SetupWithManager(mgr)
 		s.Require().NoError(err)
 	}
@@ -41,6 +53,12 @@ func (s *SpaceControllerSuite) SetupSuite() {
 	s.WithSpaceSuiteHelper = integration.WithSpaceSuiteHelper{
 		IntegrationTestSuite: &s.IntegrationTestSuite,
 	}
+	s.WithStackSuiteHelper = integration.WithStackSuiteHelper{
+		IntegrationTestSuite: &s.IntegrationTestSuite,
+	}
+	s.WithEventHelper = integration.WithEventHelper{
+		IntegrationTestSuite: &s.IntegrationTestSuite,
+	}
 }
 
 func (s *SpaceControllerSuite) SetupTest() {
@@ -144,6 +162,16 @@ func (s *SpaceControllerSuite) TestSpaceCreation_Success() {
 		return logs.Len() == 1
 	}, 3*time.Second, integration.DefaultInterval)
 
+	// Make sure the space is reported as ready
+	space, err = s.SpaceRepo.Get(s.Context(), types.NamespacedName{
+		Namespace: space.Namespace,
+		Name:      space.ObjectMeta.Name,
+	})
+	s.Require().NoError(err)
+	s.Assert().True(meta.IsStatusConditionTrue(space.Status.Conditions, v1beta1.ConditionReady))
+	s.Assert().True(meta.IsStatusConditionFalse(space.Status.Conditions, v1beta1.ConditionError))
+	s.Assert().Equal(space.Generation, space.Status.ObservedGeneration)
+
 	logContext := logs.All()[0].ContextMap()
 	s.Require().Contains(logContext, logging.SpaceId)
 	s.Assert().Equal(logContext[logging.SpaceId], "test-space-generated-id")
@@ -211,6 +239,286 @@ func (s *SpaceControllerSuite) TestSpaceUpdate_OK() {
 	s.Assert().Equal(logContext[logging.SpaceId], "test-space-generated-id")
 }
 
+func (s *SpaceControllerSuite) TestSpaceAdoption_OK() {
+	fakeSpace := &models.Space{
+		ID: "existing-space-id",
+	}
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.MatchedBy(func(space *v1beta1.Space) bool {
+		return space.Status.Id == "existing-space-id"
+	})).Once().Return(fakeSpace, nil)
+	// Create is not expected on the spacelift repository, the mock would fail otherwise
+	s.FakeSpaceliftSpaceRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeSpace, nil)
+
+	s.Logs.TakeAll()
+	space, err := s.CreateSpace(&v1beta1.Space{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Space",
+			APIVersion: v1beta1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-space",
+			Namespace:   "default",
+			Annotations: map[string]string{v1beta1.AdoptAnnotation: "existing-space-id"},
+		},
+		Spec: v1beta1.SpaceSpec{
+			ParentSpace: "root",
+		},
+	})
+	s.Require().NoError(err)
+	defer s.DeleteSpace(space)
+
+	s.Require().Eventually(func() bool {
+		space, err := s.SpaceRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: space.Namespace,
+			Name:      space.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		return space.Status.Id == "existing-space-id"
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	logs := s.Logs.FilterMessage("Space adopted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("existing-space-id", logs.All()[0].ContextMap()[logging.SpaceId])
+}
+
+// createDeletableSpace creates a space with the Delete deletion policy and waits for it to be created in spacelift
+func (s *SpaceControllerSuite) createDeletableSpace() *v1beta1.Space {
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrSpaceNotFound)
+	s.FakeSpaceliftSpaceRepo.EXPECT().Create(mock.Anything, mock.Anything).Once().
+		Return(&models.Space{
+			ID: "test-space-generated-id",
+		}, nil)
+
+	space, err := s.CreateSpace(&v1beta1.Space{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Space",
+			APIVersion: v1beta1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-space",
+			Namespace: "default",
+		},
+		Spec: v1beta1.SpaceSpec{
+			ParentSpace:    "root",
+			DeletionPolicy: v1beta1.DeletionPolicyDelete,
+		},
+	})
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		space, err = s.SpaceRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: space.Namespace,
+			Name:      space.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		return space.Status.Id == "test-space-generated-id"
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	return space
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_OrphanByDefault() {
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrSpaceNotFound)
+	s.FakeSpaceliftSpaceRepo.EXPECT().Create(mock.Anything, mock.Anything).Once().
+		Return(&models.Space{
+			ID: "test-space-generated-id",
+		}, nil)
+
+	s.Logs.TakeAll()
+	space, err := s.CreateTestSpace()
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Space created").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	space, err = s.SpaceRepo.Get(s.Context(), types.NamespacedName{
+		Namespace: space.Namespace,
+		Name:      space.ObjectMeta.Name,
+	})
+	s.Require().NoError(err)
+	s.Assert().Equal(v1beta1.DeletionPolicyOrphan, space.Spec.DeletionPolicy)
+	s.Assert().Empty(space.Finalizers)
+
+	// Delete is not expected on the spacelift repository, the mock would fail otherwise
+	s.DeleteSpace(space)
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_OK() {
+	s.Logs.TakeAll()
+	space := s.createDeletableSpace()
+	s.Require().Contains(space.Finalizers, v1beta1.SpaceliftFinalizer)
+
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Space{ID: "test-space-generated-id"}, nil)
+	s.FakeSpaceliftSpaceRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.DeleteSpace(space)
+
+	logs := s.Logs.FilterMessage("Space deleted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("test-space-generated-id", logs.All()[0].ContextMap()[logging.SpaceId])
+	events, err := s.FindEvents(types.NamespacedName{Namespace: space.Namespace, Name: space.ObjectMeta.Name}, v1beta1.EventReasonSpaceDeleted)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeNormal, events[0].Type)
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_WaitForChildren() {
+	s.Logs.TakeAll()
+	space := s.createDeletableSpace()
+
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.SpaceId = nil
+	stack.Spec.SpaceName = utils.AddressOf(space.ObjectMeta.Name)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.Client().Delete(s.Context(), space))
+
+	// The space must stay in Terminating while the stack still exists
+	s.Require().Eventually(func() bool {
+		events, err := s.FindEvents(types.NamespacedName{Namespace: space.Namespace, Name: space.ObjectMeta.Name}, v1beta1.EventReasonSpaceDeletionWaiting)
+		s.Require().NoError(err)
+		return len(events) > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Equal(0, s.Logs.FilterMessage("Space deleted").Len())
+
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Space{ID: "test-space-generated-id"}, nil)
+	s.FakeSpaceliftSpaceRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.DeleteStack(&stack)
+	// The deletion of the stack reconciles the space again
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Space deleted").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.WaitUntilSpaceRemoved(space)
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_WaitForChildrenReferencingId() {
+	s.Logs.TakeAll()
+	space := s.createDeletableSpace()
+
+	// The stack lives in the space through its ID, it blocks the deletion as well
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.SpaceId = utils.AddressOf(space.Status.Id)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.Client().Delete(s.Context(), space))
+
+	s.Require().Eventually(func() bool {
+		events, err := s.FindEvents(types.NamespacedName{Namespace: space.Namespace, Name: space.ObjectMeta.Name}, v1beta1.EventReasonSpaceDeletionWaiting)
+		s.Require().NoError(err)
+		return len(events) > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Equal(0, s.Logs.FilterMessage("Space deleted").Len())
+
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Space{ID: "test-space-generated-id"}, nil)
+	s.FakeSpaceliftSpaceRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.DeleteStack(&stack)
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Space deleted").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.WaitUntilSpaceRemoved(space)
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_DeleteOwnedChildren() {
+	s.Logs.TakeAll()
+	space := s.createDeletableSpace()
+
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.SpaceId = nil
+	stack.Spec.SpaceName = utils.AddressOf(space.ObjectMeta.Name)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	s.Require().NoError(s.StackRepo.SetOwner(s.Context(), &stack, space))
+
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(&models.Space{ID: "test-space-generated-id"}, nil)
+	s.FakeSpaceliftSpaceRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.Require().NoError(s.Client().Delete(s.Context(), space))
+
+	// The space deletes the stacks it owns instead of waiting for the garbage collector
+	s.WaitUntilStackRemoved(&stack)
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Space deleted").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.WaitUntilSpaceRemoved(space)
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_BlockedByOrphanedChildren() {
+	s.Logs.TakeAll()
+	space := s.createDeletableSpace()
+
+	// The stack is left in Spacelift by its default deletion policy, it would keep the space from being deleted
+	stack := integration.DefaultValidStack
+	stack.Spec.SpaceId = nil
+	stack.Spec.SpaceName = utils.AddressOf(space.ObjectMeta.Name)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	s.Require().NoError(s.StackRepo.SetOwner(s.Context(), &stack, space))
+
+	s.Require().NoError(s.Client().Delete(s.Context(), space))
+
+	s.Require().Eventually(func() bool {
+		events, err := s.FindEvents(types.NamespacedName{Namespace: space.Namespace, Name: space.ObjectMeta.Name}, v1beta1.EventReasonSpaceDeletionBlocked)
+		s.Require().NoError(err)
+		return len(events) > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	space, err = s.SpaceRepo.Get(s.Context(), types.NamespacedName{Namespace: space.Namespace, Name: space.ObjectMeta.Name})
+	s.Require().NoError(err)
+	ready := meta.FindStatusCondition(space.Status.Conditions, v1beta1.ConditionReady)
+	s.Require().NotNil(ready)
+	s.Assert().Equal(v1beta1.ReasonOrphanedChildren, ready.Reason)
+	s.Assert().Contains(ready.Message, "Stack/"+stack.ObjectMeta.Name)
+
+	// The owned stack is not deleted while the space can't be deleted
+	_, err = s.StackRepo.Get(s.Context(), types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name})
+	s.Require().NoError(err)
+
+	// Orphaning the space releases it
+	space.Spec.DeletionPolicy = v1beta1.DeletionPolicyOrphan
+	s.Require().NoError(s.SpaceRepo.Update(s.Context(), space))
+	s.WaitUntilSpaceRemoved(space)
+	s.Assert().Equal(0, s.Logs.FilterMessage("Space deleted").Len())
+	s.DeleteStack(&stack)
+}
+
+func (s *SpaceControllerSuite) TestSpaceDeletion_UnableToDeleteOnSpacelift() {
+	s.Logs.TakeAll()
+	space := s.createDeletableSpace()
+
+	s.FakeSpaceliftSpaceRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(&models.Space{ID: "test-space-generated-id"}, nil)
+	failedDelete := s.FakeSpaceliftSpaceRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(fmt.Errorf("unable to delete resource on spacelift"))
+	s.FakeSpaceliftSpaceRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil).NotBefore(failedDelete)
+
+	s.DeleteSpace(space)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Unable to delete the space in spacelift").Len())
+	events, err := s.FindEvents(types.NamespacedName{Namespace: space.Namespace, Name: space.ObjectMeta.Name}, v1beta1.EventReasonSpaceDeletionFailed)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeWarning, events[0].Type)
+}
+
 func TestSpaceController(t *testing.T) {
 	suite.Run(t, new(SpaceControllerSuite))
 }
@@ -18,13 +18,18 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -32,21 +37,36 @@ import (
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/drift"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/watcher"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 // StackReconciler reconciles a Stack object
 type StackReconciler struct {
 	StackRepository          *repository.StackRepository
+	NamespaceRepository      *repository.NamespaceRepository
 	SpaceRepository          *repository.SpaceRepository
+	SecretRepository         *repository.SecretRepository
+	ContextRepository        *repository.ContextRepository
 	SpaceliftStackRepository spaceliftRepository.StackRepository
+	SpaceliftRunRepository   spaceliftRepository.RunRepository
+	RunWatcher               *watcher.RunWatcher
+	EventRecorder            record.EventRecorder
+	// ResyncPeriod is the delay after which a synced resource is compared with spacelift again, 0 disables it
+	ResyncPeriod time.Duration
+	// DryRun reports the changes to every stack instead of applying them to spacelift
+	DryRun bool
 }
 
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=stacks,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=stacks/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=app.spacelift.io,resources=stacks/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=create;delete;get;list;patch;update;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,17 +89,57 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	_, err = r.SpaceliftStackRepository.Get(ctx, stack)
+	// Nothing is changed in spacelift while the reconciliation is paused, not even deletions
+	updateStatus := func() error { return r.StackRepository.UpdateStatus(ctx, stack) }
+	if paused, err := handlePause(ctx, r.NamespaceRepository, stack, updateStatus); paused || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !stack.DeletionTimestamp.IsZero() {
+		return r.handleDeleteStack(ctx, stack)
+	}
+
+	// The finalizer is only needed when the stack has to be cleaned up in Spacelift.
+	// This must happen before the spec is used as a DTO below, since it updates the whole resource.
+	if stack.Spec.DeletionPolicy == v1beta1.DeletionPolicyDelete {
+		if err := r.StackRepository.AddFinalizer(ctx, stack); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Stack finalizer update, let's try again.")
+				return ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			logger.Error(err, "Error adding finalizer to stack.")
+			return ctrl.Result{}, err
+		}
+	}
+
+	spaceliftStack, err := r.SpaceliftStackRepository.Get(ctx, stack)
 	if err != nil && !errors.Is(err, spaceliftRepository.ErrStackNotFound) {
-		return ctrl.Result{}, errors.Wrap(err, "unable to retrieve stack from spacelift")
+		err = errors.Wrap(err, "unable to retrieve stack from spacelift")
+		markFailed(ctx, stack, updateStatus, v1beta1.ReasonSpaceliftError, err)
+		return requeueOnError(err)
+	}
+
+	// Observed stacks are never created nor updated, so their dependencies don't need to be resolved
+	if stack.Spec.ManagementPolicy == v1beta1.ManagementPolicyObserveOnly {
+		if errors.Is(err, spaceliftRepository.ErrStackNotFound) {
+			return observeFailed(ctx, r.EventRecorder, stack, &stack.Status.Id, updateStatus, "stack", observeId(stack, stack.Name()))
+		}
+		return r.observeStack(ctx, stack, spaceliftStack)
+	}
+
+	// Adopted stacks are looked up by the adopt annotation, the ID is persisted by the status update below
+	if spaceliftStack != nil && v1beta1.AdoptId(stack) != "" && !stack.Ready() {
+		logger.WithValues(logging.StackId, spaceliftStack.Id).Info("Stack adopted")
+		r.EventRecorder.Eventf(stack, v1.EventTypeNormal, v1beta1.EventReasonAdopted, "Stack is bound to the existing Spacelift stack %s", spaceliftStack.Id)
 	}
 
 	if stack.Spec.SpaceName != nil {
 		space, err := r.SpaceRepository.Get(ctx, types.NamespacedName{Namespace: stack.Namespace, Name: *stack.Spec.SpaceName})
 		if err != nil {
 			if k8sErrors.IsNotFound(err) {
-				logger.Info("Unable to find space for stack, will retry in 10 seconds")
-				return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+				logger.Info("Unable to find space for stack, waiting for it to be created")
+				markWaiting(ctx, stack, updateStatus, v1beta1.ReasonSpaceNotFound, fmt.Sprintf("Space %s not found", *stack.Spec.SpaceName))
+				return ctrl.Result{}, nil
 			}
 			logger.Error(err, "Error fetching space for stack")
 			return ctrl.Result{}, err
@@ -87,8 +147,9 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 
 		// Space is created but status is not yet updated
 		if !space.Ready() {
-			logger.Info("Space is not ready yet, will retry in 3 seconds")
-			return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+			logger.Info("Space is not ready yet, waiting for it")
+			markWaiting(ctx, stack, updateStatus, v1beta1.ReasonSpaceNotReady, fmt.Sprintf("Space %s is not ready", *stack.Spec.SpaceName))
+			return ctrl.Result{}, nil
 		}
 
 		if len(stack.OwnerReferences) == 0 {
@@ -101,24 +162,108 @@ func (r *StackReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		stack.Spec.SpaceId = &space.Status.Id
 	}
 
+	// Dependencies on stack resources are set with the ID of the stack, so they must be ready first
+	for i, dependency := range stack.Spec.DependsOn {
+		if dependency.StackName == nil {
+			continue
+		}
+		logger := logger.WithValues(logging.StackName, *dependency.StackName)
+		dependencyStack, err := r.StackRepository.Get(ctx, types.NamespacedName{Namespace: stack.Namespace, Name: *dependency.StackName})
+		if err != nil {
+			if k8sErrors.IsNotFound(err) {
+				logger.Info("Unable to find stack dependency, waiting for it to be created")
+				markWaiting(ctx, stack, updateStatus, v1beta1.ReasonStackNotFound, fmt.Sprintf("Stack %s not found", *dependency.StackName))
+				return ctrl.Result{}, nil
+			}
+			logger.Error(err, "Error fetching stack dependency.")
+			return ctrl.Result{}, err
+		}
+		if !dependencyStack.Ready() {
+			logger.Info("Stack dependency is not ready yet, waiting for it")
+			markWaiting(ctx, stack, updateStatus, v1beta1.ReasonStackNotReady, fmt.Sprintf("Stack %s is not ready", *dependency.StackName))
+			return ctrl.Result{}, nil
+		}
+		stack.Spec.DependsOn[i].StackId = &dependencyStack.Status.Id
+	}
+
+	// Context resources are attached with the ID of the context, so they must be ready first
+	for i, stackContext := range stack.Spec.Contexts {
+		if stackContext.ContextName == nil {
+			continue
+		}
+		logger := logger.WithValues(logging.ContextName, *stackContext.ContextName)
+		attachedContext, err := r.ContextRepository.Get(ctx, types.NamespacedName{Namespace: stack.Namespace, Name: *stackContext.ContextName})
+		if err != nil {
+			if k8sErrors.IsNotFound(err) {
+				logger.Info("Unable to find context for stack, waiting for it to be created")
+				markWaiting(ctx, stack, updateStatus, v1beta1.ReasonContextNotFound, fmt.Sprintf("Context %s not found", *stackContext.ContextName))
+				return ctrl.Result{}, nil
+			}
+			logger.Error(err, "Error fetching context for stack.")
+			return ctrl.Result{}, err
+		}
+		if !attachedContext.Ready() {
+			logger.Info("Context is not ready yet, waiting for it")
+			markWaiting(ctx, stack, updateStatus, v1beta1.ReasonContextNotReady, fmt.Sprintf("Context %s is not ready", *stackContext.ContextName))
+			return ctrl.Result{}, nil
+		}
+		stack.Spec.Contexts[i].ContextId = &attachedContext.Status.Id
+	}
+
+	versions, waiting, resolveErr := resolveSecretValues(ctx, r.SecretRepository, stack, "stack", stack.Spec.Environment, stack.Spec.MountedFiles, updateStatus)
+	if waiting || resolveErr != nil {
+		return ctrl.Result{}, resolveErr
+	}
+	secretsHash := hashSecretValues(stack.Spec.Environment, stack.Spec.MountedFiles, versions)
+
 	if errors.Is(err, spaceliftRepository.ErrStackNotFound) {
+		if v1beta1.AdoptId(stack) != "" {
+			return adoptionFailed(ctx, r.EventRecorder, stack, &stack.Status.Id, updateStatus, "stack")
+		}
+		if isDryRun(r.DryRun, stack) {
+			reportDryRun(ctx, r.EventRecorder, stack, "Stack", false, drift.StackChanges(stack, &models.Stack{}), updateStatus)
+			return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+		}
 		// Stack does not exist in Spacelift, let's create it
-		return r.handleCreateStack(ctx, stack)
+		return r.handleCreateStack(ctx, stack, secretsHash)
+	}
+
+	// Once created, create only stacks are left untouched
+	if stack.Spec.ManagementPolicy == v1beta1.ManagementPolicyCreateOnly {
+		return r.observeStack(ctx, stack, spaceliftStack)
+	}
+
+	if isDryRun(r.DryRun, stack) {
+		stack.SetStack(*spaceliftStack)
+		reportDryRun(ctx, r.EventRecorder, stack, "Stack", true, drift.StackChanges(stack, spaceliftStack), updateStatus)
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
 	}
 
-	return r.handleUpdateStack(ctx, stack)
+	// Values of secrets can't be read back from spacelift, so a changed secret is only noticed through the hash of its values
+	if isResync(stack) && secretsHash != stack.Status.SecretsHash {
+		logger.Info("Secret values changed, updating stack")
+		return r.handleUpdateStack(ctx, stack, secretsHash)
+	}
+
+	driftedFields := func() []string { return drift.Stack(stack, spaceliftStack) }
+	if !handleDrift(ctx, r.EventRecorder, stack, stack.Spec.DriftPolicy, driftedFields, updateStatus) {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+
+	return r.handleUpdateStack(ctx, stack, secretsHash)
 }
 
-func (r *StackReconciler) handleCreateStack(ctx context.Context, stack *v1beta1.Stack) (ctrl.Result, error) {
+func (r *StackReconciler) handleCreateStack(ctx context.Context, stack *v1beta1.Stack, secretsHash string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	spaceliftStack, err := r.SpaceliftStackRepository.Create(ctx, stack)
 	if err != nil {
 		logger.Error(err, "Unable to create the stack in spacelift")
-		// TODO: Implement better error handling and retry errors that could be retried
-		return ctrl.Result{}, nil
+		return syncFailed(ctx, r.EventRecorder, stack, func() error { return r.StackRepository.UpdateStatus(ctx, stack) }, v1beta1.ReasonCreateFailed, err)
 	}
 
+	attachedContextIds := contextIds(stack)
+
 	// Refetch the stack to get the latest state.
 	stack, err = r.StackRepository.Get(ctx, types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name})
 	if err != nil {
@@ -142,6 +287,8 @@ func (r *StackReconciler) handleCreateStack(ctx context.Context, stack *v1beta1.
 		return ctrl.Result{}, err
 	}
 
+	stack.Status.SecretsHash = secretsHash
+	stack.Status.AttachedContextIds = attachedContextIds
 	res, err := r.updateStackStatus(ctx, stack, *spaceliftStack)
 
 	logger.WithValues(
@@ -151,15 +298,17 @@ func (r *StackReconciler) handleCreateStack(ctx context.Context, stack *v1beta1.
 	return res, err
 }
 
-func (r *StackReconciler) handleUpdateStack(ctx context.Context, stack *v1beta1.Stack) (ctrl.Result, error) {
+func (r *StackReconciler) handleUpdateStack(ctx context.Context, stack *v1beta1.Stack, secretsHash string) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	spaceliftUpdatedStack, err := r.SpaceliftStackRepository.Update(ctx, stack)
 	if err != nil {
 		logger.Error(err, "Unable to update the stack in spacelift")
-		return ctrl.Result{}, err
+		return syncFailed(ctx, r.EventRecorder, stack, func() error { return r.StackRepository.UpdateStatus(ctx, stack) }, v1beta1.ReasonUpdateFailed, err)
 	}
 
+	stack.Status.SecretsHash = secretsHash
+	stack.Status.AttachedContextIds = contextIds(stack)
 	res, err := r.updateStackStatus(ctx, stack, *spaceliftUpdatedStack)
 
 	logger.WithValues(
@@ -169,10 +318,23 @@ func (r *StackReconciler) handleUpdateStack(ctx context.Context, stack *v1beta1.
 	return res, err
 }
 
+// contextIds returns the IDs of the contexts of spec.contexts, once their names have been resolved.
+func contextIds(stack *v1beta1.Stack) []string {
+	var ids []string
+	for _, stackContext := range stack.Spec.Contexts {
+		if stackContext.ContextId != nil {
+			ids = append(ids, *stackContext.ContextId)
+		}
+	}
+	return ids
+}
+
 func (r *StackReconciler) updateStackStatus(ctx context.Context, stack *v1beta1.Stack, spaceliftStack models.Stack) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 
 	stack.SetStack(spaceliftStack)
+	v1beta1.MarkSynced(stack)
+	v1beta1.MarkInSync(stack)
 	if err := r.StackRepository.UpdateStatus(ctx, stack); err != nil {
 		if k8sErrors.IsConflict(err) {
 			logger.Info("Conflict on Stack status update, let's try again.")
@@ -181,20 +343,193 @@ func (r *StackReconciler) updateStackStatus(ctx context.Context, stack *v1beta1.
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// observeStack reports the spacelift stack in the status without changing it.
+func (r *StackReconciler) observeStack(ctx context.Context, stack *v1beta1.Stack, spaceliftStack *models.Stack) (ctrl.Result, error) {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues(logging.StackId, spaceliftStack.Id))
+
+	stack.SetStack(*spaceliftStack)
+	observed := &v1beta1.ObservedState{
+		Name:   spaceliftStack.Name,
+		Space:  spaceliftStack.SpaceId,
+		Labels: spaceliftStack.Labels,
+		State:  spaceliftStack.State,
+		URL:    spaceliftStack.Url,
+	}
+	updateStatus := func() error { return r.StackRepository.UpdateStatus(ctx, stack) }
+	return observe(ctx, r.EventRecorder, stack, "Stack", spaceliftStack.Id, observed, updateStatus, r.ResyncPeriod)
+}
+
+func (r *StackReconciler) handleDeleteStack(ctx context.Context, stack *v1beta1.Stack) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(stack, v1beta1.SpaceliftFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if stack.Spec.DeletionPolicy != v1beta1.DeletionPolicyDelete {
+		logger.Info("Stack deletion policy is not Delete, leaving the stack in spacelift")
+		return r.removeStackFinalizer(ctx, stack)
+	}
+
+	if stack.Spec.ProtectFromDeletion != nil && *stack.Spec.ProtectFromDeletion {
+		logger.Info("Stack is protected from deletion, leaving the stack in spacelift")
+		r.EventRecorder.Event(stack, v1.EventTypeWarning, v1beta1.EventReasonStackOrphaned,
+			"Stack is protected from deletion in Spacelift, it has not been deleted")
+		return r.removeStackFinalizer(ctx, stack)
+	}
+
+	spaceliftStack, err := r.SpaceliftStackRepository.Get(ctx, stack)
+	if err != nil && !errors.Is(err, spaceliftRepository.ErrStackNotFound) {
+		logger.Error(err, "Unable to retrieve the stack from spacelift")
+		r.EventRecorder.Event(stack, v1.EventTypeWarning, v1beta1.EventReasonStackDeletionFailed, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	// Stack is already gone from Spacelift, we just need to release the resource
+	if errors.Is(err, spaceliftRepository.ErrStackNotFound) {
+		logger.Info("Stack does not exist in spacelift, removing finalizer")
+		return r.removeStackFinalizer(ctx, stack)
+	}
+
+	stack.Status.Id = spaceliftStack.Id
+	if stack.ShouldDestroyOnDelete() {
+		destroyed, res, err := r.destroyStackResources(ctx, stack)
+		if !destroyed {
+			return res, err
+		}
+	}
+
+	if err := r.SpaceliftStackRepository.Delete(ctx, stack); err != nil {
+		logger.Error(err, "Unable to delete the stack in spacelift")
+		r.EventRecorder.Event(stack, v1.EventTypeWarning, v1beta1.EventReasonStackDeletionFailed, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	logger.WithValues(logging.StackId, spaceliftStack.Id).Info("Stack deleted")
+	r.EventRecorder.Event(stack, v1.EventTypeNormal, v1beta1.EventReasonStackDeleted, "Stack has been deleted in Spacelift")
+
+	return r.removeStackFinalizer(ctx, stack)
+}
+
+// destroyStackResources makes sure a destroy task ran to completion on the stack before it gets deleted.
+// The task is watched by the run watcher, which updates the stack status and triggers a new reconciliation.
+// It returns true once the stack resources have been destroyed.
+func (r *StackReconciler) destroyStackResources(ctx context.Context, stack *v1beta1.Stack) (bool, ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if stack.ShouldRetryDestroy() {
+		logger.WithValues(
+			logging.RunId, stack.Status.DestroyRunId,
+			logging.RunState, stack.Status.DestroyRunState,
+		).Info("Retrying the destroy task")
+		stack.Status.DestroyRunId = ""
+		stack.Status.DestroyRunState = ""
+	}
+
+	if stack.Status.DestroyRunId == "" {
+		run, err := r.SpaceliftRunRepository.CreateDestroyTask(ctx, stack)
+		if err != nil {
+			logger.Error(err, "Unable to trigger destroy task in spacelift")
+			r.EventRecorder.Event(stack, v1.EventTypeWarning, v1beta1.EventReasonStackDestroyFailed, err.Error())
+			return false, ctrl.Result{}, err
+		}
+		stack.Status.DestroyRunId = run.Id
+		stack.Status.DestroyRunState = v1beta1.RunState(run.State)
+		stack.Status.DestroyRunGeneration = stack.Generation
+		if err := r.StackRepository.UpdateStatus(ctx, stack); err != nil {
+			if k8sErrors.IsConflict(err) {
+				logger.Info("Conflict on Stack status update, let's try again.")
+				return false, ctrl.Result{RequeueAfter: time.Second * 3}, nil
+			}
+			return false, ctrl.Result{}, err
+		}
+		logger.WithValues(logging.RunId, run.Id).Info("Destroy task triggered")
+		r.EventRecorder.Eventf(stack, v1.EventTypeNormal, v1beta1.EventReasonStackDestroyStarted,
+			"Destroy task %s has been triggered in Spacelift", run.Id)
+
+		// The annotation is consumed by the new task, so it is not retried again if this one fails too
+		if v1beta1.IsRetryDestroy(stack) {
+			delete(stack.Annotations, v1beta1.RetryDestroyAnnotation)
+			if err := r.StackRepository.Update(ctx, stack); err != nil {
+				if k8sErrors.IsConflict(err) {
+					logger.Info("Conflict on Stack update, let's try again.")
+					return false, ctrl.Result{RequeueAfter: time.Second * 3}, nil
+				}
+				return false, ctrl.Result{}, err
+			}
+		}
+	}
+
+	if stack.Status.DestroyRunState == v1beta1.RunStateFinished {
+		return true, ctrl.Result{}, nil
+	}
+
+	// The destroy task did not succeed, resources may still exist so we must not delete the stack.
+	// The task is triggered again once the stack is changed or the retry-destroy annotation is set,
+	// and unsetting destroyOnDelete deletes the stack anyway.
+	if stack.IsDestroyTerminated() {
+		logger.WithValues(
+			logging.RunId, stack.Status.DestroyRunId,
+			logging.RunState, stack.Status.DestroyRunState,
+		).Info("Destroy task did not finish, the stack will not be deleted")
+		r.EventRecorder.Eventf(stack, v1.EventTypeWarning, v1beta1.EventReasonStackDestroyFailed,
+			"Destroy task %s ended in state %s, change the stack or set the %s annotation to \"true\" to run it again, or unset destroyOnDelete to delete the stack anyway",
+			stack.Status.DestroyRunId, stack.Status.DestroyRunState, v1beta1.RetryDestroyAnnotation)
+		return false, ctrl.Result{}, nil
+	}
+
+	if !r.RunWatcher.IsDestroyWatched(stack) {
+		if err := r.RunWatcher.StartDestroy(ctx, stack); err != nil {
+			logger.Error(err, "Cannot start destroy task watcher")
+			return false, ctrl.Result{}, err
+		}
+	}
+
+	// The watcher reports the state of the destroy task, the stack is still checked again in case an update is missed
+	return false, ctrl.Result{RequeueAfter: r.RunWatcher.Interval}, nil
+}
+
+func (r *StackReconciler) removeStackFinalizer(ctx context.Context, stack *v1beta1.Stack) (ctrl.Result, error) {
+	return removeFinalizer(ctx, "Stack", func() error { return r.StackRepository.RemoveFinalizer(ctx, stack) })
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *StackReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := indexField(mgr, &v1beta1.Stack{}, spaceNameIndex, indexSpaceName); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Stack{}, dependsOnIndex, indexDependsOn); err != nil {
+		return err
+	}
+	if err := indexField(mgr, &v1beta1.Stack{}, secretRefIndex, indexSecretRefs); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1beta1.Stack{}).
-		WithEventFilter(predicate.Funcs{
+		For(&v1beta1.Stack{}, builder.WithPredicates(predicate.Funcs{
 			// Always handle new resource creation
 			CreateFunc: func(event.CreateEvent) bool { return true },
-			// Always handle resource update
-			UpdateFunc: func(e event.UpdateEvent) bool { return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() },
-			// We don't care about stack removal
+			// Always handle resource update, any update while the stack is being deleted, and the stack being paused or resumed
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+					!e.ObjectNew.GetDeletionTimestamp().IsZero() ||
+					pauseChanged(e)
+			},
+			// Stack removal is handled by the finalizer, once the resource is gone there is nothing left to do
 			DeleteFunc: func(event.DeleteEvent) bool { return false },
-		}).
-		Complete(r)
+		})).
+		Watches(&v1beta1.Space{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.StackList{} }, spaceNameIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1beta1.Stack{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.StackList{} }, dependsOnIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1.Secret{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.StackList{} }, secretRefIndex),
+			builder.WithPredicates(secretChanged)).
+		Watches(&v1beta1.Context{}, enqueueDependents(mgr.GetClient(), func() client.ObjectList { return &v1beta1.StackList{} }, contextNameIndex),
+			builder.WithPredicates(dependencyReady)).
+		Watches(&v1.Namespace{}, enqueueNamespace(mgr.GetClient(), func() client.ObjectList { return &v1beta1.StackList{} }),
+			builder.WithPredicates(namespacePauseChanged)).
+		Complete(tracing.Reconciler("Stack", r))
 }
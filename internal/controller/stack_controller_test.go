@@ -1,13 +1,17 @@
 package controller_test
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap/zaptest/observer"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -19,6 +23,7 @@ import (
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/mocks"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/watcher"
 	"github.com/spacelift-io/spacelift-operator/internal/utils"
 	"github.com/spacelift-io/spacelift-operator/tests/integration"
 )
@@ -27,17 +32,31 @@ type StackControllerSuite struct {
 	integration.IntegrationTestSuite
 	integration.WithStackSuiteHelper
 	integration.WithSpaceSuiteHelper
+	integration.WithEventHelper
 }
 
 func (s *StackControllerSuite) SetupSuite() {
 	s.SetupManager = func(mgr manager.Manager) {
 		s.FakeSpaceliftStackRepo = new(mocks.StackRepository)
+		s.FakeSpaceliftRunRepo = new(mocks.RunRepository)
 		s.StackRepo = repository.NewStackRepository(mgr.GetClient(), mgr.GetScheme())
 		s.SpaceRepo = repository.NewSpaceRepository(mgr.GetClient())
+		s.RunRepo = repository.NewRunRepository(mgr.GetClient(), mgr.GetScheme())
+		s.NamespaceRepo = repository.NewNamespaceRepository(mgr.GetClient())
+		s.SecretRepo = repository.NewSecretRepository(mgr.GetClient())
+		s.ContextRepo = repository.NewContextRepository(mgr.GetClient(), mgr.GetScheme())
+		runWatcher := watcher.NewRunWatcher(s.RunRepo, s.StackRepo, s.NamespaceRepo, s.FakeSpaceliftRunRepo)
+		runWatcher.Interval = integration.DefaultInterval
 		err := (&controller.StackReconciler{
 			StackRepository:          s.StackRepo,
+			NamespaceRepository:      s.NamespaceRepo,
 			SpaceRepository:          s.SpaceRepo,
+			SecretRepository:         s.SecretRepo,
+			ContextRepository:        s.ContextRepo,
 			SpaceliftStackRepository: s.FakeSpaceliftStackRepo,
+			SpaceliftRunRepository:   s.FakeSpaceliftRunRepo,
+			RunWatcher:               runWatcher,
+			EventRecorder:            mgr.GetEventRecorderFor("stack-controller"),
 		}).SetupWithManager(mgr)
 		s.Require().NoError(err)
 	}
@@ -48,10 +67,14 @@ func (s *StackControllerSuite) SetupSuite() {
 	s.WithSpaceSuiteHelper = integration.WithSpaceSuiteHelper{
 		IntegrationTestSuite: &s.IntegrationTestSuite,
 	}
+	s.WithEventHelper = integration.WithEventHelper{
+		IntegrationTestSuite: &s.IntegrationTestSuite,
+	}
 }
 
 func (s *StackControllerSuite) SetupTest() {
 	s.FakeSpaceliftStackRepo.Test(s.T())
+	s.FakeSpaceliftRunRepo.Test(s.T())
 	s.IntegrationTestSuite.SetupTest()
 }
 
@@ -59,6 +82,9 @@ func (s *StackControllerSuite) TearDownTest() {
 	s.FakeSpaceliftStackRepo.AssertExpectations(s.T())
 	s.FakeSpaceliftStackRepo.Calls = nil
 	s.FakeSpaceliftStackRepo.ExpectedCalls = nil
+	s.FakeSpaceliftRunRepo.AssertExpectations(s.T())
+	s.FakeSpaceliftRunRepo.Calls = nil
+	s.FakeSpaceliftRunRepo.ExpectedCalls = nil
 }
 
 func (s *StackControllerSuite) TestStackCreation_InvalidSpec() {
@@ -72,6 +98,15 @@ func (s *StackControllerSuite) TestStackCreation_InvalidSpec() {
 			Name:        "missing spaceName/spaceId",
 			ExpectedErr: `Stack.app.spacelift.io "invalid-stack" is invalid: spec: Invalid value: "object": only one of spaceName or spaceId can be set`,
 		},
+		{
+			Spec: v1beta1.StackSpec{
+				SpaceId:          utils.AddressOf("root"),
+				ManagementPolicy: v1beta1.ManagementPolicyObserveOnly,
+				DeletionPolicy:   v1beta1.DeletionPolicyDelete,
+			},
+			Name:        "observed stack deleted in spacelift",
+			ExpectedErr: `Stack.app.spacelift.io "invalid-stack" is invalid: spec: Invalid value: "object": deletionPolicy can't be Delete when managementPolicy is ObserveOnly`,
+		},
 	}
 
 	for _, c := range cases {
@@ -119,6 +154,20 @@ func (s *StackControllerSuite) TestStackCreation_UnableToCreateOnSpacelift() {
 	s.Require().Equal(1, logs.Len())
 	logs = s.Logs.FilterMessage("Stack created")
 	s.Require().Equal(0, logs.Len())
+
+	// Check that the failure is reported in the stack conditions
+	stack, err = s.StackRepo.Get(s.Context(), types.NamespacedName{
+		Namespace: stack.Namespace,
+		Name:      stack.ObjectMeta.Name,
+	})
+	s.Require().NoError(err)
+	synced := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionSynced)
+	s.Require().NotNil(synced)
+	s.Assert().Equal(metav1.ConditionFalse, synced.Status)
+	s.Assert().Equal(v1beta1.ReasonCreateFailed, synced.Reason)
+	s.Assert().Equal("unable to create resource on spacelift", synced.Message)
+	s.Assert().True(meta.IsStatusConditionTrue(stack.Status.Conditions, v1beta1.ConditionError))
+	s.Assert().True(meta.IsStatusConditionFalse(stack.Status.Conditions, v1beta1.ConditionReady))
 }
 
 func (s *StackControllerSuite) TestStackCreation_OK() {
@@ -151,6 +200,18 @@ func (s *StackControllerSuite) TestStackCreation_OK() {
 		return logs.Len() == 1
 	}, 3*time.Second, integration.DefaultInterval)
 
+	// Make sure the stack is reported as ready
+	stack, err = s.StackRepo.Get(s.Context(), types.NamespacedName{
+		Namespace: stack.Namespace,
+		Name:      stack.ObjectMeta.Name,
+	})
+	s.Require().NoError(err)
+	for _, condition := range []string{v1beta1.ConditionReady, v1beta1.ConditionSynced, v1beta1.ConditionDependenciesReady} {
+		s.Assert().True(meta.IsStatusConditionTrue(stack.Status.Conditions, condition), condition)
+	}
+	s.Assert().True(meta.IsStatusConditionFalse(stack.Status.Conditions, v1beta1.ConditionError))
+	s.Assert().Equal(stack.Generation, stack.Status.ObservedGeneration)
+
 	logContext := logs.All()[0].ContextMap()
 	s.Require().Contains(logContext, logging.StackId)
 	s.Assert().Equal(logContext[logging.StackId], "test-stack-generated-id")
@@ -185,10 +246,23 @@ func (s *StackControllerSuite) TestStackCreation_OK_SpaceNotReady() {
 
 	var logs *observer.ObservedLogs
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Unable to find space for stack, will retry in 10 seconds")
+		logs = s.Logs.FilterMessage("Unable to find space for stack, waiting for it to be created")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 
+	s.Require().Eventually(func() bool {
+		stack, err := s.StackRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: stack.Namespace,
+			Name:      stack.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		dependencies := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionDependenciesReady)
+		return dependencies != nil &&
+			dependencies.Status == metav1.ConditionFalse &&
+			dependencies.Reason == v1beta1.ReasonSpaceNotFound &&
+			meta.IsStatusConditionFalse(stack.Status.Conditions, v1beta1.ConditionReady)
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
 	s.Logs.TakeAll()
 
 	space := &v1beta1.Space{
@@ -210,7 +284,7 @@ func (s *StackControllerSuite) TestStackCreation_OK_SpaceNotReady() {
 	defer s.DeleteSpace(space)
 
 	s.Require().Eventually(func() bool {
-		logs = s.Logs.FilterMessage("Space is not ready yet, will retry in 3 seconds")
+		logs = s.Logs.FilterMessage("Space is not ready yet, waiting for it")
 		return logs.Len() == 1
 	}, integration.DefaultTimeout, integration.DefaultInterval)
 
@@ -296,6 +370,59 @@ func (s *StackControllerSuite) TestStackCreationWithSpaceName_OK() {
 	s.Assert().Equal(logContext[logging.StackId], "test-stack-generated-id")
 }
 
+func (s *StackControllerSuite) TestStackCreation_OK_SecretNotFound() {
+	stack := integration.DefaultValidStack.DeepCopy()
+	stack.Spec.Environment = []v1beta1.Environment{
+		{Id: "PLAIN", Value: utils.AddressOf("plain")},
+		{Id: "TOKEN", ValueFromSecret: &v1.SecretKeySelector{
+			LocalObjectReference: v1.LocalObjectReference{Name: "test-stack-secret"},
+			Key:                  "token",
+		}},
+	}
+
+	s.Logs.TakeAll()
+	stack, err := s.CreateStack(stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(stack)
+
+	var logs *observer.ObservedLogs
+	s.Require().Eventually(func() bool {
+		logs = s.Logs.FilterMessage("Unable to find secret for stack, waiting for it to be created")
+		return logs.Len() >= 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Equal("test-stack-secret", logs.All()[0].ContextMap()[logging.SecretName])
+
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(nil, spaceliftRepository.ErrStackNotFound)
+	var environment []v1beta1.Environment
+	s.FakeSpaceliftStackRepo.EXPECT().Create(mock.Anything, mock.Anything).
+		Run(func(_ context.Context, stack *v1beta1.Stack) {
+			environment = stack.Spec.Environment
+		}).Once().
+		Return(&models.Stack{Id: "test-stack-generated-id"}, nil)
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-stack-secret", Namespace: stack.Namespace},
+		Data:       map[string][]byte{"token": []byte("secret_value")},
+	}
+	s.Require().NoError(s.Client().Create(s.Context(), secret))
+	defer s.Client().Delete(s.Context(), secret)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack created").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.Require().Len(environment, 2)
+	s.Assert().Nil(environment[0].Secret)
+	s.Require().NotNil(environment[1].Value)
+	s.Assert().Equal("secret_value", *environment[1].Value)
+	s.Assert().Equal(utils.AddressOf(true), environment[1].Secret)
+
+	stack, err = s.StackRepo.Get(s.Context(), types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name})
+	s.Require().NoError(err)
+	s.Assert().NotEmpty(stack.Status.SecretsHash)
+}
+
 func (s *StackControllerSuite) TestStackUpdate_UnableToUpdateOnSpacelift() {
 	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Times(2).
 		Return(nil, nil)
@@ -358,6 +485,408 @@ func (s *StackControllerSuite) TestStackUpdate_OK() {
 	s.Assert().Equal(logContext[logging.StackId], "test-stack-generated-id")
 }
 
+func (s *StackControllerSuite) TestStackAdoption_OK() {
+	fakeStack := &models.Stack{
+		Id: "existing-stack-id",
+	}
+
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	// Create is not expected on the spacelift repository, the mock would fail otherwise
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Annotations = map[string]string{v1beta1.AdoptAnnotation: "existing-stack-id"}
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(&stack)
+
+	s.Require().Eventually(func() bool {
+		stack, err := s.StackRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: stack.Namespace,
+			Name:      stack.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		return stack.Status.Id == "existing-stack-id"
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	logs := s.Logs.FilterMessage("Stack adopted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("existing-stack-id", logs.All()[0].ContextMap()[logging.StackId])
+	events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonAdopted)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeNormal, events[0].Type)
+}
+
+func (s *StackControllerSuite) TestStackAdoption_NotFound() {
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(nil, spaceliftRepository.ErrStackNotFound)
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Annotations = map[string]string{v1beta1.AdoptAnnotation: "missing-stack-id"}
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(&stack)
+
+	// The stack must not be created in spacelift, the mock would fail otherwise
+	s.Require().Eventually(func() bool {
+		events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonAdoptionFailed)
+		s.Require().NoError(err)
+		return len(events) > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Equal(0, s.Logs.FilterMessage("Stack created").Len())
+
+	s.Require().Eventually(func() bool {
+		stack, err := s.StackRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: stack.Namespace,
+			Name:      stack.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		synced := meta.FindStatusCondition(stack.Status.Conditions, v1beta1.ConditionSynced)
+		return synced != nil && synced.Reason == v1beta1.ReasonAdoptionFailed && stack.Status.Id == ""
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+}
+
+func (s *StackControllerSuite) TestStackDryRun() {
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(nil, spaceliftRepository.ErrStackNotFound)
+
+	stack := integration.DefaultValidStack
+	stack.Annotations = map[string]string{v1beta1.DryRunAnnotation: "true"}
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(&stack)
+
+	// The stack must not be created in spacelift, the mock would fail otherwise
+	s.Require().Eventually(func() bool {
+		stack, err := s.StackRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: stack.Namespace,
+			Name:      stack.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		return meta.IsStatusConditionTrue(stack.Status.Conditions, v1beta1.ConditionDryRun) &&
+			slices.Contains(stack.Status.PlannedChanges, v1beta1.FieldChange{Field: "branch", Desired: "fake-branch"})
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonDryRun)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(0, s.Logs.FilterMessage("Stack created").Len())
+}
+
+func (s *StackControllerSuite) TestStackObserveOnly() {
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(&models.Stack{
+			Id:      "existing-stack",
+			Url:     "https://example.app.spacelift.io/stack/existing-stack",
+			Name:    "Existing stack",
+			Labels:  []string{"team:infra"},
+			SpaceId: "root",
+			State:   "FINISHED",
+		}, nil)
+
+	stack := integration.DefaultValidStack
+	stack.Spec.ManagementPolicy = v1beta1.ManagementPolicyObserveOnly
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(&stack)
+
+	// The stack must never be created nor updated in spacelift, the mock would fail otherwise
+	s.Require().Eventually(func() bool {
+		stack, err := s.StackRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: stack.Namespace,
+			Name:      stack.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		return stack.Ready() && stack.Status.Observed != nil
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	observed, err := s.StackRepo.Get(s.Context(), types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name})
+	s.Require().NoError(err)
+	s.Assert().Equal("existing-stack", observed.Status.Id)
+	s.Assert().Equal(&v1beta1.ObservedState{
+		Name:   "Existing stack",
+		Space:  "root",
+		Labels: []string{"team:infra"},
+		State:  "FINISHED",
+		URL:    "https://example.app.spacelift.io/stack/existing-stack",
+	}, observed.Status.Observed)
+	s.Assert().True(meta.IsStatusConditionTrue(observed.Status.Conditions, v1beta1.ConditionReady))
+}
+
+func (s *StackControllerSuite) TestStackObserveOnly_NotFound() {
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(nil, spaceliftRepository.ErrStackNotFound)
+
+	stack := integration.DefaultValidStack
+	stack.Spec.ManagementPolicy = v1beta1.ManagementPolicyObserveOnly
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+	defer s.DeleteStack(&stack)
+
+	s.Require().Eventually(func() bool {
+		events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonObserveFailed)
+		s.Require().NoError(err)
+		return len(events) > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+	s.Assert().Equal(0, s.Logs.FilterMessage("Stack created").Len())
+}
+
+func (s *StackControllerSuite) TestStackDeletion_OrphanByDefault() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+
+	s.Logs.TakeAll()
+	stack, err := s.CreateTestStack()
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	stack, err = s.StackRepo.Get(s.Context(), types.NamespacedName{
+		Namespace: stack.Namespace,
+		Name:      stack.ObjectMeta.Name,
+	})
+	s.Require().NoError(err)
+	s.Assert().Equal(v1beta1.DeletionPolicyOrphan, stack.Spec.DeletionPolicy)
+	s.Assert().Empty(stack.Finalizers)
+
+	// Delete is not expected on the spacelift repository, the mock would fail otherwise
+	s.DeleteStack(stack)
+}
+
+func (s *StackControllerSuite) TestStackDeletion_OK() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Twice().
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	// Make sure the finalizer is set
+	s.Require().Eventually(func() bool {
+		stack, err := s.StackRepo.Get(s.Context(), types.NamespacedName{
+			Namespace: stack.Namespace,
+			Name:      stack.ObjectMeta.Name,
+		})
+		s.Require().NoError(err)
+		return slices.Contains(stack.Finalizers, v1beta1.SpaceliftFinalizer)
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.DeleteStack(&stack)
+
+	logs := s.Logs.FilterMessage("Stack deleted")
+	s.Require().Equal(1, logs.Len())
+	s.Assert().Equal("test-stack-generated-id", logs.All()[0].ContextMap()[logging.StackId])
+}
+
+func (s *StackControllerSuite) TestStackDeletion_ProtectedFromDeletion() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.ProtectFromDeletion = utils.AddressOf(true)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.DeleteStack(&stack)
+
+	events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonStackOrphaned)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeWarning, events[0].Type)
+}
+
+func (s *StackControllerSuite) TestStackDeletion_UnableToDeleteOnSpacelift() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	failedDelete := s.FakeSpaceliftStackRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(fmt.Errorf("unable to delete resource on spacelift"))
+	s.FakeSpaceliftStackRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil).NotBefore(failedDelete)
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.DeleteStack(&stack)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Unable to delete the stack in spacelift").Len())
+	events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonStackDeletionFailed)
+	s.Require().NoError(err)
+	s.Require().Len(events, 1)
+	s.Assert().Equal(v1.EventTypeWarning, events[0].Type)
+}
+
+func (s *StackControllerSuite) TestStackDeletion_DestroyOnDelete() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	s.FakeSpaceliftRunRepo.EXPECT().CreateDestroyTask(mock.Anything, mock.Anything).Once().
+		Return(&models.Run{Id: "destroy-run-id", State: string(v1beta1.RunStateQueued), StackId: fakeStack.Id}, nil)
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, mock.MatchedBy(func(runs []models.Run) bool {
+		return len(runs) == 1 && runs[0].Id == "destroy-run-id" && runs[0].StackId == fakeStack.Id
+	})).RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFinished))
+	s.FakeSpaceliftStackRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.DestroyOnDelete = utils.AddressOf(true)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.DeleteStack(&stack)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Destroy task triggered").Len())
+	s.Assert().Equal(1, s.Logs.FilterMessage("Stack deleted").Len())
+}
+
+func (s *StackControllerSuite) TestStackDeletion_DestroyOnDelete_DestroyFailed() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	s.FakeSpaceliftRunRepo.EXPECT().CreateDestroyTask(mock.Anything, mock.Anything).Once().
+		Return(&models.Run{Id: "destroy-run-id", State: string(v1beta1.RunStateQueued), StackId: fakeStack.Id}, nil)
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, mock.Anything).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFailed))
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.DestroyOnDelete = utils.AddressOf(true)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	err = s.Client().Delete(s.Context(), &stack)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Destroy task did not finish, the stack will not be deleted").Len() > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	events, err := s.FindEvents(types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}, v1beta1.EventReasonStackDestroyFailed)
+	s.Require().NoError(err)
+	s.Require().NotEmpty(events)
+
+	// Unsetting destroyOnDelete releases the stack
+	s.FakeSpaceliftStackRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+	current, err := s.StackRepo.Get(s.Context(), types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name})
+	s.Require().NoError(err)
+	current.Spec.DestroyOnDelete = utils.AddressOf(false)
+	s.Require().NoError(s.StackRepo.Update(s.Context(), current))
+	s.WaitUntilStackRemoved(&stack)
+}
+
+func (s *StackControllerSuite) TestStackDeletion_DestroyOnDelete_RetryDestroy() {
+	fakeStack := &models.Stack{
+		Id: "test-stack-generated-id",
+	}
+	runIs := func(id string) any {
+		return mock.MatchedBy(func(runs []models.Run) bool { return len(runs) == 1 && runs[0].Id == id })
+	}
+	s.FakeSpaceliftStackRepo.EXPECT().Get(mock.Anything, mock.Anything).
+		Return(fakeStack, nil)
+	s.FakeSpaceliftStackRepo.EXPECT().Update(mock.Anything, mock.Anything).Once().
+		Return(fakeStack, nil)
+	failedTask := s.FakeSpaceliftRunRepo.EXPECT().CreateDestroyTask(mock.Anything, mock.Anything).Once().
+		Return(&models.Run{Id: "destroy-run-id", State: string(v1beta1.RunStateQueued), StackId: fakeStack.Id}, nil)
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, runIs("destroy-run-id")).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFailed))
+
+	s.Logs.TakeAll()
+	stack := integration.DefaultValidStack
+	stack.Spec.DeletionPolicy = v1beta1.DeletionPolicyDelete
+	stack.Spec.DestroyOnDelete = utils.AddressOf(true)
+	_, err := s.CreateStack(&stack)
+	s.Require().NoError(err)
+
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Stack updated").Len() == 1
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	s.Require().NoError(s.Client().Delete(s.Context(), &stack))
+	s.Require().Eventually(func() bool {
+		return s.Logs.FilterMessage("Destroy task did not finish, the stack will not be deleted").Len() > 0
+	}, integration.DefaultTimeout, integration.DefaultInterval)
+
+	// The annotation triggers a new destroy task, the stack is deleted once it has finished
+	s.FakeSpaceliftRunRepo.EXPECT().CreateDestroyTask(mock.Anything, mock.Anything).Once().
+		Return(&models.Run{Id: "retry-run-id", State: string(v1beta1.RunStateQueued), StackId: fakeStack.Id}, nil).
+		NotBefore(failedTask)
+	s.FakeSpaceliftRunRepo.EXPECT().GetStates(mock.Anything, mock.Anything, runIs("retry-run-id")).
+		RunAndReturn(integration.ReturnRunStates(v1beta1.RunStateFinished))
+	s.FakeSpaceliftStackRepo.EXPECT().Delete(mock.Anything, mock.Anything).Once().
+		Return(nil)
+	current, err := s.StackRepo.Get(s.Context(), types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name})
+	s.Require().NoError(err)
+	current.Annotations = map[string]string{v1beta1.RetryDestroyAnnotation: "true"}
+	s.Require().NoError(s.StackRepo.Update(s.Context(), current))
+	s.WaitUntilStackRemoved(&stack)
+
+	s.Assert().Equal(1, s.Logs.FilterMessage("Retrying the destroy task").Len())
+	s.Assert().Equal(1, s.Logs.FilterMessage("Stack deleted").Len())
+}
+
 func TestStackController(t *testing.T) {
 	suite.Run(t, new(StackControllerSuite))
 }
@@ -7,6 +7,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
@@ -38,3 +39,36 @@ func (r *ContextRepository) SetOwner(ctx context.Context, context *v1beta1.Conte
 	}
 	return r.client.Update(ctx, context)
 }
+
+func (r *ContextRepository) AddFinalizer(ctx context.Context, context *v1beta1.Context) error {
+	if !controllerutil.AddFinalizer(context, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, context)
+}
+
+func (r *ContextRepository) RemoveFinalizer(ctx context.Context, context *v1beta1.Context) error {
+	if !controllerutil.RemoveFinalizer(context, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, context)
+}
+
+// ListBySpace returns the contexts of the namespace that reference the given space by name, or by ID once the space is synced.
+func (r *ContextRepository) ListBySpace(ctx context.Context, space *v1beta1.Space) ([]v1beta1.Context, error) {
+	var list v1beta1.ContextList
+	if err := r.client.List(ctx, &list, client.InNamespace(space.Namespace)); err != nil {
+		return nil, err
+	}
+	var contexts []v1beta1.Context
+	for _, context := range list.Items {
+		if inSpace(space, context.Spec.SpaceName, context.Spec.SpaceId) {
+			contexts = append(contexts, context)
+		}
+	}
+	return contexts, nil
+}
+
+func (r *ContextRepository) Delete(ctx context.Context, context *v1beta1.Context) error {
+	return client.IgnoreNotFound(r.client.Delete(ctx, context))
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+type NamespaceRepository struct {
+	client client.Client
+}
+
+func NewNamespaceRepository(client client.Client) *NamespaceRepository {
+	return &NamespaceRepository{client: client}
+}
+
+func (r *NamespaceRepository) Get(ctx context.Context, name string) (*v1.Namespace, error) {
+	namespace := &v1.Namespace{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: name}, namespace); err != nil {
+		return nil, err
+	}
+	return namespace, nil
+}
+
+// IsPaused returns true if the reconciliation of every resource of the namespace is paused, see v1beta1.PausedAnnotation.
+func (r *NamespaceRepository) IsPaused(ctx context.Context, name string) (bool, error) {
+	namespace, err := r.Get(ctx, name)
+	if k8sErrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return v1beta1.IsPaused(namespace), nil
+}
@@ -7,6 +7,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
@@ -42,3 +43,36 @@ func (r *PolicyRepository) SetOwner(ctx context.Context, policy *v1beta1.Policy,
 	}
 	return r.client.Update(ctx, policy)
 }
+
+func (r *PolicyRepository) AddFinalizer(ctx context.Context, policy *v1beta1.Policy) error {
+	if !controllerutil.AddFinalizer(policy, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, policy)
+}
+
+func (r *PolicyRepository) RemoveFinalizer(ctx context.Context, policy *v1beta1.Policy) error {
+	if !controllerutil.RemoveFinalizer(policy, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, policy)
+}
+
+// ListBySpace returns the policies of the namespace that reference the given space by name, or by ID once the space is synced.
+func (r *PolicyRepository) ListBySpace(ctx context.Context, space *v1beta1.Space) ([]v1beta1.Policy, error) {
+	var list v1beta1.PolicyList
+	if err := r.client.List(ctx, &list, client.InNamespace(space.Namespace)); err != nil {
+		return nil, err
+	}
+	var policies []v1beta1.Policy
+	for _, policy := range list.Items {
+		if inSpace(space, policy.Spec.SpaceName, policy.Spec.SpaceId) {
+			policies = append(policies, policy)
+		}
+	}
+	return policies, nil
+}
+
+func (r *PolicyRepository) Delete(ctx context.Context, policy *v1beta1.Policy) error {
+	return client.IgnoreNotFound(r.client.Delete(ctx, policy))
+}
@@ -7,6 +7,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
@@ -28,6 +29,21 @@ func (r *RunRepository) Get(ctx context.Context, name types.NamespacedName) (*v1
 	return &run, nil
 }
 
+// ListByRunId returns the runs of any namespace bound to the given spacelift run
+func (r *RunRepository) ListByRunId(ctx context.Context, runId string) ([]v1beta1.Run, error) {
+	var list v1beta1.RunList
+	if err := r.client.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	var runs []v1beta1.Run
+	for _, run := range list.Items {
+		if run.Status.Id == runId {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
 func (r *RunRepository) SetOwner(ctx context.Context, run *v1beta1.Run, stack *v1beta1.Stack) error {
 	if err := ctrl.SetControllerReference(stack, run, r.scheme); err != nil {
 		return err
@@ -42,3 +58,17 @@ func (r *RunRepository) Update(ctx context.Context, run *v1beta1.Run) error {
 func (r *RunRepository) UpdateStatus(ctx context.Context, run *v1beta1.Run) error {
 	return r.client.Status().Update(ctx, run)
 }
+
+func (r *RunRepository) AddFinalizer(ctx context.Context, run *v1beta1.Run) error {
+	if !controllerutil.AddFinalizer(run, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, run)
+}
+
+func (r *RunRepository) RemoveFinalizer(ctx context.Context, run *v1beta1.Run) error {
+	if !controllerutil.RemoveFinalizer(run, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, run)
+}
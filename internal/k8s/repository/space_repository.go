@@ -5,6 +5,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
@@ -32,3 +33,38 @@ func (r *SpaceRepository) Update(ctx context.Context, space *v1beta1.Space) erro
 func (r *SpaceRepository) UpdateStatus(ctx context.Context, space *v1beta1.Space) error {
 	return r.client.Status().Update(ctx, space)
 }
+
+func (r *SpaceRepository) AddFinalizer(ctx context.Context, space *v1beta1.Space) error {
+	if !controllerutil.AddFinalizer(space, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, space)
+}
+
+func (r *SpaceRepository) RemoveFinalizer(ctx context.Context, space *v1beta1.Space) error {
+	if !controllerutil.RemoveFinalizer(space, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, space)
+}
+
+// ListByParentSpace returns the spaces of the namespace whose parent is the given spacelift space ID.
+func (r *SpaceRepository) ListByParentSpace(ctx context.Context, namespace, parentSpaceId string) ([]v1beta1.Space, error) {
+	var list v1beta1.SpaceList
+	if err := r.client.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var spaces []v1beta1.Space
+	for _, space := range list.Items {
+		if space.Spec.ParentSpace == parentSpaceId {
+			spaces = append(spaces, space)
+		}
+	}
+	return spaces, nil
+}
+
+// inSpace returns true when a resource references the space by name, or by ID once the space is synced.
+func inSpace(space *v1beta1.Space, spaceName, spaceId *string) bool {
+	return (spaceName != nil && *spaceName == space.ObjectMeta.Name) ||
+		(spaceId != nil && space.Status.Id != "" && *spaceId == space.Status.Id)
+}
@@ -2,15 +2,68 @@ package repository
 
 import (
 	"context"
+	"slices"
 
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
 
+// Field indexes of the stacks, registered for the manager by IndexStacks.
+const (
+	StackContextNameIndex  = ".spec.contexts.contextName"
+	StackContextIdIndex    = ".spec.contexts.contextId"
+	StackDestroyRunIdIndex = ".status.destroyRunId"
+)
+
+// IndexStacks registers the field indexes used to list the stacks. It must be called once per manager, before it starts.
+func IndexStacks(ctx context.Context, indexer client.FieldIndexer) error {
+	contextNames := indexContexts(func(c v1beta1.StackContext) *string { return c.ContextName })
+	if err := indexer.IndexField(ctx, &v1beta1.Stack{}, StackContextNameIndex, contextNames); err != nil {
+		return errors.Wrapf(err, "unable to index stacks by %s", StackContextNameIndex)
+	}
+	contextIds := indexContexts(func(c v1beta1.StackContext) *string { return c.ContextId })
+	if err := indexer.IndexField(ctx, &v1beta1.Stack{}, StackContextIdIndex, contextIds); err != nil {
+		return errors.Wrapf(err, "unable to index stacks by %s", StackContextIdIndex)
+	}
+	if err := indexer.IndexField(ctx, &v1beta1.Stack{}, StackDestroyRunIdIndex, indexDestroyRunId); err != nil {
+		return errors.Wrapf(err, "unable to index stacks by %s", StackDestroyRunIdIndex)
+	}
+	return nil
+}
+
+// indexDestroyRunId indexes the stacks waiting for a destroy task by the ID of the task.
+func indexDestroyRunId(obj client.Object) []string {
+	stack, ok := obj.(*v1beta1.Stack)
+	if !ok || stack.Status.DestroyRunId == "" {
+		return nil
+	}
+	return []string{stack.Status.DestroyRunId}
+}
+
+// indexContexts indexes the stacks by the given reference of the contexts of their spec.contexts.
+func indexContexts(reference func(v1beta1.StackContext) *string) client.IndexerFunc {
+	return func(obj client.Object) []string {
+		stack, ok := obj.(*v1beta1.Stack)
+		if !ok {
+			return nil
+		}
+		var references []string
+		for _, stackContext := range stack.Spec.Contexts {
+			if ref := reference(stackContext); ref != nil {
+				references = append(references, *ref)
+			}
+		}
+		return references
+	}
+}
+
 type StackRepository struct {
 	client client.Client
 	scheme *runtime.Scheme
@@ -42,3 +95,76 @@ func (r *StackRepository) SetOwner(ctx context.Context, stack *v1beta1.Stack, sp
 func (r *StackRepository) UpdateStatus(ctx context.Context, stack *v1beta1.Stack) error {
 	return r.client.Status().Update(ctx, stack)
 }
+
+func (r *StackRepository) AddFinalizer(ctx context.Context, stack *v1beta1.Stack) error {
+	if !controllerutil.AddFinalizer(stack, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, stack)
+}
+
+func (r *StackRepository) RemoveFinalizer(ctx context.Context, stack *v1beta1.Stack) error {
+	if !controllerutil.RemoveFinalizer(stack, v1beta1.SpaceliftFinalizer) {
+		return nil
+	}
+	return r.client.Update(ctx, stack)
+}
+
+// ListBySpace returns the stacks of the namespace that reference the given space by name, or by ID once the space is synced.
+func (r *StackRepository) ListBySpace(ctx context.Context, space *v1beta1.Space) ([]v1beta1.Stack, error) {
+	var list v1beta1.StackList
+	if err := r.client.List(ctx, &list, client.InNamespace(space.Namespace)); err != nil {
+		return nil, err
+	}
+	var stacks []v1beta1.Stack
+	for _, stack := range list.Items {
+		if inSpace(space, stack.Spec.SpaceName, stack.Spec.SpaceId) {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks, nil
+}
+
+// ListBySelector returns the stacks of the namespace whose labels match the selector.
+func (r *StackRepository) ListBySelector(ctx context.Context, namespace string, selector labels.Selector) ([]v1beta1.Stack, error) {
+	var list v1beta1.StackList
+	if err := r.client.List(ctx, &list, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// ListByContext returns the stacks of the namespace that attach the given context in spec.contexts, by name or by ID.
+func (r *StackRepository) ListByContext(ctx context.Context, c *v1beta1.Context) ([]v1beta1.Stack, error) {
+	var byName v1beta1.StackList
+	if err := r.client.List(ctx, &byName, client.InNamespace(c.Namespace), client.MatchingFields{StackContextNameIndex: c.ObjectMeta.Name}); err != nil {
+		return nil, err
+	}
+	stacks := byName.Items
+	if c.Status.Id == "" {
+		return stacks, nil
+	}
+	var byId v1beta1.StackList
+	if err := r.client.List(ctx, &byId, client.InNamespace(c.Namespace), client.MatchingFields{StackContextIdIndex: c.Status.Id}); err != nil {
+		return nil, err
+	}
+	for _, stack := range byId.Items {
+		if !slices.ContainsFunc(stacks, func(s v1beta1.Stack) bool { return s.ObjectMeta.Name == stack.ObjectMeta.Name }) {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks, nil
+}
+
+// ListByDestroyRunId returns the stacks of any namespace waiting for the given destroy task
+func (r *StackRepository) ListByDestroyRunId(ctx context.Context, runId string) ([]v1beta1.Stack, error) {
+	var list v1beta1.StackList
+	if err := r.client.List(ctx, &list, client.MatchingFields{StackDestroyRunIdIndex: runId}); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (r *StackRepository) Delete(ctx context.Context, stack *v1beta1.Stack) error {
+	return client.IgnoreNotFound(r.client.Delete(ctx, stack))
+}
@@ -6,13 +6,19 @@ const (
 	RunId    = "run.id"
 	RunState = "run.state"
 
-	StackName             = "stack.name"
-	StackId               = "stack.id"
-	StackAWSIntegrationId = "stack.aws_integration_id"
+	StackName               = "stack.name"
+	StackId                 = "stack.id"
+	StackAWSIntegrationId   = "stack.aws_integration_id"
+	StackAzureIntegrationId = "stack.azure_integration_id"
+	StackDependencyId       = "stack.dependency_id"
+	StackConfigId           = "stack.config_id"
+	StackContextId          = "stack.context_id"
 
 	SpaceId   = "space.id"
 	SpaceName = "space.name"
 
+	SpaceChildren = "space.children"
+
 	SecretName = "secret.name"
 	SecretKey  = "secret.key"
 
@@ -25,4 +31,10 @@ const (
 	PolicyName         = "policy.name"
 	PolicyType         = "policy.type"
 	PolicyAttachmentId = "policy.attachment_id"
+
+	DriftFields = "drift.fields"
+
+	DryRunFields = "dry_run.fields"
+
+	TraceId = "trace.id"
 )
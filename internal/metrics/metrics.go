@@ -0,0 +1,64 @@
+// Package metrics defines the operator specific prometheus metrics.
+// They are registered on the controller-runtime registry, and exposed on --metrics-bind-address along with the controller-runtime ones.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "spacelift_operator"
+
+const (
+	// OperationTypeQuery labels the metrics of spacelift graphql queries
+	OperationTypeQuery = "query"
+	// OperationTypeMutation labels the metrics of spacelift graphql mutations
+	OperationTypeMutation = "mutation"
+)
+
+const (
+	// RefreshReasonExpired is used when a token is refreshed because it is about to expire
+	RefreshReasonExpired = "expired"
+	// RefreshReasonUnauthorized is used when a token is refreshed because spacelift rejected it
+	RefreshReasonUnauthorized = "unauthorized"
+)
+
+var (
+	// APIRequestDuration observes the latency of spacelift API calls, including the retry after an unauthorized response
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of the Spacelift API requests by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+
+	// APIRequestErrors counts the spacelift API calls that returned an error
+	APIRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_request_errors_total",
+		Help:      "Number of Spacelift API requests that returned an error by operation.",
+	}, []string{"operation", "type"})
+
+	// TokenRefreshes counts the exchanges of the API key for a new token
+	TokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_token_refreshes_total",
+		Help:      "Number of Spacelift API token refreshes by reason.",
+	}, []string{"reason"})
+
+	// WatchedRuns is the number of runs and destroy tasks currently polled by the run watcher
+	WatchedRuns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watched_runs",
+		Help:      "Number of Spacelift runs currently watched by the operator.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		APIRequestDuration,
+		APIRequestErrors,
+		TokenRefreshes,
+		WatchedRuns,
+	)
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+// runStateUnknown labels the runs that have not been created in spacelift yet
+const runStateUnknown = "UNKNOWN"
+
+var runsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "runs"),
+	"Number of Run resources by state.",
+	[]string{"namespace", "state"}, nil,
+)
+
+// RunStateCollector counts the Run resources per state each time metrics are scraped.
+// Runs are listed through the given reader, which should be the manager cached client.
+type RunStateCollector struct {
+	reader  client.Reader
+	timeout time.Duration
+}
+
+func NewRunStateCollector(reader client.Reader) *RunStateCollector {
+	return &RunStateCollector{reader: reader, timeout: 5 * time.Second}
+}
+
+func (c *RunStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- runsDesc
+}
+
+func (c *RunStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var runs v1beta1.RunList
+	if err := c.reader.List(ctx, &runs); err != nil {
+		log.Log.WithName("metrics").Error(err, "Unable to list runs")
+		ch <- prometheus.NewInvalidMetric(runsDesc, err)
+		return
+	}
+
+	type key struct{ namespace, state string }
+	counts := map[key]int{}
+	for _, run := range runs.Items {
+		state := string(run.Status.State)
+		if state == "" {
+			state = runStateUnknown
+		}
+		counts[key{run.Namespace, state}]++
+	}
+	for k, count := range counts {
+		ch <- prometheus.MustNewConstMetric(runsDesc, prometheus.GaugeValue, float64(count), k.namespace, k.state)
+	}
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+func TestRunStateCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+
+	run := func(namespace, name string, state v1beta1.RunState) *v1beta1.Run {
+		return &v1beta1.Run{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     v1beta1.RunStatus{State: state},
+		}
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		run("default", "queued-1", v1beta1.RunStateQueued),
+		run("default", "queued-2", v1beta1.RunStateQueued),
+		run("default", "finished", v1beta1.RunStateFinished),
+		run("other", "queued", v1beta1.RunStateQueued),
+		run("other", "new", ""),
+	).Build()
+
+	expected := `
+# HELP spacelift_operator_runs Number of Run resources by state.
+# TYPE spacelift_operator_runs gauge
+spacelift_operator_runs{namespace="default",state="FINISHED"} 1
+spacelift_operator_runs{namespace="default",state="QUEUED"} 2
+spacelift_operator_runs{namespace="other",state="QUEUED"} 1
+spacelift_operator_runs{namespace="other",state="UNKNOWN"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(NewRunStateCollector(reader), strings.NewReader(expected)))
+}
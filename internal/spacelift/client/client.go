@@ -5,26 +5,28 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/shurcooL/graphql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/metrics"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/client/session"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
-// spaceliftClient is the authenticated client that can be used to interact with Spacelift
-var spaceliftClient Client
-
-// secretVersion stores the version of the secret currently in use by the client
-// This allows to recreate the spaceliftClient if the secret has been changed in the cluster
-var secretVersion string
-
 const (
 	SecretName                 = "spacelift-credentials"      //nolint:gosec
 	SpaceliftApiKeyEndpointKey = "SPACELIFT_API_KEY_ENDPOINT" //nolint:gosec
@@ -32,103 +34,298 @@ const (
 	SpaceliftApiKeySecretKey   = "SPACELIFT_API_KEY_SECRET"   //nolint:gosec
 )
 
+// Account identifies the spacelift credentials of a resource, see AccountOf.
+// It is comparable, so it is used to cache clients and to group requests sent with the same credentials.
+type Account struct {
+	// Kind is one of the v1beta1.AccountKind constants, or empty for the spacelift-credentials secret of the namespace
+	Kind string
+	Name string
+	// Namespace is empty for a ClusterSpaceliftAccount
+	Namespace string
+}
+
+// AccountOf returns the account referenced by the spec.accountRef of a resource
+func AccountOf(obj v1beta1.AccountObject) Account {
+	ref := obj.GetAccountRef()
+	switch {
+	case ref == nil:
+		return Account{Namespace: obj.GetNamespace()}
+	case ref.Kind == v1beta1.AccountKindClusterSpaceliftAccount:
+		return Account{Kind: ref.Kind, Name: ref.Name}
+	default:
+		return Account{Kind: v1beta1.AccountKindSpaceliftAccount, Name: ref.Name, Namespace: obj.GetNamespace()}
+	}
+}
+
+func (a Account) String() string {
+	switch a.Kind {
+	case "":
+		return a.Namespace + "/" + SecretName
+	case v1beta1.AccountKindClusterSpaceliftAccount:
+		return a.Kind + "/" + a.Name
+	default:
+		return a.Kind + "/" + a.Namespace + "/" + a.Name
+	}
+}
+
+// cachedClient is an authenticated client, along with the secret it has been created from.
+// The client is created again when the account references another secret, or when the secret is changed in the cluster.
+type cachedClient struct {
+	client          Client
+	secret          types.NamespacedName
+	secretVersion   string
+	caBundleVersion string
+}
+
+var (
+	clientsLock sync.Mutex
+	clients     = map[Account]cachedClient{}
+)
+
 var DefaultClient = GetSpaceliftClient
 
-func GetSpaceliftClient(ctx context.Context, client k8sclient.Client, namespace string) (Client, error) {
+//+kubebuilder:rbac:groups=app.spacelift.io,resources=spaceliftaccounts;clusterspaceliftaccounts,verbs=get;list;watch
+
+// GetSpaceliftClient returns a client authenticated with the credentials of the account
+func GetSpaceliftClient(ctx context.Context, client k8sclient.Client, account Account) (Client, error) {
+	secretName, err := credentialsSecret(ctx, client, account)
+	if err != nil {
+		return nil, err
+	}
+
 	var secret v1.Secret
-	if err := client.Get(
-		ctx,
-		k8sclient.ObjectKey{
-			Namespace: namespace,
-			Name:      SecretName,
-		},
-		&secret,
-	); err != nil {
+	if err := client.Get(ctx, secretName, &secret); err != nil {
 		return nil, errors.Wrap(err, "failed to get spacelift credentials secret")
 	}
 
+	caBundle, err := caBundle(ctx, client, &secret)
+	if err != nil {
+		return nil, err
+	}
+	var caBundleVersion string
+	if caBundle != nil {
+		caBundleVersion = caBundle.GetResourceVersion()
+	}
+
+	clientsLock.Lock()
+	cached, found := clients[account]
+	clientsLock.Unlock()
+	if found && cached.secret == secretName && cached.secretVersion == secret.GetResourceVersion() && cached.caBundleVersion == caBundleVersion {
+		return cached.client, nil
+	}
+
 	apiEndpoint := string(secret.Data[SpaceliftApiKeyEndpointKey])
 	apiKeyID := string(secret.Data[SpaceliftApiKeyIDKey])
 	apiKeySecret := string(secret.Data[SpaceliftApiKeySecretKey])
+	if apiEndpoint == "" || apiKeyID == "" || apiKeySecret == "" {
+		return nil, errors.Errorf("secret %s must set %s, %s and %s", secretName, SpaceliftApiKeyEndpointKey, SpaceliftApiKeyIDKey, SpaceliftApiKeySecretKey)
+	}
 
-	currentSecretVersion := secret.GetResourceVersion()
-	if spaceliftClient != nil && currentSecretVersion == secretVersion {
-		return spaceliftClient, nil
+	httpClient, err := httpClient(&secret, caBundle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "secret %s has an invalid transport configuration", secretName)
 	}
 
 	session, err := func() (session.Session, error) {
 		sessionCtx, cancel := context.WithTimeout(ctx, time.Second*5)
 		defer cancel()
-		return session.New(sessionCtx, http.DefaultClient, apiEndpoint, apiKeyID, apiKeySecret)
+		return session.New(sessionCtx, httpClient, apiEndpoint, apiKeyID, apiKeySecret)
 	}()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create session from Spacelift API key")
 	}
 
-	spaceliftClient = New(http.DefaultClient, session)
-	secretVersion = currentSecretVersion
+	spaceliftClient := New(httpClient, session)
+	clientsLock.Lock()
+	clients[account] = cachedClient{
+		client:          spaceliftClient,
+		secret:          secretName,
+		secretVersion:   secret.GetResourceVersion(),
+		caBundleVersion: caBundleVersion,
+	}
+	clientsLock.Unlock()
 
 	return spaceliftClient, nil
 }
 
+// credentialsSecret returns the name of the secret holding the API key of the account
+func credentialsSecret(ctx context.Context, client k8sclient.Client, account Account) (types.NamespacedName, error) {
+	switch account.Kind {
+	case "":
+		return types.NamespacedName{Namespace: account.Namespace, Name: SecretName}, nil
+	case v1beta1.AccountKindClusterSpaceliftAccount:
+		var clusterAccount v1beta1.ClusterSpaceliftAccount
+		if err := client.Get(ctx, types.NamespacedName{Name: account.Name}, &clusterAccount); err != nil {
+			return types.NamespacedName{}, errors.Wrapf(err, "failed to get %s", account)
+		}
+		return types.NamespacedName{
+			Namespace: clusterAccount.Spec.SecretRef.Namespace,
+			Name:      clusterAccount.Spec.SecretRef.Name,
+		}, nil
+	default:
+		var namespacedAccount v1beta1.SpaceliftAccount
+		if err := client.Get(ctx, types.NamespacedName{Namespace: account.Namespace, Name: account.Name}, &namespacedAccount); err != nil {
+			return types.NamespacedName{}, errors.Wrapf(err, "failed to get %s", account)
+		}
+		return types.NamespacedName{Namespace: account.Namespace, Name: namespacedAccount.Spec.SecretName}, nil
+	}
+}
+
+// Backoff configures the retries of transient and rate limited requests.
+type Backoff struct {
+	// Steps is the maximum number of attempts of a request
+	Steps int
+	// Duration is the delay before the first retry, it is multiplied by Factor after each retry up to Cap
+	Duration time.Duration
+	Factor   float64
+	Cap      time.Duration
+}
+
+// DefaultBackoff retries a request for about 10 seconds, so reconcilers are not blocked for long.
+// Longer outages and rate limits are left to the reconcilers, which requeue the resource.
+var DefaultBackoff = Backoff{
+	Steps:    4,
+	Duration: 500 * time.Millisecond,
+	Factor:   3,
+	Cap:      10 * time.Second,
+}
+
 type client struct {
 	wraps   *http.Client
 	session session.Session
+	backoff Backoff
 }
 
 // New returns a new instance of a Spacelift Client.
 func New(wraps *http.Client, session session.Session) Client {
-	return &client{wraps: wraps, session: session}
+	return &client{wraps: wraps, session: session, backoff: DefaultBackoff}
 }
 
 func (c *client) Mutate(ctx context.Context, mutation interface{}, variables map[string]interface{}, opts ...graphql.RequestOption) error {
-	logger := log.FromContext(ctx)
-
-	apiClient, err := c.apiClient(ctx)
-	if err != nil {
-		return nil
-	}
+	return c.do(ctx, metrics.OperationTypeMutation, mutation, variables, func(ctx context.Context, apiClient *graphql.Client) error {
+		return apiClient.Mutate(ctx, mutation, variables, opts...)
+	})
+}
 
-	err = apiClient.Mutate(ctx, mutation, variables, opts...)
-	if err != nil && strings.Contains(err.Error(), "unauthorized") {
-		logger.Error(err, "Server returned an unauthorized response - retrying request with a new token")
-		c.session.RefreshToken(ctx)
+func (c *client) Query(ctx context.Context, query interface{}, variables map[string]interface{}, opts ...graphql.RequestOption) error {
+	return c.do(ctx, metrics.OperationTypeQuery, query, variables, func(ctx context.Context, apiClient *graphql.Client) error {
+		return apiClient.Query(ctx, query, variables, opts...)
+	})
+}
 
-		// Try again in case refreshing the token fixes the problem
-		apiClient, err = c.apiClient(ctx)
+// do sends a request to the API and records the latency and errors of the operation in metrics and in a span.
+// Unauthorized requests are retried once with a new token, and transient or rate limited requests
+// are retried with an exponential backoff. Failures are returned as an Error when they can be classified.
+// Mutations may have been applied when a transient error is returned, so they are only retried when rate limited
+// or when they never reached the API, the reconcilers read the state again before sending them another time.
+func (c *client) do(ctx context.Context, operationType string, operation interface{}, variables map[string]interface{}, request func(context.Context, *graphql.Client) error) (err error) {
+	name := operationName(operation)
+	ctx, span := tracing.Start(ctx, operationType+" "+name,
+		attribute.String("graphql.operation.name", name),
+		attribute.String("graphql.operation.type", operationType),
+		// Values are not recorded as they may hold secrets, e.g. context config or API keys
+		attribute.StringSlice("graphql.variables", variableNames(variables)),
+	)
+	logger := log.FromContext(ctx)
+	defer func(start time.Time) {
+		metrics.APIRequestDuration.WithLabelValues(name, operationType).Observe(time.Since(start).Seconds())
 		if err != nil {
+			metrics.APIRequestErrors.WithLabelValues(name, operationType).Inc()
+			span.SetAttributes(attribute.String("spacelift.error.kind", string(KindOf(err))))
+		}
+		tracing.End(span, err)
+	}(time.Now())
+
+	refreshed := false
+	delay := c.backoff.Duration
+	for attempt := 1; ; attempt++ {
+		err = c.send(ctx, request)
+		if err == nil {
+			return nil
+		}
+
+		var clientErr *Error
+		if !errors.As(err, &clientErr) {
+			return err
+		}
+		if clientErr.Kind == ErrorKindAuth && !refreshed {
+			logger.Error(err, "Server returned an unauthorized response - retrying request with a new token")
+			if err := c.session.RefreshToken(ctx); err != nil {
+				logger.Error(err, "Unable to refresh the token")
+			}
+			// Try again in case refreshing the token fixes the problem
+			refreshed = true
+			continue
+		}
+		if !clientErr.Retryable() || attempt >= c.backoff.Steps {
+			return err
+		}
+		if operationType == metrics.OperationTypeMutation && clientErr.Kind != ErrorKindRateLimited && !notSent(err) {
 			return err
 		}
 
-		err = apiClient.Mutate(ctx, mutation, variables, opts...)
+		wait := delay
+		if clientErr.RetryAfter > 0 {
+			// Waiting longer than the backoff would block the reconciler, let it requeue the resource instead
+			if clientErr.RetryAfter > c.backoff.Cap {
+				return err
+			}
+			wait = clientErr.RetryAfter
+		}
+		logger.Info("Retrying spacelift API request", "operation", name, "kind", clientErr.Kind, "attempt", attempt, "delay", wait.String())
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.String("spacelift.error.kind", string(clientErr.Kind)),
+			attribute.Int("attempt", attempt),
+			attribute.String("delay", wait.String()),
+		))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(time.Duration(float64(delay)*c.backoff.Factor), c.backoff.Cap)
 	}
-
-	return err
 }
 
-func (c *client) Query(ctx context.Context, query interface{}, variables map[string]interface{}, opts ...graphql.RequestOption) error {
-	logger := log.FromContext(ctx)
-
+func (c *client) send(ctx context.Context, request func(context.Context, *graphql.Client) error) error {
 	apiClient, err := c.apiClient(ctx)
 	if err != nil {
-		return err
+		return classify(err)
 	}
+	return classify(request(ctx, apiClient))
+}
 
-	err = apiClient.Query(ctx, query, variables)
-	if err != nil && strings.Contains(err.Error(), "unauthorized") {
-		logger.Error(err, "Server returned an unauthorized response - retrying request with a new token")
-		c.session.RefreshToken(ctx)
-
-		// Try again in case refreshing the token fixes the problem
-		apiClient, err = c.apiClient(ctx)
-		if err != nil {
-			return err
-		}
-
-		err = apiClient.Query(ctx, query, variables, opts...)
+// variableNames returns the sorted names of the variables of a request
+func variableNames(variables map[string]interface{}) []string {
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	return err
+// operationName returns the name of the first field of a graphql query or mutation struct,
+// e.g. stackCreate for a field tagged graphql:"stackCreate(input: $input)" or graphql:"s0: stackCreate(input: $input)".
+func operationName(operation interface{}) string {
+	t := reflect.TypeOf(operation)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t.NumField() == 0 {
+		return "unknown"
+	}
+	field := t.Field(0)
+	name := field.Tag.Get("graphql")
+	if name == "" {
+		return field.Name
+	}
+	name, _, _ = strings.Cut(name, "(")
+	// Aliased fields are tagged like alias: field(arguments)
+	if _, field, aliased := strings.Cut(name, ":"); aliased {
+		name = field
+	}
+	return strings.TrimSpace(name)
 }
 
 func (c *client) URL(format string, a ...interface{}) string {
@@ -150,8 +347,12 @@ func (c *client) apiClient(ctx context.Context) (*graphql.Client, error) {
 		return nil, err
 	}
 
-	return graphql.NewClient(c.session.Endpoint(), oauth2.NewClient(
+	httpClient := oauth2.NewClient(
 		context.WithValue(ctx, oauth2.HTTPClient, c.wraps), oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: bearerToken},
-		)), graphql.WithHeader("Spacelift-Client-Type", "k8s-operator")), nil
+		))
+	httpClient.Transport = &statusTransport{base: httpClient.Transport}
+	httpClient.Timeout = c.wraps.Timeout
+
+	return graphql.NewClient(c.session.Endpoint(), httpClient, graphql.WithHeader("Spacelift-Client-Type", "k8s-operator")), nil
 }
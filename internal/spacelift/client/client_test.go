@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+)
+
+func Test_operationName(t *testing.T) {
+	var mutation struct {
+		StackCreate struct {
+			ID string `graphql:"id"`
+		} `graphql:"stackCreate(input: $input, manageState: $manageState)"`
+	}
+	var query struct {
+		Stack *struct {
+			ID string `graphql:"id"`
+		} `graphql:"stack(id: $stackId)"`
+	}
+	var aliased struct {
+		S0 *struct{} `graphql:"s0: stack(id: $stack0)"`
+	}
+	var untagged struct {
+		Space struct{}
+	}
+
+	assert.Equal(t, "stackCreate", operationName(&mutation))
+	assert.Equal(t, "stack", operationName(&query))
+	assert.Equal(t, "stack", operationName(&aliased))
+	assert.Equal(t, "Space", operationName(&untagged))
+	assert.Equal(t, "unknown", operationName(nil))
+}
+
+func TestAccountOf(t *testing.T) {
+	stack := &v1beta1.Stack{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}
+	assert.Equal(t, Account{Namespace: "team-a"}, AccountOf(stack))
+
+	stack.Spec.AccountRef = &v1beta1.AccountReference{Name: "prod"}
+	assert.Equal(t, Account{Kind: v1beta1.AccountKindSpaceliftAccount, Name: "prod", Namespace: "team-a"}, AccountOf(stack))
+
+	// Cluster accounts are shared by all namespaces
+	stack.Spec.AccountRef = &v1beta1.AccountReference{Kind: v1beta1.AccountKindClusterSpaceliftAccount, Name: "prod"}
+	run := &v1beta1.Run{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-b"},
+		Status:     v1beta1.RunStatus{AccountRef: stack.Spec.AccountRef},
+	}
+	assert.Equal(t, Account{Kind: v1beta1.AccountKindClusterSpaceliftAccount, Name: "prod"}, AccountOf(stack))
+	assert.Equal(t, AccountOf(stack), AccountOf(run))
+}
+
+func Test_credentialsSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1beta1.SpaceliftAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "prod"},
+			Spec:       v1beta1.SpaceliftAccountSpec{SecretName: "team-a-credentials"},
+		},
+		&v1beta1.ClusterSpaceliftAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+			Spec: v1beta1.ClusterSpaceliftAccountSpec{
+				SecretRef: v1beta1.SecretReference{Namespace: "spacelift-operator-system", Name: "prod-credentials"},
+			},
+		},
+	).Build()
+
+	tests := []struct {
+		name    string
+		account Account
+		want    types.NamespacedName
+		wantErr string
+	}{
+		{
+			name:    "default secret",
+			account: Account{Namespace: "team-a"},
+			want:    types.NamespacedName{Namespace: "team-a", Name: SecretName},
+		},
+		{
+			name:    "namespaced account",
+			account: Account{Kind: v1beta1.AccountKindSpaceliftAccount, Namespace: "team-a", Name: "prod"},
+			want:    types.NamespacedName{Namespace: "team-a", Name: "team-a-credentials"},
+		},
+		{
+			name:    "namespaced account from another namespace",
+			account: Account{Kind: v1beta1.AccountKindSpaceliftAccount, Namespace: "team-b", Name: "prod"},
+			wantErr: "failed to get SpaceliftAccount/team-b/prod",
+		},
+		{
+			name:    "cluster account",
+			account: Account{Kind: v1beta1.AccountKindClusterSpaceliftAccount, Name: "prod"},
+			want:    types.NamespacedName{Namespace: "spacelift-operator-system", Name: "prod-credentials"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := credentialsSecret(context.Background(), k8sClient, tt.account)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, secret)
+		})
+	}
+}
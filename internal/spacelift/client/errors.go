@@ -0,0 +1,217 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/graphql"
+)
+
+// ErrorKind classifies the failures of spacelift API requests, so callers can decide whether they are worth retrying.
+type ErrorKind string
+
+const (
+	// ErrorKindTransient is a network failure or a server error that is likely to go away
+	ErrorKindTransient ErrorKind = "Transient"
+	// ErrorKindRateLimited is returned when the API throttles the requests, see Error.RetryAfter
+	ErrorKindRateLimited ErrorKind = "RateLimited"
+	// ErrorKindValidation is a request rejected by the API, it fails again until the resource is changed
+	ErrorKindValidation ErrorKind = "Validation"
+	// ErrorKindNotFound is returned when a resource referenced by the request does not exist
+	ErrorKindNotFound ErrorKind = "NotFound"
+	// ErrorKindAuth is returned when the credentials are invalid or lack permissions
+	ErrorKindAuth ErrorKind = "Auth"
+)
+
+// Error is returned by the client when a request to the spacelift API fails.
+type Error struct {
+	Kind ErrorKind
+	// RetryAfter is the delay requested by the API before sending a rate limited request again, if any
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable returns true when sending the same request again may succeed
+func (e *Error) Retryable() bool {
+	return e.Kind == ErrorKindTransient || e.Kind == ErrorKindRateLimited
+}
+
+// KindOf returns the kind of a client error, or an empty string if the error does not come from the client
+func KindOf(err error) ErrorKind {
+	var clientErr *Error
+	if errors.As(err, &clientErr) {
+		return clientErr.Kind
+	}
+	return ""
+}
+
+// IsRetryable returns true when the request failed with a transient or rate limited error
+func IsRetryable(err error) bool {
+	var clientErr *Error
+	return errors.As(err, &clientErr) && clientErr.Retryable()
+}
+
+// RetryAfter returns the delay requested by the API before retrying a rate limited request, or 0
+func RetryAfter(err error) time.Duration {
+	var clientErr *Error
+	if errors.As(err, &clientErr) {
+		return clientErr.RetryAfter
+	}
+	return 0
+}
+
+// Message returns a description of a failed request meant for users. The messages of GraphQL errors
+// are prefixed with the path of the field they apply to, without the context added by the callers of the client.
+func Message(err error) string {
+	var graphqlErrs graphql.GraphQLErrors
+	if !errors.As(err, &graphqlErrs) || len(graphqlErrs) == 0 {
+		return err.Error()
+	}
+	messages := make([]string, 0, len(graphqlErrs))
+	for _, graphqlErr := range graphqlErrs {
+		path := make([]string, 0, len(graphqlErr.Path))
+		for _, element := range graphqlErr.Path {
+			path = append(path, fmt.Sprint(element))
+		}
+		if len(path) == 0 {
+			messages = append(messages, graphqlErr.Message)
+			continue
+		}
+		messages = append(messages, strings.Join(path, ".")+": "+graphqlErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// HTTPError is returned when the API answers with a non 200 status code.
+// The graphql library only reports those as text, so the transport of the client returns it instead.
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("non-200 OK status code: %d %s body: %q", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// statusTransport turns the non 200 responses of the API into an HTTPError
+type statusTransport struct {
+	base http.RoundTripper
+}
+
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode == http.StatusOK {
+		return resp, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, &HTTPError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Body:       string(body),
+	}
+}
+
+// parseRetryAfter reads a Retry-After header, set either in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// classify wraps the errors of the graphql library in an Error.
+// Errors that can't be classified, e.g. failures to decode a response, are returned unchanged.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var clientErr *Error
+	if errors.As(err, &clientErr) {
+		return err
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return &Error{Kind: httpErrorKind(httpErr.StatusCode), RetryAfter: httpErr.RetryAfter, Err: httpErr}
+	}
+
+	var graphqlErrs graphql.GraphQLErrors
+	if errors.As(err, &graphqlErrs) {
+		return &Error{Kind: graphqlErrorKind(graphqlErrs), Err: err}
+	}
+
+	var netErr net.Error
+	var opErr *net.OpError
+	if errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return &Error{Kind: ErrorKindTransient, Err: err}
+	}
+
+	return err
+}
+
+// notSent returns true when a request failed before reaching the API, so sending it again can't apply it twice
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+func httpErrorKind(statusCode int) ErrorKind {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorKindAuth
+	case statusCode == http.StatusNotFound:
+		return ErrorKindNotFound
+	case statusCode == http.StatusTooManyRequests:
+		return ErrorKindRateLimited
+	case statusCode == http.StatusRequestTimeout || statusCode >= http.StatusInternalServerError:
+		return ErrorKindTransient
+	default:
+		return ErrorKindValidation
+	}
+}
+
+// graphqlErrorKind classifies the errors returned in a GraphQL response.
+// The API does not set error codes, so the kind is guessed from the messages,
+// and any other error is a request that has been processed and rejected.
+func graphqlErrorKind(errs graphql.GraphQLErrors) ErrorKind {
+	for _, err := range errs {
+		message := strings.ToLower(err.Message)
+		switch {
+		case strings.Contains(message, "unauthorized") || strings.Contains(message, "forbidden"):
+			return ErrorKindAuth
+		case strings.Contains(message, "rate limit") || strings.Contains(message, "too many requests"):
+			return ErrorKindRateLimited
+		case strings.Contains(message, "internal error") || strings.Contains(message, "internal server error"):
+			return ErrorKindTransient
+		case strings.Contains(message, "not found"):
+			return ErrorKindNotFound
+		}
+	}
+	return ErrorKindValidation
+}
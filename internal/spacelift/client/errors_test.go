@@ -0,0 +1,270 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type staticSession struct {
+	endpoint  string
+	refreshed atomic.Int32
+}
+
+func (s *staticSession) BearerToken(context.Context) (string, error) { return "token", nil }
+func (s *staticSession) Endpoint() string                            { return s.endpoint }
+func (s *staticSession) RefreshToken(context.Context) error {
+	s.refreshed.Add(1)
+	return nil
+}
+
+// newTestClient returns a client sending requests to a server answering with the given responses in order,
+// the last response being repeated. It returns the number of requests received by the server.
+func newTestClient(t *testing.T, responses ...func(w http.ResponseWriter)) (*client, *staticSession, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		i := int(requests.Add(1)) - 1
+		responses[min(i, len(responses)-1)](w)
+	}))
+	t.Cleanup(server.Close)
+
+	session := &staticSession{endpoint: server.URL}
+	c := New(server.Client(), session).(*client)
+	c.backoff = Backoff{Steps: 3, Duration: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond}
+	return c, session, &requests
+}
+
+func status(code int, header ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		for i := 0; i+1 < len(header); i += 2 {
+			w.Header().Set(header[i], header[i+1])
+		}
+		w.WriteHeader(code)
+	}
+}
+
+func body(body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+type testQuery struct {
+	Stack *struct {
+		ID string `graphql:"id"`
+	} `graphql:"stack(id: \"id\")"`
+}
+
+func TestClient_Errors(t *testing.T) {
+	const ok = `{"data":{"stack":{"id":"id"}}}`
+
+	tests := []struct {
+		name         string
+		responses    []func(w http.ResponseWriter)
+		wantKind     ErrorKind
+		wantRequests int32
+		wantRefresh  int32
+	}{
+		{
+			name:         "transient error is retried",
+			responses:    []func(w http.ResponseWriter){status(http.StatusBadGateway), body(ok)},
+			wantRequests: 2,
+		},
+		{
+			name:         "transient error is retried until the backoff is exhausted",
+			responses:    []func(w http.ResponseWriter){status(http.StatusServiceUnavailable)},
+			wantKind:     ErrorKindTransient,
+			wantRequests: 3,
+		},
+		{
+			name:         "rate limited request waits before retrying",
+			responses:    []func(w http.ResponseWriter){status(http.StatusTooManyRequests), body(ok)},
+			wantRequests: 2,
+		},
+		{
+			name:         "rate limited request is not retried when the API asks to wait longer than the backoff",
+			responses:    []func(w http.ResponseWriter){status(http.StatusTooManyRequests, "Retry-After", "60")},
+			wantKind:     ErrorKindRateLimited,
+			wantRequests: 1,
+		},
+		{
+			name:         "validation error is not retried",
+			responses:    []func(w http.ResponseWriter){body(`{"errors":[{"message":"invalid branch"}]}`)},
+			wantKind:     ErrorKindValidation,
+			wantRequests: 1,
+		},
+		{
+			name:         "not found error is not retried",
+			responses:    []func(w http.ResponseWriter){status(http.StatusNotFound)},
+			wantKind:     ErrorKindNotFound,
+			wantRequests: 1,
+		},
+		{
+			name:         "unauthorized request is retried once with a new token",
+			responses:    []func(w http.ResponseWriter){body(`{"errors":[{"message":"unauthorized"}]}`), body(ok)},
+			wantRequests: 2,
+			wantRefresh:  1,
+		},
+		{
+			name:         "unauthorized request fails when a new token does not help",
+			responses:    []func(w http.ResponseWriter){status(http.StatusUnauthorized)},
+			wantKind:     ErrorKindAuth,
+			wantRequests: 2,
+			wantRefresh:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, session, requests := newTestClient(t, tt.responses...)
+
+			var query testQuery
+			err := c.Query(context.Background(), &query, nil)
+			if tt.wantKind == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "id", query.Stack.ID)
+			} else {
+				assert.Equal(t, tt.wantKind, KindOf(err))
+			}
+			assert.Equal(t, tt.wantRequests, requests.Load())
+			assert.Equal(t, tt.wantRefresh, session.refreshed.Load())
+		})
+	}
+}
+
+type testMutation struct {
+	RunTrigger struct {
+		ID string `graphql:"id"`
+	} `graphql:"runTrigger(stack: \"id\")"`
+}
+
+func TestClient_MutationRetries(t *testing.T) {
+	const ok = `{"data":{"runTrigger":{"id":"id"}}}`
+	slow := func(w http.ResponseWriter) {
+		time.Sleep(100 * time.Millisecond)
+		body(ok)(w)
+	}
+
+	tests := []struct {
+		name         string
+		responses    []func(w http.ResponseWriter)
+		timeout      time.Duration
+		wantKind     ErrorKind
+		wantRequests int32
+	}{
+		{
+			name:         "server error may come after the mutation has been applied, it is not retried",
+			responses:    []func(w http.ResponseWriter){status(http.StatusBadGateway), body(ok)},
+			wantKind:     ErrorKindTransient,
+			wantRequests: 1,
+		},
+		{
+			name:         "timeout may come after the mutation has been applied, it is not retried",
+			responses:    []func(w http.ResponseWriter){slow, body(ok)},
+			timeout:      20 * time.Millisecond,
+			wantKind:     ErrorKindTransient,
+			wantRequests: 1,
+		},
+		{
+			name:         "rate limited mutation is retried",
+			responses:    []func(w http.ResponseWriter){status(http.StatusTooManyRequests), body(ok)},
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, requests := newTestClient(t, tt.responses...)
+			c.wraps.Timeout = tt.timeout
+
+			var mutation testMutation
+			err := c.Mutate(context.Background(), &mutation, nil)
+			if tt.wantKind == "" {
+				require.NoError(t, err)
+			} else {
+				assert.Equal(t, tt.wantKind, KindOf(err))
+			}
+			assert.Equal(t, tt.wantRequests, requests.Load())
+		})
+	}
+}
+
+func TestClient_MutationNotSentIsRetried(t *testing.T) {
+	var dials atomic.Int32
+	c, _, _ := newTestClient(t, body(`{}`))
+	c.session = &staticSession{endpoint: "http://127.0.0.1:1"}
+	c.wraps = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	// Nothing listens on the port, the connection is refused before the mutation is sent
+	err := c.Mutate(context.Background(), &testMutation{}, nil)
+	assert.Equal(t, ErrorKindTransient, KindOf(err))
+	assert.Equal(t, int32(3), dials.Load())
+}
+
+func TestClient_ErrorsWrapped(t *testing.T) {
+	c, _, _ := newTestClient(t, status(http.StatusTooManyRequests, "Retry-After", "60"))
+
+	// Repositories wrap the errors of the client
+	err := errors.Wrap(c.Query(context.Background(), &testQuery{}, nil), "unable to get stack")
+	assert.True(t, IsRetryable(err))
+	assert.Equal(t, time.Minute, RetryAfter(err))
+
+	var httpErr *HTTPError
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusTooManyRequests, httpErr.StatusCode)
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, 5*time.Second, parseRetryAfter("5"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)))
+
+	retryAfter := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, time.Hour.Seconds(), retryAfter.Seconds(), 2)
+}
+
+func TestMessage(t *testing.T) {
+	c, _, _ := newTestClient(t, body(`{"errors":[`+
+		`{"message":"branch does not exist","path":["stackCreate","branch"]},`+
+		`{"message":"invalid project root"}]}`))
+
+	err := errors.Wrap(c.Query(context.Background(), &testQuery{}, nil), "unable to create stack")
+	assert.Equal(t, ErrorKindValidation, KindOf(err))
+	assert.Equal(t, "stackCreate.branch: branch does not exist; invalid project root", Message(err))
+
+	assert.Equal(t, "unable to create stack: failed", Message(errors.Wrap(errors.New("failed"), "unable to create stack")))
+}
+
+func TestClient_Span(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	c, _, _ := newTestClient(t, status(http.StatusBadGateway), body(`{"data":{"stack":{"id":"id"}}}`))
+
+	require.NoError(t, c.Query(context.Background(), &testQuery{}, map[string]interface{}{"secret": "value"}))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "query stack", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.StringSlice("graphql.variables", []string{"secret"}))
+	for _, attr := range spans[0].Attributes() {
+		assert.NotContains(t, attr.Value.Emit(), "value")
+	}
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, "retry", spans[0].Events()[0].Name)
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/shurcooL/graphql"
+
+	"github.com/spacelift-io/spacelift-operator/internal/metrics"
+	"github.com/spacelift-io/spacelift-operator/internal/tracing"
 )
 
 // FromAPIKey builds a Spacelift session from a combination of endpoint, API key
@@ -36,6 +39,7 @@ type apiKey struct {
 
 func (g *apiKey) BearerToken(ctx context.Context) (string, error) {
 	if !g.isFresh() {
+		metrics.TokenRefreshes.WithLabelValues(metrics.RefreshReasonExpired).Inc()
 		if err := g.exchange(ctx); err != nil {
 			return "", err
 		}
@@ -45,10 +49,14 @@ func (g *apiKey) BearerToken(ctx context.Context) (string, error) {
 }
 
 func (g *apiKey) RefreshToken(ctx context.Context) error {
+	metrics.TokenRefreshes.WithLabelValues(metrics.RefreshReasonUnauthorized).Inc()
 	return g.exchange(ctx)
 }
 
-func (g *apiKey) exchange(ctx context.Context) error {
+func (g *apiKey) exchange(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "session.apiKey.exchange")
+	defer func() { tracing.End(span, err) }()
+
 	var mutation struct {
 		APIKeyUser user `graphql:"apiKeyUser(id: $id, secret: $secret)"`
 	}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Optional keys of the credentials secret configuring how the spacelift API is reached, e.g. for self-hosted instances.
+const (
+	// SpaceliftProxyURLKey is the URL of the proxy used for API requests, overriding the HTTPS_PROXY environment variable
+	SpaceliftProxyURLKey = "SPACELIFT_PROXY_URL" //nolint:gosec
+	// SpaceliftCABundleConfigMapKey is the name of a ConfigMap in the namespace of the secret, holding PEM encoded
+	// certificates trusted in addition to the system ones
+	SpaceliftCABundleConfigMapKey = "SPACELIFT_CA_BUNDLE_CONFIGMAP" //nolint:gosec
+	// SpaceliftClientCertificateKey and SpaceliftClientKeyKey hold a PEM encoded client certificate and its key for mTLS
+	SpaceliftClientCertificateKey = "SPACELIFT_CLIENT_CERTIFICATE" //nolint:gosec
+	SpaceliftClientKeyKey         = "SPACELIFT_CLIENT_KEY"         //nolint:gosec
+	// SpaceliftRequestTimeoutKey is the timeout of a single API request, as a duration such as 30s
+	SpaceliftRequestTimeoutKey = "SPACELIFT_REQUEST_TIMEOUT" //nolint:gosec
+)
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// caBundle returns the ConfigMap referenced by the credentials secret, or nil if the secret does not set one.
+// It is read before the client cache is looked up, so the client is created again when the bundle is changed.
+func caBundle(ctx context.Context, client k8sclient.Client, secret *v1.Secret) (*v1.ConfigMap, error) {
+	name := string(secret.Data[SpaceliftCABundleConfigMapKey])
+	if name == "" {
+		return nil, nil
+	}
+	var configMap v1.ConfigMap
+	if err := client.Get(ctx, types.NamespacedName{Namespace: secret.Namespace, Name: name}, &configMap); err != nil {
+		return nil, errors.Wrap(err, "failed to get CA bundle ConfigMap")
+	}
+	return &configMap, nil
+}
+
+// httpClient returns the HTTP client configured by the transport keys of the credentials secret,
+// or http.DefaultClient if none is set.
+func httpClient(secret *v1.Secret, caBundle *v1.ConfigMap) (*http.Client, error) {
+	proxyURL := string(secret.Data[SpaceliftProxyURLKey])
+	clientCertificate := secret.Data[SpaceliftClientCertificateKey]
+	clientKey := secret.Data[SpaceliftClientKeyKey]
+	timeout := string(secret.Data[SpaceliftRequestTimeoutKey])
+	if proxyURL == "" && caBundle == nil && len(clientCertificate) == 0 && len(clientKey) == 0 && timeout == "" {
+		return http.DefaultClient, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	httpClient := &http.Client{Transport: transport}
+
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", SpaceliftProxyURLKey)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if caBundle != nil {
+		rootCAs, err := certPool(caBundle)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.RootCAs = rootCAs
+	}
+
+	if len(clientCertificate) > 0 || len(clientKey) > 0 {
+		certificate, err := tls.X509KeyPair(clientCertificate, clientKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s or %s", SpaceliftClientCertificateKey, SpaceliftClientKeyKey)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if timeout != "" {
+		duration, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", SpaceliftRequestTimeoutKey)
+		}
+		httpClient.Timeout = duration
+	}
+
+	return httpClient, nil
+}
+
+// certPool returns the system certificates along with every certificate of the ConfigMap
+func certPool(configMap *v1.ConfigMap) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	keys := make([]string, 0, len(configMap.Data))
+	for key := range configMap.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !pool.AppendCertsFromPEM([]byte(configMap.Data[key])) {
+			return nil, errors.Errorf("no PEM certificate found in key %s of ConfigMap %s/%s", key, configMap.Namespace, configMap.Name)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// clientCertificate returns a self signed client certificate and its key, PEM encoded
+func clientCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "spacelift-operator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func Test_httpClient(t *testing.T) {
+	secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: SecretName}}
+	client, err := httpClient(secret, nil)
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultClient, client)
+
+	secret.Data = map[string][]byte{
+		SpaceliftProxyURLKey:       []byte("http://proxy.internal:3128"),
+		SpaceliftRequestTimeoutKey: []byte("30s"),
+	}
+	client, err = httpClient(secret, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, client.Timeout)
+	proxy, err := client.Transport.(*http.Transport).Proxy(httptest.NewRequest(http.MethodPost, "https://mycorp.app.spacelift.io/graphql", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "http://proxy.internal:3128", proxy.String())
+
+	secret.Data = map[string][]byte{SpaceliftRequestTimeoutKey: []byte("soon")}
+	_, err = httpClient(secret, nil)
+	assert.ErrorContains(t, err, "invalid "+SpaceliftRequestTimeoutKey)
+
+	secret.Data = map[string][]byte{SpaceliftClientCertificateKey: []byte("not a certificate")}
+	_, err = httpClient(secret, nil)
+	assert.ErrorContains(t, err, "invalid "+SpaceliftClientCertificateKey)
+
+	_, err = httpClient(&v1.Secret{}, &v1.ConfigMap{Data: map[string]string{"ca.crt": "not a certificate"}})
+	assert.ErrorContains(t, err, "no PEM certificate found in key ca.crt")
+}
+
+func Test_httpClient_mTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS12}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "spacelift-ca"},
+		Data: map[string]string{
+			"ca.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		},
+	}).Build()
+
+	certificate, key := clientCertificate(t)
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: SecretName},
+		Data: map[string][]byte{
+			SpaceliftCABundleConfigMapKey: []byte("spacelift-ca"),
+			SpaceliftClientCertificateKey: certificate,
+			SpaceliftClientKeyKey:         key,
+		},
+	}
+	bundle, err := caBundle(context.Background(), k8sClient, secret)
+	require.NoError(t, err)
+	client, err := httpClient(secret, bundle)
+	require.NoError(t, err)
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Without the CA bundle, the server certificate is not trusted
+	delete(secret.Data, SpaceliftCABundleConfigMapKey)
+	client, err = httpClient(secret, nil)
+	require.NoError(t, err)
+	_, err = client.Get(server.URL) //nolint:bodyclose
+	assert.Error(t, err)
+}
@@ -0,0 +1,381 @@
+// Package drift compares resources read from spacelift with the spec of their custom resource.
+// Only the fields set in the spec are compared, anything else is left to spacelift defaults.
+package drift
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/structs"
+)
+
+// sensitiveValue replaces the values of secret config elements, they must never end up in a status or an event.
+const sensitiveValue = "(sensitive)"
+
+// changes collects the fields of a spec that no longer match spacelift.
+type changes []v1beta1.FieldChange
+
+func (c *changes) add(field, current, desired string) {
+	*c = append(*c, v1beta1.FieldChange{Field: field, Current: current, Desired: desired})
+}
+
+// Stack returns the spec fields of the stack that no longer match spacelift.
+func Stack(stack *v1beta1.Stack, spaceliftStack *models.Stack) []string {
+	return Fields(StackChanges(stack, spaceliftStack))
+}
+
+// StackChanges returns the changes that applying the stack spec would make in spacelift.
+func StackChanges(stack *v1beta1.Stack, spaceliftStack *models.Stack) []v1beta1.FieldChange {
+	input := structs.FromStackSpec(stack)
+	spec := stack.Spec
+
+	var c changes
+	if string(input.Name) != spaceliftStack.Name {
+		c.add("name", spaceliftStack.Name, string(input.Name))
+	}
+	if string(input.Branch) != spaceliftStack.Branch {
+		c.add("branch", spaceliftStack.Branch, string(input.Branch))
+	}
+	if string(input.Repository) != spaceliftStack.Repository ||
+		(input.Namespace != nil && string(*input.Namespace) != spaceliftStack.Namespace) {
+		current := spaceliftStack.Repository
+		if spaceliftStack.Namespace != "" {
+			current = spaceliftStack.Namespace + "/" + current
+		}
+		c.add("repository", current, spec.Repository)
+	}
+	if bool(input.Administrative) != spaceliftStack.Administrative {
+		c.add("administrative", fmt.Sprint(spaceliftStack.Administrative), fmt.Sprint(bool(input.Administrative)))
+	}
+	if spec.Description != nil && *spec.Description != spaceliftStack.Description {
+		c.add("description", spaceliftStack.Description, *spec.Description)
+	}
+	if spec.ProjectRoot != nil && *spec.ProjectRoot != spaceliftStack.ProjectRoot {
+		c.add("projectRoot", spaceliftStack.ProjectRoot, *spec.ProjectRoot)
+	}
+	if spec.Autodeploy != nil && *spec.Autodeploy != spaceliftStack.Autodeploy {
+		c.add("autodeploy", fmt.Sprint(spaceliftStack.Autodeploy), fmt.Sprint(*spec.Autodeploy))
+	}
+	if spec.Labels != nil && !sameLabels(*spec.Labels, spaceliftStack.Labels) {
+		c.add("labels", strings.Join(spaceliftStack.Labels, ","), strings.Join(*spec.Labels, ","))
+	}
+	if spec.SpaceId != nil && *spec.SpaceId != spaceliftStack.SpaceId {
+		c.add("spaceId", spaceliftStack.SpaceId, *spec.SpaceId)
+	}
+	if current, desired := currentDependencies(spaceliftStack.DependsOn), desiredDependencies(spec.DependsOn); !slices.Equal(current, desired) {
+		c.add("dependsOn", strings.Join(current, ","), strings.Join(desired, ","))
+	}
+	c.config(spec.Environment, spec.MountedFiles, spaceliftStack.Config)
+	if current, desired := currentContexts(stack, spaceliftStack.AttachedContexts), desiredContexts(spec.Contexts); !slices.Equal(current, desired) {
+		c.add("contexts", strings.Join(current, ","), strings.Join(desired, ","))
+	}
+	c.cloudIntegrations(spec, spaceliftStack)
+
+	return c
+}
+
+// cloudIntegrations adds the cloud integrations that differ from the ones of the spacelift stack.
+// Integrations are managed entirely by the spec, the ones it does not declare are detached.
+// AWS integrations are reported as awsIntegration when the deprecated field is used, the others as cloudIntegrations.<cloud>.
+func (c *changes) cloudIntegrations(spec v1beta1.StackSpec, spaceliftStack *models.Stack) {
+	var currentAWS, desiredAWS, currentAzure, desiredAzure []string
+	for _, integration := range spaceliftStack.AttachedAWSIntegrations {
+		currentAWS = append(currentAWS, formatIntegration(integration.IntegrationId, integration.Read, integration.Write, nil))
+	}
+	for _, integration := range spaceliftStack.AttachedAzureIntegrations {
+		currentAzure = append(currentAzure, formatIntegration(integration.IntegrationId, integration.Read, integration.Write, integration.SubscriptionId))
+	}
+	awsField := "cloudIntegrations.aws"
+	if spec.AWSIntegration != nil {
+		awsField = "awsIntegration"
+		desiredAWS = append(desiredAWS, formatIntegration(spec.AWSIntegration.Id, spec.AWSIntegration.Read, spec.AWSIntegration.Write, nil))
+	}
+	currentGCP, desiredGCP := "", ""
+	if spaceliftStack.GCPIntegration != nil {
+		currentGCP = formatTokenScopes(spaceliftStack.GCPIntegration.TokenScopes)
+	}
+	for _, integration := range spec.CloudIntegrations {
+		switch {
+		case integration.AWS != nil:
+			desiredAWS = append(desiredAWS, formatIntegration(integration.AWS.Id, integration.AWS.Read, integration.AWS.Write, nil))
+		case integration.Azure != nil:
+			desiredAzure = append(desiredAzure, formatIntegration(integration.Azure.Id, integration.Azure.Read, integration.Azure.Write, integration.Azure.SubscriptionId))
+		case integration.GCP != nil && desiredGCP == "":
+			desiredGCP = formatTokenScopes(integration.GCP.TokenScopes)
+		}
+	}
+	for _, integrations := range [][]string{currentAWS, desiredAWS, currentAzure, desiredAzure} {
+		slices.Sort(integrations)
+	}
+
+	if !slices.Equal(currentAWS, desiredAWS) {
+		c.add(awsField, strings.Join(currentAWS, ","), strings.Join(desiredAWS, ","))
+	}
+	if !slices.Equal(currentAzure, desiredAzure) {
+		c.add("cloudIntegrations.azure", strings.Join(currentAzure, ","), strings.Join(desiredAzure, ","))
+	}
+	if currentGCP != desiredGCP {
+		c.add("cloudIntegrations.gcp", currentGCP, desiredGCP)
+	}
+}
+
+// formatIntegration formats a cloud integration as <integration id>(<permissions>), followed by @<subscription id> for Azure.
+func formatIntegration(id string, read, write bool, subscriptionId *string) string {
+	var permissions []string
+	if read {
+		permissions = append(permissions, "read")
+	}
+	if write {
+		permissions = append(permissions, "write")
+	}
+	result := id + "(" + strings.Join(permissions, ",") + ")"
+	if subscriptionId != nil && *subscriptionId != "" {
+		result += "@" + *subscriptionId
+	}
+	return result
+}
+
+// formatTokenScopes formats the token scopes of a GCP service account, sorted to be compared regardless of their order.
+func formatTokenScopes(scopes []string) string {
+	return "[" + strings.Join(slices.Sorted(slices.Values(scopes)), " ") + "]"
+}
+
+// currentDependencies formats the dependencies of a spacelift stack as <stack id>(<output>:<input> ...), sorted to be compared regardless of their order.
+func currentDependencies(dependencies []models.StackDependency) []string {
+	result := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		references := make([]string, 0, len(dependency.References))
+		for _, reference := range dependency.References {
+			references = append(references, reference.OutputName+":"+reference.InputName)
+		}
+		result = append(result, formatDependency(dependency.DependsOnStackId, references))
+	}
+	slices.Sort(result)
+	return result
+}
+
+// desiredDependencies formats the dependencies of a stack spec like currentDependencies.
+func desiredDependencies(dependencies []v1beta1.StackDependency) []string {
+	result := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		if dependency.StackId == nil {
+			continue
+		}
+		references := make([]string, 0, len(dependency.References))
+		for _, reference := range dependency.References {
+			references = append(references, reference.Output+":"+reference.Input)
+		}
+		result = append(result, formatDependency(*dependency.StackId, references))
+	}
+	slices.Sort(result)
+	return result
+}
+
+func formatDependency(stackId string, references []string) string {
+	if len(references) == 0 {
+		return stackId
+	}
+	slices.Sort(references)
+	return stackId + "(" + strings.Join(references, " ") + ")"
+}
+
+// currentContexts formats the contexts attached to a spacelift stack as <context id>:<priority>, sorted to be compared regardless of their order.
+// Only the contexts managed by spec.contexts are listed, the ones attached by contexts themselves are not part of the stack spec.
+func currentContexts(stack *v1beta1.Stack, attached []models.StackContextAttachment) []string {
+	var result []string
+	for _, attachment := range attached {
+		i := slices.IndexFunc(stack.Spec.Contexts, func(c v1beta1.StackContext) bool {
+			return c.ContextId != nil && *c.ContextId == attachment.ContextId
+		})
+		switch {
+		case i >= 0 && attachment.IsAutoattached:
+			// Contexts attached by labels can't be attached again, they are in sync whatever their priority
+			result = append(result, formatContext(stack.Spec.Contexts[i]))
+		case i >= 0 || (!attachment.IsAutoattached && slices.Contains(stack.Status.AttachedContextIds, attachment.ContextId)):
+			result = append(result, fmt.Sprintf("%s:%d", attachment.ContextId, attachment.Priority))
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// desiredContexts formats the contexts of a stack spec like currentContexts.
+func desiredContexts(contexts []v1beta1.StackContext) []string {
+	var result []string
+	for _, stackContext := range contexts {
+		if stackContext.ContextId != nil {
+			result = append(result, formatContext(stackContext))
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func formatContext(stackContext v1beta1.StackContext) string {
+	priority := 0
+	if stackContext.Priority != nil {
+		priority = *stackContext.Priority
+	}
+	return fmt.Sprintf("%s:%d", *stackContext.ContextId, priority)
+}
+
+// Context returns the spec fields of the context that no longer match spacelift.
+func Context(context *v1beta1.Context, spaceliftContext *models.Context) []string {
+	return Fields(ContextChanges(context, spaceliftContext))
+}
+
+// ContextChanges returns the changes that applying the context spec would make in spacelift.
+func ContextChanges(context *v1beta1.Context, spaceliftContext *models.Context) []v1beta1.FieldChange {
+	spec := context.Spec
+
+	var c changes
+	if context.Name() != spaceliftContext.Name {
+		c.add("name", spaceliftContext.Name, context.Name())
+	}
+	if spec.Description != nil && *spec.Description != spaceliftContext.Description {
+		c.add("description", spaceliftContext.Description, *spec.Description)
+	}
+	if !sameLabels(spec.Labels, spaceliftContext.Labels) {
+		c.add("labels", strings.Join(spaceliftContext.Labels, ","), strings.Join(spec.Labels, ","))
+	}
+	if spec.SpaceId != nil && *spec.SpaceId != spaceliftContext.SpaceId {
+		c.add("spaceId", spaceliftContext.SpaceId, *spec.SpaceId)
+	}
+
+	c.config(spec.Environment, spec.MountedFiles, spaceliftContext.Config)
+
+	return c
+}
+
+// config adds the environment variables and mounted files that differ from the config elements in spacelift.
+// Values of write only config elements can't be read back, only their presence is compared.
+// Config elements are reported as environment.<id> or mountedFiles.<id>, and secret values are never exposed.
+func (c *changes) config(environment []v1beta1.Environment, mountedFiles []v1beta1.MountedFile, spaceliftConfig []models.ContextConfig) {
+	configs := make(map[string]models.ContextConfig, len(spaceliftConfig))
+	for _, config := range spaceliftConfig {
+		configs[config.Type+"/"+config.Id] = config
+	}
+	compareConfig := func(field, configType, id string, value *string, secret *bool) {
+		config, ok := configs[configType+"/"+id]
+		delete(configs, configType+"/"+id)
+		if ok && (config.WriteOnly || value == nil || (config.Value != nil && *config.Value == *value)) {
+			return
+		}
+		var current, desired string
+		if ok {
+			current = configValue(config)
+		}
+		if value != nil {
+			desired = *value
+		}
+		if secret != nil && *secret {
+			desired = sensitiveValue
+		}
+		c.add(field+"."+id, current, desired)
+	}
+
+	for _, env := range environment {
+		compareConfig("environment", structs.ConfigAttachmentTypeEnvVar, env.Id, env.Value, env.Secret)
+	}
+	for _, mountedFile := range mountedFiles {
+		compareConfig("mountedFiles", structs.ConfigAttachmentTypeFileMount, mountedFile.Id, mountedFile.Value, mountedFile.Secret)
+	}
+	// Remaining config elements are not in the spec anymore, they are sorted to keep the changes stable
+	remaining := make([]models.ContextConfig, 0, len(configs))
+	for _, config := range configs {
+		remaining = append(remaining, config)
+	}
+	slices.SortFunc(remaining, func(a, b models.ContextConfig) int {
+		return strings.Compare(a.Type+"/"+a.Id, b.Type+"/"+b.Id)
+	})
+	for _, config := range remaining {
+		field := "environment"
+		if config.Type == structs.ConfigAttachmentTypeFileMount {
+			field = "mountedFiles"
+		}
+		c.add(field+"."+config.Id, configValue(config), "")
+	}
+}
+
+func configValue(config models.ContextConfig) string {
+	if config.WriteOnly || config.Value == nil {
+		return sensitiveValue
+	}
+	return *config.Value
+}
+
+// Policy returns the spec fields of the policy that no longer match spacelift.
+func Policy(policy *v1beta1.Policy, spaceliftPolicy *models.Policy) []string {
+	return Fields(PolicyChanges(policy, spaceliftPolicy))
+}
+
+// PolicyChanges returns the changes that applying the policy spec would make in spacelift.
+func PolicyChanges(policy *v1beta1.Policy, spaceliftPolicy *models.Policy) []v1beta1.FieldChange {
+	spec := policy.Spec
+
+	var c changes
+	if policy.Name() != spaceliftPolicy.Name {
+		c.add("name", spaceliftPolicy.Name, policy.Name())
+	}
+	if spec.Body != spaceliftPolicy.Body {
+		c.add("body", spaceliftPolicy.Body, spec.Body)
+	}
+	if spec.Description != nil && *spec.Description != spaceliftPolicy.Description {
+		c.add("description", spaceliftPolicy.Description, *spec.Description)
+	}
+	if !sameLabels(spec.Labels, spaceliftPolicy.Labels) {
+		c.add("labels", strings.Join(spaceliftPolicy.Labels, ","), strings.Join(spec.Labels, ","))
+	}
+	if spec.SpaceId != nil && *spec.SpaceId != spaceliftPolicy.SpaceId {
+		c.add("spaceId", spaceliftPolicy.SpaceId, *spec.SpaceId)
+	}
+
+	return c
+}
+
+// SpaceChanges returns the changes that applying the space spec would make in spacelift.
+func SpaceChanges(space *v1beta1.Space, spaceliftSpace *models.Space) []v1beta1.FieldChange {
+	input := structs.FromSpaceSpec(space)
+
+	var c changes
+	if string(input.Name) != spaceliftSpace.Name {
+		c.add("name", spaceliftSpace.Name, string(input.Name))
+	}
+	if string(input.Description) != spaceliftSpace.Description {
+		c.add("description", spaceliftSpace.Description, string(input.Description))
+	}
+	if bool(input.InheritEntities) != spaceliftSpace.InheritEntities {
+		c.add("inheritEntities", fmt.Sprint(spaceliftSpace.InheritEntities), fmt.Sprint(bool(input.InheritEntities)))
+	}
+	if string(input.ParentSpace) != spaceliftSpace.ParentSpace {
+		c.add("parentSpace", spaceliftSpace.ParentSpace, string(input.ParentSpace))
+	}
+	if space.Spec.Labels != nil && !sameLabels(*space.Spec.Labels, spaceliftSpace.Labels) {
+		c.add("labels", strings.Join(spaceliftSpace.Labels, ","), strings.Join(*space.Spec.Labels, ","))
+	}
+
+	return c
+}
+
+// Fields returns the spec fields of the changes, config elements are reported by their list name.
+func Fields(changes []v1beta1.FieldChange) []string {
+	var fields []string
+	for _, change := range changes {
+		field, _, _ := strings.Cut(change.Field, ".")
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// sameLabels compares labels regardless of their order, spacelift does not preserve it.
+func sameLabels(spec, spacelift []string) bool {
+	a, b := slices.Clone(spec), slices.Clone(spacelift)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
@@ -0,0 +1,368 @@
+package drift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
+)
+
+func TestStack(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository:  "spacelift-io/spacelift-operator",
+			Description: utils.AddressOf("description"),
+			Labels:      &[]string{"b", "a"},
+			SpaceId:     utils.AddressOf("space-id"),
+		},
+	}
+	inSync := models.Stack{
+		Name:        "stack-name",
+		Branch:      "main",
+		Namespace:   "spacelift-io",
+		Repository:  "spacelift-operator",
+		Description: "description",
+		Labels:      []string{"a", "b"},
+		SpaceId:     "space-id",
+		// Not set in the spec, spacelift defaults are never reported
+		ProjectRoot: "root",
+		Autodeploy:  true,
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*models.Stack)
+		want   []string
+	}{
+		{
+			name:   "in sync",
+			mutate: func(*models.Stack) {},
+		},
+		{
+			name: "drifted",
+			mutate: func(s *models.Stack) {
+				s.Branch = "feature"
+				s.Namespace = "someone-else"
+				s.Administrative = true
+				s.Labels = []string{"a"}
+			},
+			want: []string{"branch", "repository", "administrative", "labels"},
+		},
+		{
+			name: "moved to another space",
+			mutate: func(s *models.Stack) {
+				s.SpaceId = "root"
+				s.Description = ""
+			},
+			want: []string{"description", "spaceId"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spaceliftStack := inSync
+			tt.mutate(&spaceliftStack)
+			assert.Equal(t, tt.want, Stack(stack, &spaceliftStack))
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	context := &v1beta1.Context{
+		ObjectMeta: metav1.ObjectMeta{Name: "context-name"},
+		Spec: v1beta1.ContextSpec{
+			SpaceId: utils.AddressOf("space-id"),
+			Labels:  []string{"label"},
+			Environment: []v1beta1.Environment{
+				{Id: "PLAIN", Value: utils.AddressOf("value")},
+				{Id: "SECRET", Value: utils.AddressOf("secret"), Secret: utils.AddressOf(true)},
+			},
+			MountedFiles: []v1beta1.MountedFile{
+				{Id: "file", Value: utils.AddressOf("content")},
+			},
+		},
+	}
+	inSync := func() models.Context {
+		return models.Context{
+			Name:    "context-name",
+			Labels:  []string{"label"},
+			SpaceId: "space-id",
+			Config: []models.ContextConfig{
+				{Id: "PLAIN", Type: "ENVIRONMENT_VARIABLE", Value: utils.AddressOf("value")},
+				{Id: "SECRET", Type: "ENVIRONMENT_VARIABLE", WriteOnly: true},
+				{Id: "file", Type: "FILE_MOUNT", Value: utils.AddressOf("content")},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*models.Context)
+		want   []string
+	}{
+		{
+			name:   "in sync",
+			mutate: func(*models.Context) {},
+		},
+		{
+			name: "value changed",
+			mutate: func(c *models.Context) {
+				c.Config[0].Value = utils.AddressOf("changed")
+			},
+			want: []string{"environment"},
+		},
+		{
+			name: "config element added",
+			mutate: func(c *models.Context) {
+				c.Config = append(c.Config, models.ContextConfig{Id: "other", Type: "FILE_MOUNT"})
+			},
+			want: []string{"mountedFiles"},
+		},
+		{
+			name: "config element removed",
+			mutate: func(c *models.Context) {
+				c.Config = c.Config[:2]
+				c.Name = "renamed"
+			},
+			want: []string{"name", "mountedFiles"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spaceliftContext := inSync()
+			tt.mutate(&spaceliftContext)
+			assert.Equal(t, tt.want, Context(context, &spaceliftContext))
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := &v1beta1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy-name"},
+		Spec: v1beta1.PolicySpec{
+			Body: "package spacelift",
+		},
+	}
+
+	assert.Empty(t, Policy(policy, &models.Policy{Name: "policy-name", Body: "package spacelift", Description: "set in spacelift"}))
+	assert.Equal(t, []string{"body", "labels"}, Policy(policy, &models.Policy{Name: "policy-name", Body: "package other", Labels: []string{"label"}}))
+}
+
+func TestStackChanges_Create(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository: "spacelift-io/spacelift-operator",
+			SpaceId:    utils.AddressOf("space-id"),
+		},
+	}
+
+	assert.Equal(t, []v1beta1.FieldChange{
+		{Field: "name", Desired: "stack-name"},
+		{Field: "branch", Desired: "main"},
+		{Field: "repository", Desired: "spacelift-io/spacelift-operator"},
+		{Field: "spaceId", Desired: "space-id"},
+	}, StackChanges(stack, &models.Stack{}))
+}
+
+func TestStackChanges_Dependencies(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository: "spacelift-operator",
+			DependsOn: []v1beta1.StackDependency{
+				{StackId: utils.AddressOf("network"), References: []v1beta1.StackDependencyReference{
+					{Output: "vpc_id", Input: "TF_VAR_vpc_id"},
+					{Output: "subnet_id", Input: "TF_VAR_subnet_id"},
+				}},
+				{StackId: utils.AddressOf("cluster")},
+			},
+		},
+	}
+	spaceliftStack := &models.Stack{
+		Name:       "stack-name",
+		Branch:     "main",
+		Repository: "spacelift-operator",
+		DependsOn: []models.StackDependency{
+			{Id: "dependency-1", DependsOnStackId: "cluster"},
+			{Id: "dependency-2", DependsOnStackId: "network", References: []models.StackDependencyReference{
+				{Id: "reference-1", OutputName: "subnet_id", InputName: "TF_VAR_subnet_id"},
+				{Id: "reference-2", OutputName: "vpc_id", InputName: "TF_VAR_vpc_id"},
+			}},
+		},
+	}
+	assert.Empty(t, StackChanges(stack, spaceliftStack))
+
+	spaceliftStack.DependsOn = spaceliftStack.DependsOn[:1]
+	assert.Equal(t, []v1beta1.FieldChange{{
+		Field:   "dependsOn",
+		Current: "cluster",
+		Desired: "cluster,network(subnet_id:TF_VAR_subnet_id vpc_id:TF_VAR_vpc_id)",
+	}}, StackChanges(stack, spaceliftStack))
+}
+
+func TestStackChanges_Config(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository: "spacelift-operator",
+			Environment: []v1beta1.Environment{
+				{Id: "REGION", Value: utils.AddressOf("eu-west-1")},
+				{Id: "TOKEN", Value: utils.AddressOf("secret-token"), Secret: utils.AddressOf(true)},
+			},
+			MountedFiles: []v1beta1.MountedFile{
+				{Id: "config.json", Value: utils.AddressOf("{}")},
+			},
+		},
+	}
+	spaceliftStack := &models.Stack{
+		Name:       "stack-name",
+		Branch:     "main",
+		Repository: "spacelift-operator",
+		Config: []models.ContextConfig{
+			{Id: "REGION", Type: "ENVIRONMENT_VARIABLE", Value: utils.AddressOf("us-east-1")},
+			{Id: "TOKEN", Type: "ENVIRONMENT_VARIABLE", WriteOnly: true},
+			{Id: "UNDECLARED", Type: "ENVIRONMENT_VARIABLE", Value: utils.AddressOf("value")},
+		},
+	}
+	assert.Equal(t, []v1beta1.FieldChange{
+		{Field: "environment.REGION", Current: "us-east-1", Desired: "eu-west-1"},
+		{Field: "mountedFiles.config.json", Desired: "{}"},
+		{Field: "environment.UNDECLARED", Current: "value"},
+	}, StackChanges(stack, spaceliftStack))
+	assert.Equal(t, []string{"environment", "mountedFiles"}, Stack(stack, spaceliftStack))
+}
+
+func TestStackChanges_Contexts(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository: "spacelift-operator",
+			Contexts: []v1beta1.StackContext{
+				{ContextId: utils.AddressOf("shared"), Priority: utils.AddressOf(1)},
+				{ContextId: utils.AddressOf("autoattached")},
+			},
+		},
+		Status: v1beta1.StackStatus{AttachedContextIds: []string{"shared", "removed"}},
+	}
+	spaceliftStack := &models.Stack{
+		Name:       "stack-name",
+		Branch:     "main",
+		Repository: "spacelift-operator",
+		AttachedContexts: []models.StackContextAttachment{
+			{Id: "attachment-1", ContextId: "shared", Priority: 1},
+			{Id: "attachment-2", ContextId: "autoattached", Priority: 5, IsAutoattached: true},
+			{Id: "attachment-3", ContextId: "attached-by-context"},
+		},
+	}
+	assert.Empty(t, StackChanges(stack, spaceliftStack))
+
+	spaceliftStack.AttachedContexts = append(spaceliftStack.AttachedContexts, models.StackContextAttachment{Id: "attachment-4", ContextId: "removed"})
+	spaceliftStack.AttachedContexts[0].Priority = 0
+	assert.Equal(t, []v1beta1.FieldChange{{
+		Field:   "contexts",
+		Current: "autoattached:0,removed:0,shared:0",
+		Desired: "autoattached:0,shared:1",
+	}}, StackChanges(stack, spaceliftStack))
+}
+
+func TestStackChanges_CloudIntegrations(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository: "spacelift-operator",
+			CloudIntegrations: []v1beta1.CloudIntegration{
+				{AWS: &v1beta1.AWSIntegration{Id: "aws", Read: true, Write: true}},
+				{Azure: &v1beta1.AzureIntegration{Id: "azure", Read: true, SubscriptionId: utils.AddressOf("subscription")}},
+				{GCP: &v1beta1.GCPIntegration{TokenScopes: []string{"scope-b", "scope-a"}}},
+			},
+		},
+	}
+	inSync := models.Stack{
+		Name:                      "stack-name",
+		Branch:                    "main",
+		Repository:                "spacelift-operator",
+		AttachedAWSIntegrations:   []models.StackAWSIntegration{{Id: "attachment-1", IntegrationId: "aws", Read: true, Write: true}},
+		AttachedAzureIntegrations: []models.StackAzureIntegration{{Id: "attachment-2", IntegrationId: "azure", Read: true, SubscriptionId: utils.AddressOf("subscription")}},
+		GCPIntegration:            &models.StackGCPIntegration{TokenScopes: []string{"scope-a", "scope-b"}},
+	}
+	assert.Empty(t, StackChanges(stack, &inSync))
+
+	drifted := inSync
+	drifted.AttachedAWSIntegrations = []models.StackAWSIntegration{
+		{Id: "attachment-1", IntegrationId: "aws", Read: true},
+		{Id: "attachment-3", IntegrationId: "removed", Read: true},
+	}
+	drifted.AttachedAzureIntegrations = nil
+	drifted.GCPIntegration = &models.StackGCPIntegration{TokenScopes: []string{"scope-a"}}
+	assert.Equal(t, []v1beta1.FieldChange{
+		{Field: "cloudIntegrations.aws", Current: "aws(read),removed(read)", Desired: "aws(read,write)"},
+		{Field: "cloudIntegrations.azure", Current: "", Desired: "azure(read)@subscription"},
+		{Field: "cloudIntegrations.gcp", Current: "[scope-a]", Desired: "[scope-a scope-b]"},
+	}, StackChanges(stack, &drifted))
+	assert.Equal(t, []string{"cloudIntegrations"}, Stack(stack, &drifted))
+
+	// Integrations removed from the spec are detached
+	legacy := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack-name"},
+		Spec: v1beta1.StackSpec{
+			Repository:     "spacelift-operator",
+			AWSIntegration: &v1beta1.AWSIntegration{Id: "aws", Read: true, Write: true},
+		},
+	}
+	assert.Equal(t, []v1beta1.FieldChange{
+		{Field: "cloudIntegrations.azure", Current: "azure(read)@subscription", Desired: ""},
+		{Field: "cloudIntegrations.gcp", Current: "[scope-a scope-b]", Desired: ""},
+	}, StackChanges(legacy, &inSync))
+	legacy.Spec.AWSIntegration.Write = false
+	assert.Equal(t, []string{"awsIntegration", "cloudIntegrations"}, Stack(legacy, &inSync))
+}
+
+func TestContextChanges(t *testing.T) {
+	context := &v1beta1.Context{
+		ObjectMeta: metav1.ObjectMeta{Name: "context-name"},
+		Spec: v1beta1.ContextSpec{
+			Environment: []v1beta1.Environment{
+				{Id: "PLAIN", Value: utils.AddressOf("value")},
+				{Id: "SECRET", Value: utils.AddressOf("secret"), Secret: utils.AddressOf(true)},
+			},
+		},
+	}
+	spaceliftContext := &models.Context{
+		Name: "context-name",
+		Config: []models.ContextConfig{
+			{Id: "PLAIN", Type: "ENVIRONMENT_VARIABLE", Value: utils.AddressOf("old")},
+			{Id: "REMOVED", Type: "ENVIRONMENT_VARIABLE", WriteOnly: true},
+			{Id: "file", Type: "FILE_MOUNT", Value: utils.AddressOf("content")},
+		},
+	}
+
+	// Secret values are never exposed
+	assert.Equal(t, []v1beta1.FieldChange{
+		{Field: "environment.PLAIN", Current: "old", Desired: "value"},
+		{Field: "environment.SECRET", Desired: "(sensitive)"},
+		{Field: "environment.REMOVED", Current: "(sensitive)"},
+		{Field: "mountedFiles.file", Current: "content"},
+	}, ContextChanges(context, spaceliftContext))
+	assert.Equal(t, []string{"environment", "mountedFiles"}, Context(context, spaceliftContext))
+}
+
+func TestSpaceChanges(t *testing.T) {
+	space := &v1beta1.Space{
+		ObjectMeta: metav1.ObjectMeta{Name: "space-name"},
+		Spec: v1beta1.SpaceSpec{
+			ParentSpace: "root",
+			Description: "description",
+		},
+	}
+
+	assert.Empty(t, SpaceChanges(space, &models.Space{Name: "space-name", ParentSpace: "root", Description: "description", Labels: []string{"label"}}))
+	assert.Equal(t, []v1beta1.FieldChange{
+		{Field: "description", Desired: "description"},
+		{Field: "parentSpace", Current: "other", Desired: "root"},
+	}, SpaceChanges(space, &models.Space{Name: "space-name", ParentSpace: "other"}))
+}
@@ -2,4 +2,18 @@ package models
 
 type Context struct {
 	Id string
+
+	Name        string
+	Description string
+	Labels      []string
+	SpaceId     string
+	Config      []ContextConfig
+	Url         string
+}
+
+type ContextConfig struct {
+	Id        string
+	Type      string
+	Value     *string
+	WriteOnly bool
 }
@@ -2,4 +2,11 @@ package models
 
 type Policy struct {
 	Id string `json:"id"`
+
+	Name        string   `json:"name"`
+	Body        string   `json:"body"`
+	Description string   `json:"description"`
+	Labels      []string `json:"labels"`
+	SpaceId     string   `json:"space"`
+	Url         string   `json:"url"`
 }
@@ -8,6 +8,72 @@ type Stack struct {
 	Id      string
 	Url     string
 	Outputs []StackOutput
+
+	Name           string
+	Description    string
+	Branch         string
+	Namespace      string
+	Repository     string
+	ProjectRoot    string
+	Labels         []string
+	Administrative bool
+	Autodeploy     bool
+	SpaceId        string
+	State          string
+	DependsOn      []StackDependency
+	// Config lists the environment variables and mounted files set on the stack itself, not the ones of its contexts
+	Config []ContextConfig
+	// AttachedContexts lists the contexts attached to the stack, whether by the stack, by the context or by labels
+	AttachedContexts []StackContextAttachment
+	// AttachedAWSIntegrations and AttachedAzureIntegrations list the cloud integrations attached to the stack
+	AttachedAWSIntegrations   []StackAWSIntegration
+	AttachedAzureIntegrations []StackAzureIntegration
+	// GCPIntegration is the GCP service account of the stack, nil when it has none
+	GCPIntegration *StackGCPIntegration
+}
+
+// StackAWSIntegration is an AWS integration attached to the stack.
+type StackAWSIntegration struct {
+	Id            string
+	IntegrationId string
+	Read          bool
+	Write         bool
+}
+
+// StackAzureIntegration is an Azure integration attached to the stack.
+type StackAzureIntegration struct {
+	Id             string
+	IntegrationId  string
+	Read           bool
+	Write          bool
+	SubscriptionId *string
+}
+
+// StackGCPIntegration is the GCP service account of the stack.
+type StackGCPIntegration struct {
+	TokenScopes []string
+}
+
+// StackContextAttachment is a context attached to the stack.
+type StackContextAttachment struct {
+	Id             string
+	ContextId      string
+	Priority       int
+	IsAutoattached bool
+}
+
+// StackDependency is a stack the stack depends on.
+type StackDependency struct {
+	Id               string
+	DependsOnStackId string
+	References       []StackDependencyReference
+}
+
+// StackDependencyReference passes an output of the dependency to an input of the stack.
+type StackDependencyReference struct {
+	Id         string
+	OutputName string
+	InputName  string
 }
 
 type StackOutput struct {
@@ -22,6 +22,7 @@ type ContextRepository interface {
 	Create(context.Context, *v1beta1.Context) (*models.Context, error)
 	Update(context.Context, *v1beta1.Context) (*models.Context, error)
 	Get(context.Context, *v1beta1.Context) (*models.Context, error)
+	Delete(context.Context, *v1beta1.Context) error
 }
 
 type contextRepository struct {
@@ -39,7 +40,7 @@ type contextCreateMutation struct {
 }
 
 func (r *contextRepository) Create(ctx context.Context, context *v1beta1.Context) (*models.Context, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, context.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(context))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating context")
 	}
@@ -70,7 +71,7 @@ type contextUpdateMutation struct {
 }
 
 func (r *contextRepository) Update(ctx context.Context, context *v1beta1.Context) (*models.Context, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, context.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(context))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating context")
 	}
@@ -97,13 +98,23 @@ func (r *contextRepository) Update(ctx context.Context, context *v1beta1.Context
 }
 
 func (r *contextRepository) Get(ctx context.Context, context *v1beta1.Context) (*models.Context, error) {
-	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, context.Namespace)
+	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, spaceliftclient.AccountOf(context))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while getting a space")
 	}
 	var query struct {
 		Context *struct {
-			Id string `graphql:"id"`
+			Id          string   `graphql:"id"`
+			Name        string   `graphql:"name"`
+			Description string   `graphql:"description"`
+			Labels      []string `graphql:"labels"`
+			Space       string   `graphql:"space"`
+			Config      []struct {
+				Id        string  `graphql:"id"`
+				Type      string  `graphql:"type"`
+				Value     *string `graphql:"value"`
+				WriteOnly bool    `graphql:"writeOnly"`
+			} `graphql:"config"`
 		} `graphql:"context(id: $id)"`
 	}
 	queryVariables := map[string]any{"id": graphql.ID(context.Status.Id)}
@@ -115,5 +126,47 @@ func (r *contextRepository) Get(ctx context.Context, context *v1beta1.Context) (
 		return nil, ErrContextNotFound
 	}
 
-	return &models.Context{Id: query.Context.Id}, nil
+	result := &models.Context{
+		Id:          query.Context.Id,
+		Name:        query.Context.Name,
+		Description: query.Context.Description,
+		Labels:      query.Context.Labels,
+		SpaceId:     query.Context.Space,
+		Config:      make([]models.ContextConfig, 0, len(query.Context.Config)),
+		Url:         c.URL("/context/%s", query.Context.Id),
+	}
+	for _, config := range query.Context.Config {
+		result.Config = append(result.Config, models.ContextConfig{
+			Id:        config.Id,
+			Type:      config.Type,
+			Value:     config.Value,
+			WriteOnly: config.WriteOnly,
+		})
+	}
+
+	return result, nil
+}
+
+type contextDeleteMutation struct {
+	ContextDelete struct {
+		Id string `graphql:"id"`
+	} `graphql:"contextDelete(id: $id)"`
+}
+
+func (r *contextRepository) Delete(ctx context.Context, context *v1beta1.Context) error {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(context))
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch spacelift client while deleting context")
+	}
+
+	var deleteMutation contextDeleteMutation
+	mutationVars := map[string]interface{}{
+		"id": graphql.ID(context.Status.Id),
+	}
+
+	if err := c.Mutate(ctx, &deleteMutation, mutationVars); err != nil {
+		return errors.Wrap(err, "unable to delete context")
+	}
+
+	return nil
 }
@@ -108,7 +108,7 @@ func Test_contextRepository_Create(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewContextRepository(nil)
@@ -162,7 +162,7 @@ func Test_contextRepository_Update(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewContextRepository(nil)
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shurcooL/graphql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/client/mocks"
+)
+
+func Test_Repository_Delete(t *testing.T) {
+	testCases := []struct {
+		name         string
+		mutation     string
+		delete       func() error
+		expectedVars map[string]any
+	}{
+		{
+			name:     "stack",
+			mutation: "*repository.stackDeleteMutation",
+			delete: func() error {
+				return NewStackRepository(nil).Delete(context.Background(), &v1beta1.Stack{
+					ObjectMeta: v1.ObjectMeta{Name: "stack-name"},
+					Status:     v1beta1.StackStatus{Id: "stack-id"},
+				})
+			},
+			expectedVars: map[string]any{"id": graphql.ID("stack-id")},
+		},
+		{
+			name:     "space",
+			mutation: "*repository.spaceDeleteMutation",
+			delete: func() error {
+				return NewSpaceRepository(nil).Delete(context.Background(), &v1beta1.Space{
+					ObjectMeta: v1.ObjectMeta{Name: "space-name"},
+					Status:     v1beta1.SpaceStatus{Id: "space-id"},
+				})
+			},
+			expectedVars: map[string]any{"space": graphql.ID("space-id")},
+		},
+		{
+			name:     "context",
+			mutation: "*repository.contextDeleteMutation",
+			delete: func() error {
+				return NewContextRepository(nil).Delete(context.Background(), &v1beta1.Context{
+					ObjectMeta: v1.ObjectMeta{Name: "context-name"},
+					Status:     v1beta1.ContextStatus{Id: "context-id"},
+				})
+			},
+			expectedVars: map[string]any{"id": graphql.ID("context-id")},
+		},
+		{
+			name:     "policy",
+			mutation: "*repository.policyDeleteMutation",
+			delete: func() error {
+				return NewPolicyRepository(nil).Delete(context.Background(), &v1beta1.Policy{
+					ObjectMeta: v1.ObjectMeta{Name: "policy-name"},
+					Status:     v1beta1.PolicyStatus{Id: "policy-id"},
+				})
+			},
+			expectedVars: map[string]any{"id": graphql.ID("policy-id")},
+		},
+	}
+
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	var fakeClient *mocks.Client
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fakeClient = mocks.NewClient(t)
+			var actualVars map[string]any
+			fakeClient.EXPECT().
+				Mutate(mock.Anything, mock.AnythingOfType(testCase.mutation), mock.Anything).
+				Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+					actualVars = vars
+				}).Return(nil)
+			require.NoError(t, testCase.delete())
+			assert.Equal(t, testCase.expectedVars, actualVars)
+		})
+	}
+}
@@ -83,6 +83,53 @@ func (_c *PolicyRepository_Create_Call) RunAndReturn(run func(context.Context, *
 	return _c
 }
 
+// Delete provides a mock function with given fields: _a0, _a1
+func (_m *PolicyRepository) Delete(_a0 context.Context, _a1 *v1beta1.Policy) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1beta1.Policy) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PolicyRepository_Delete_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Delete'
+type PolicyRepository_Delete_Call struct {
+	*mock.Call
+}
+
+// Delete is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - _a1 *v1beta1.Policy
+func (_e *PolicyRepository_Expecter) Delete(_a0 interface{}, _a1 interface{}) *PolicyRepository_Delete_Call {
+	return &PolicyRepository_Delete_Call{Call: _e.mock.On("Delete", _a0, _a1)}
+}
+
+func (_c *PolicyRepository_Delete_Call) Run(run func(_a0 context.Context, _a1 *v1beta1.Policy)) *PolicyRepository_Delete_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*v1beta1.Policy))
+	})
+	return _c
+}
+
+func (_c *PolicyRepository_Delete_Call) Return(_a0 error) *PolicyRepository_Delete_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *PolicyRepository_Delete_Call) RunAndReturn(run func(context.Context, *v1beta1.Policy) error) *PolicyRepository_Delete_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function with given fields: _a0, _a1
 func (_m *PolicyRepository) Get(_a0 context.Context, _a1 *v1beta1.Policy) (*models.Policy, error) {
 	ret := _m.Called(_a0, _a1)
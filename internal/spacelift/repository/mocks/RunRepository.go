@@ -5,9 +5,12 @@ package mocks
 import (
 	context "context"
 
-	models "github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+	client "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+
 	mock "github.com/stretchr/testify/mock"
 
+	models "github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+
 	v1beta1 "github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
 
@@ -24,6 +27,53 @@ func (_m *RunRepository) EXPECT() *RunRepository_Expecter {
 	return &RunRepository_Expecter{mock: &_m.Mock}
 }
 
+// Cancel provides a mock function with given fields: _a0, _a1
+func (_m *RunRepository) Cancel(_a0 context.Context, _a1 *v1beta1.Run) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Cancel")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1beta1.Run) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RunRepository_Cancel_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Cancel'
+type RunRepository_Cancel_Call struct {
+	*mock.Call
+}
+
+// Cancel is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - _a1 *v1beta1.Run
+func (_e *RunRepository_Expecter) Cancel(_a0 interface{}, _a1 interface{}) *RunRepository_Cancel_Call {
+	return &RunRepository_Cancel_Call{Call: _e.mock.On("Cancel", _a0, _a1)}
+}
+
+func (_c *RunRepository_Cancel_Call) Run(run func(_a0 context.Context, _a1 *v1beta1.Run)) *RunRepository_Cancel_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*v1beta1.Run))
+	})
+	return _c
+}
+
+func (_c *RunRepository_Cancel_Call) Return(_a0 error) *RunRepository_Cancel_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RunRepository_Cancel_Call) RunAndReturn(run func(context.Context, *v1beta1.Run) error) *RunRepository_Cancel_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Create provides a mock function with given fields: _a0, _a1
 func (_m *RunRepository) Create(_a0 context.Context, _a1 *v1beta1.Stack) (*models.Run, error) {
 	ret := _m.Called(_a0, _a1)
@@ -83,6 +133,112 @@ func (_c *RunRepository_Create_Call) RunAndReturn(run func(context.Context, *v1b
 	return _c
 }
 
+// CreateDestroyTask provides a mock function with given fields: _a0, _a1
+func (_m *RunRepository) CreateDestroyTask(_a0 context.Context, _a1 *v1beta1.Stack) (*models.Run, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateDestroyTask")
+	}
+
+	var r0 *models.Run
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1beta1.Stack) (*models.Run, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, *v1beta1.Stack) *models.Run); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*models.Run)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, *v1beta1.Stack) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RunRepository_CreateDestroyTask_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CreateDestroyTask'
+type RunRepository_CreateDestroyTask_Call struct {
+	*mock.Call
+}
+
+// CreateDestroyTask is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - _a1 *v1beta1.Stack
+func (_e *RunRepository_Expecter) CreateDestroyTask(_a0 interface{}, _a1 interface{}) *RunRepository_CreateDestroyTask_Call {
+	return &RunRepository_CreateDestroyTask_Call{Call: _e.mock.On("CreateDestroyTask", _a0, _a1)}
+}
+
+func (_c *RunRepository_CreateDestroyTask_Call) Run(run func(_a0 context.Context, _a1 *v1beta1.Stack)) *RunRepository_CreateDestroyTask_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*v1beta1.Stack))
+	})
+	return _c
+}
+
+func (_c *RunRepository_CreateDestroyTask_Call) Return(_a0 *models.Run, _a1 error) *RunRepository_CreateDestroyTask_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *RunRepository_CreateDestroyTask_Call) RunAndReturn(run func(context.Context, *v1beta1.Stack) (*models.Run, error)) *RunRepository_CreateDestroyTask_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Discard provides a mock function with given fields: _a0, _a1
+func (_m *RunRepository) Discard(_a0 context.Context, _a1 *v1beta1.Run) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Discard")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *v1beta1.Run) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RunRepository_Discard_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Discard'
+type RunRepository_Discard_Call struct {
+	*mock.Call
+}
+
+// Discard is a helper method to define mock.On call
+//   - _a0 context.Context
+//   - _a1 *v1beta1.Run
+func (_e *RunRepository_Expecter) Discard(_a0 interface{}, _a1 interface{}) *RunRepository_Discard_Call {
+	return &RunRepository_Discard_Call{Call: _e.mock.On("Discard", _a0, _a1)}
+}
+
+func (_c *RunRepository_Discard_Call) Run(run func(_a0 context.Context, _a1 *v1beta1.Run)) *RunRepository_Discard_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*v1beta1.Run))
+	})
+	return _c
+}
+
+func (_c *RunRepository_Discard_Call) Return(_a0 error) *RunRepository_Discard_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *RunRepository_Discard_Call) RunAndReturn(run func(context.Context, *v1beta1.Run) error) *RunRepository_Discard_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Get provides a mock function with given fields: _a0, _a1
 func (_m *RunRepository) Get(_a0 context.Context, _a1 *v1beta1.Run) (*models.Run, error) {
 	ret := _m.Called(_a0, _a1)
@@ -142,6 +298,66 @@ func (_c *RunRepository_Get_Call) RunAndReturn(run func(context.Context, *v1beta
 	return _c
 }
 
+// GetStates provides a mock function with given fields: ctx, account, runs
+func (_m *RunRepository) GetStates(ctx context.Context, account client.Account, runs []models.Run) (map[string]*models.Run, error) {
+	ret := _m.Called(ctx, account, runs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStates")
+	}
+
+	var r0 map[string]*models.Run
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, client.Account, []models.Run) (map[string]*models.Run, error)); ok {
+		return rf(ctx, account, runs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, client.Account, []models.Run) map[string]*models.Run); ok {
+		r0 = rf(ctx, account, runs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*models.Run)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, client.Account, []models.Run) error); ok {
+		r1 = rf(ctx, account, runs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RunRepository_GetStates_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetStates'
+type RunRepository_GetStates_Call struct {
+	*mock.Call
+}
+
+// GetStates is a helper method to define mock.On call
+//   - ctx context.Context
+//   - account client.Account
+//   - runs []models.Run
+func (_e *RunRepository_Expecter) GetStates(ctx interface{}, account interface{}, runs interface{}) *RunRepository_GetStates_Call {
+	return &RunRepository_GetStates_Call{Call: _e.mock.On("GetStates", ctx, account, runs)}
+}
+
+func (_c *RunRepository_GetStates_Call) Run(run func(ctx context.Context, account client.Account, runs []models.Run)) *RunRepository_GetStates_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(client.Account), args[2].([]models.Run))
+	})
+	return _c
+}
+
+func (_c *RunRepository_GetStates_Call) Return(_a0 map[string]*models.Run, _a1 error) *RunRepository_GetStates_Call {
+	_c.Call.Return(_a0, _a1)
+	return _c
+}
+
+func (_c *RunRepository_GetStates_Call) RunAndReturn(run func(context.Context, client.Account, []models.Run) (map[string]*models.Run, error)) *RunRepository_GetStates_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewRunRepository creates a new instance of RunRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRunRepository(t interface {
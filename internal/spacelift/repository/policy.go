@@ -23,6 +23,7 @@ type PolicyRepository interface {
 	Create(context.Context, *v1beta1.Policy) (*models.Policy, error)
 	Update(context.Context, *v1beta1.Policy) (*models.Policy, error)
 	Get(context.Context, *v1beta1.Policy) (*models.Policy, error)
+	Delete(context.Context, *v1beta1.Policy) error
 }
 
 type policyRepository struct {
@@ -55,6 +56,11 @@ type policyDetachMutation struct {
 		Id string `graphql:"id"`
 	} `graphql:"policyDetach(id: $id)"`
 }
+type policyDeleteMutation struct {
+	PolicyDelete struct {
+		Id string `graphql:"id"`
+	} `graphql:"policyDelete(id: $id)"`
+}
 type policyUpdate struct {
 	Id             string          `graphql:"id"`
 	AttachedStacks []attachedStack `graphql:"attachedStacks"`
@@ -68,7 +74,7 @@ func NewPolicyRepository(client client.Client) *policyRepository {
 }
 
 func (r *policyRepository) Create(ctx context.Context, policy *v1beta1.Policy) (*models.Policy, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, policy.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(policy))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating policy")
 	}
@@ -117,7 +123,7 @@ func (r *policyRepository) Create(ctx context.Context, policy *v1beta1.Policy) (
 }
 
 func (r *policyRepository) Update(ctx context.Context, policy *v1beta1.Policy) (*models.Policy, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, policy.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(policy))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while updating policy")
 	}
@@ -177,14 +183,19 @@ func (r *policyRepository) Update(ctx context.Context, policy *v1beta1.Policy) (
 }
 
 func (r *policyRepository) Get(ctx context.Context, policy *v1beta1.Policy) (*models.Policy, error) {
-	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, policy.Namespace)
+	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, spaceliftclient.AccountOf(policy))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while getting a policy")
 	}
 
 	var spaceQuery struct {
 		Policy *struct {
-			Id string `graphql:"id"`
+			Id          string   `graphql:"id"`
+			Name        string   `graphql:"name"`
+			Body        string   `graphql:"body"`
+			Description string   `graphql:"description"`
+			Labels      []string `graphql:"labels"`
+			Space       string   `graphql:"space"`
 		} `graphql:"policy(id: $id)"`
 	}
 
@@ -199,10 +210,32 @@ func (r *policyRepository) Get(ctx context.Context, policy *v1beta1.Policy) (*mo
 	}
 
 	return &models.Policy{
-		Id: spaceQuery.Policy.Id,
+		Id:          spaceQuery.Policy.Id,
+		Name:        spaceQuery.Policy.Name,
+		Body:        spaceQuery.Policy.Body,
+		Description: spaceQuery.Policy.Description,
+		Labels:      spaceQuery.Policy.Labels,
+		SpaceId:     spaceQuery.Policy.Space,
+		Url:         c.URL("/policy/%s", spaceQuery.Policy.Id),
 	}, nil
 }
 
+func (r *policyRepository) Delete(ctx context.Context, policy *v1beta1.Policy) error {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(policy))
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch spacelift client while deleting policy")
+	}
+
+	var deleteMutation policyDeleteMutation
+	vars := map[string]any{"id": graphql.ID(policy.Status.Id)}
+
+	if err := c.Mutate(ctx, &deleteMutation, vars); err != nil {
+		return errors.Wrap(err, "unable to delete policy")
+	}
+
+	return nil
+}
+
 func (*policyRepository) findStackToAttach(policy *v1beta1.Policy, attachedStacks []attachedStack) []string {
 	var stacksToAttach []string
 stacksToAttach:
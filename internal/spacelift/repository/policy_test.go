@@ -150,7 +150,7 @@ func Test_policyRepository_Create(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewPolicyRepository(nil)
@@ -177,7 +177,7 @@ func Test_policyRepository_Create_WithAttachedStacks(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewPolicyRepository(nil)
@@ -369,7 +369,7 @@ func Test_policyRepository_Update(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewPolicyRepository(nil)
@@ -395,7 +395,7 @@ func Test_policyRepository_Update_WithAttachedStacks(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewPolicyRepository(nil)
@@ -465,7 +465,7 @@ func Test_policyRepository_Update_DetachAllStacks(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewPolicyRepository(nil)
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	"github.com/pkg/errors"
 	"github.com/shurcooL/graphql"
@@ -12,10 +14,16 @@ import (
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 )
 
+var ErrRunNotFound = errors.New("run not found")
+
 //go:generate mockery --with-expecter --name RunRepository
 type RunRepository interface {
 	Create(context.Context, *v1beta1.Stack) (*models.Run, error)
 	Get(context.Context, *v1beta1.Run) (*models.Run, error)
+	GetStates(ctx context.Context, account spaceliftclient.Account, runs []models.Run) (map[string]*models.Run, error)
+	CreateDestroyTask(context.Context, *v1beta1.Stack) (*models.Run, error)
+	Cancel(context.Context, *v1beta1.Run) error
+	Discard(context.Context, *v1beta1.Run) error
 }
 
 type runRepository struct {
@@ -37,7 +45,7 @@ type createRunMutation struct {
 }
 
 func (r *runRepository) Create(ctx context.Context, stack *v1beta1.Stack) (*models.Run, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, stack.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(stack))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating run")
 	}
@@ -58,13 +66,13 @@ func (r *runRepository) Create(ctx context.Context, stack *v1beta1.Stack) (*mode
 }
 
 func (r *runRepository) Get(ctx context.Context, run *v1beta1.Run) (*models.Run, error) {
-	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, run.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(run))
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating run")
+		return nil, errors.Wrap(err, "unable to fetch spacelift client while getting run")
 	}
 	var query struct {
-		Stack struct {
-			Run struct {
+		Stack *struct {
+			Run *struct {
 				State string `graphql:"state"`
 			} `graphql:"run(id: $runId)"`
 		} `graphql:"stack(id: $stackId)"`
@@ -76,8 +84,195 @@ func (r *runRepository) Get(ctx context.Context, run *v1beta1.Run) (*models.Run,
 	if err := c.Query(ctx, &query, vars); err != nil {
 		return nil, errors.Wrap(err, "unable to get run")
 	}
+	if query.Stack == nil || query.Stack.Run == nil {
+		return nil, ErrRunNotFound
+	}
 	return &models.Run{
+		Id:      run.Status.Id,
+		Url:     c.URL("/stack/%s/run/%s", run.Status.StackId, run.Status.Id),
 		State:   query.Stack.Run.State,
 		StackId: run.Status.StackId,
 	}, nil
 }
+
+// batchRun is the part of a run fetched by GetStates
+type batchRun struct {
+	ID    string `graphql:"id"`
+	State string `graphql:"state"`
+}
+
+// GetStates fetches the state of many runs of the same account, identified by their Id and StackId, in a single query.
+// The query type is built at runtime, with one aliased stack field per stack and one aliased run field per run.
+// The result is keyed by run ID, runs that don't exist in spacelift are missing from it.
+func (r *runRepository) GetStates(ctx context.Context, account spaceliftclient.Account, runs []models.Run) (map[string]*models.Run, error) {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch spacelift client while getting runs")
+	}
+
+	var stackIds []string
+	runsByStack := map[string][]models.Run{}
+	for _, run := range runs {
+		if _, found := runsByStack[run.StackId]; !found {
+			stackIds = append(stackIds, run.StackId)
+		}
+		runsByStack[run.StackId] = append(runsByStack[run.StackId], run)
+	}
+
+	vars := map[string]any{}
+	stackFields := make([]reflect.StructField, 0, len(stackIds))
+	for i, stackId := range stackIds {
+		runFields := make([]reflect.StructField, 0, len(runsByStack[stackId]))
+		for j, run := range runsByStack[stackId] {
+			runFields = append(runFields, reflect.StructField{
+				Name: fmt.Sprintf("R%d", j),
+				Type: reflect.TypeOf(&batchRun{}),
+				Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"r%d: run(id: $run%d_%d)"`, j, i, j)),
+			})
+			vars[fmt.Sprintf("run%d_%d", i, j)] = graphql.ID(run.Id)
+		}
+		stackFields = append(stackFields, reflect.StructField{
+			Name: fmt.Sprintf("S%d", i),
+			Type: reflect.PointerTo(reflect.StructOf(runFields)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"s%d: stack(id: $stack%d)"`, i, i)),
+		})
+		vars[fmt.Sprintf("stack%d", i)] = graphql.ID(stackId)
+	}
+	if len(stackFields) == 0 {
+		return map[string]*models.Run{}, nil
+	}
+
+	query := reflect.New(reflect.StructOf(stackFields))
+	if err := c.Query(ctx, query.Interface(), vars); err != nil {
+		return nil, errors.Wrap(err, "unable to get runs")
+	}
+
+	result := make(map[string]*models.Run, len(runs))
+	for i, stackId := range stackIds {
+		stack := query.Elem().Field(i)
+		if stack.IsNil() {
+			continue
+		}
+		for j := range runsByStack[stackId] {
+			run := stack.Elem().Field(j).Interface().(*batchRun)
+			if run == nil {
+				continue
+			}
+			result[run.ID] = &models.Run{
+				Id:      run.ID,
+				State:   run.State,
+				StackId: stackId,
+			}
+		}
+	}
+	return result, nil
+}
+
+type cancelRunMutation struct {
+	RunCancel struct {
+		ID string `graphql:"id"`
+	} `graphql:"runCancel(stack: $stack, run: $run)"`
+}
+
+// Cancel cancels a run that is still queued.
+func (r *runRepository) Cancel(ctx context.Context, run *v1beta1.Run) error {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(run))
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch spacelift client while canceling run")
+	}
+	var mutation cancelRunMutation
+	vars := map[string]any{
+		"stack": graphql.ID(run.Status.StackId),
+		"run":   graphql.ID(run.Status.Id),
+	}
+	if err := c.Mutate(ctx, &mutation, vars); err != nil {
+		return errors.Wrap(err, "unable to cancel run")
+	}
+	return nil
+}
+
+type discardRunMutation struct {
+	RunDiscard struct {
+		ID string `graphql:"id"`
+	} `graphql:"runDiscard(stack: $stack, run: $run)"`
+}
+
+// Discard discards a run waiting for confirmation.
+func (r *runRepository) Discard(ctx context.Context, run *v1beta1.Run) error {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(run))
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch spacelift client while discarding run")
+	}
+	var mutation discardRunMutation
+	vars := map[string]any{
+		"stack": graphql.ID(run.Status.StackId),
+		"run":   graphql.ID(run.Status.Id),
+	}
+	if err := c.Mutate(ctx, &mutation, vars); err != nil {
+		return errors.Wrap(err, "unable to discard run")
+	}
+	return nil
+}
+
+type createTaskMutation struct {
+	TaskCreate struct {
+		ID    string `graphql:"id"`
+		State string `graphql:"state"`
+	} `graphql:"taskCreate(stack: $stack, command: $command, skipInitialization: $skipInitialization)"`
+}
+
+// CreateDestroyTask triggers a task destroying all the resources managed by the stack.
+func (r *runRepository) CreateDestroyTask(ctx context.Context, stack *v1beta1.Stack) (*models.Run, error) {
+	command, err := destroyCommand(stack)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(stack))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating destroy task")
+	}
+	var mutation createTaskMutation
+	vars := map[string]any{
+		"stack":              graphql.ID(stack.Status.Id),
+		"command":            graphql.String(command),
+		"skipInitialization": graphql.Boolean(false),
+	}
+	if err := c.Mutate(ctx, &mutation, vars); err != nil {
+		return nil, errors.Wrap(err, "unable to create destroy task")
+	}
+	url := c.URL("/stack/%s/run/%s", stack.Status.Id, mutation.TaskCreate.ID)
+	return &models.Run{
+		Id:      mutation.TaskCreate.ID,
+		State:   mutation.TaskCreate.State,
+		Url:     url,
+		StackId: stack.Status.Id,
+	}, nil
+}
+
+// destroyCommand returns the command destroying the resources of a stack depending on its vendor.
+// Stacks running a custom workflow tool can't be destroyed, since the command of the tool is unknown.
+// The vendors supported here must match the validation of destroyOnDelete in the Stack spec.
+func destroyCommand(stack *v1beta1.Stack) (string, error) {
+	vendor := stack.Spec.VendorConfig
+	switch {
+	case vendor == nil || *vendor == (v1beta1.VendorConfig{}):
+		return "terraform destroy -auto-approve", nil
+	case vendor.Terraform != nil && vendor.Terraform.WorkflowTool != nil && *vendor.Terraform.WorkflowTool == "CUSTOM":
+		return "", errors.New("destroying resources is not supported for stacks using a custom workflow tool")
+	case vendor.Terraform != nil:
+		if vendor.Terraform.WorkflowTool != nil && *vendor.Terraform.WorkflowTool == "OPEN_TOFU" {
+			return "tofu destroy -auto-approve", nil
+		}
+		return "terraform destroy -auto-approve", nil
+	case vendor.Terragrunt != nil:
+		if vendor.Terragrunt.UseRunAll {
+			return "terragrunt run-all destroy --terragrunt-non-interactive -auto-approve", nil
+		}
+		return "terragrunt destroy -auto-approve", nil
+	case vendor.Pulumi != nil:
+		return "pulumi destroy --non-interactive --yes", nil
+	default:
+		return "", errors.New("destroying resources is only supported for Terraform, OpenTofu, Terragrunt and Pulumi stacks")
+	}
+}
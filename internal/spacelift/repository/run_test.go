@@ -2,23 +2,29 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/shurcooL/graphql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/client/mocks"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+	"github.com/spacelift-io/spacelift-operator/internal/utils"
 )
 
 func Test_runRepository_Create(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 
@@ -49,3 +55,288 @@ func Test_runRepository_Create(t *testing.T) {
 	assert.Equal(t, "run-url", run.Url)
 	assert.Equal(t, "stack-id", run.StackId)
 }
+
+func Test_runRepository_Cancel(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	var actualVars map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.cancelRunMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			actualVars = vars
+		}).Return(nil)
+
+	fakeRun := &v1beta1.Run{
+		Status: v1beta1.RunStatus{
+			Id:      "run-id",
+			StackId: "stack-id",
+		},
+	}
+	repo := NewRunRepository(nil)
+	err := repo.Cancel(context.Background(), fakeRun)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"stack": graphql.ID("stack-id"),
+		"run":   graphql.ID("run-id"),
+	}, actualVars)
+}
+
+func Test_runRepository_Discard(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	var actualVars map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.discardRunMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			actualVars = vars
+		}).Return(nil)
+
+	fakeRun := &v1beta1.Run{
+		Status: v1beta1.RunStatus{
+			Id:      "run-id",
+			StackId: "stack-id",
+		},
+	}
+	repo := NewRunRepository(nil)
+	err := repo.Discard(context.Background(), fakeRun)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"stack": graphql.ID("stack-id"),
+		"run":   graphql.ID("run-id"),
+	}, actualVars)
+}
+
+func Test_runRepository_CreateDestroyTask(t *testing.T) {
+	testCases := []struct {
+		name            string
+		vendorConfig    *v1beta1.VendorConfig
+		expectedCommand graphql.String
+		expectedErr     string
+	}{
+		{
+			name:            "default vendor",
+			expectedCommand: "terraform destroy -auto-approve",
+		},
+		{
+			name: "opentofu",
+			vendorConfig: &v1beta1.VendorConfig{
+				Terraform: &v1beta1.TerraformConfig{WorkflowTool: utils.AddressOf("OPEN_TOFU")},
+			},
+			expectedCommand: "tofu destroy -auto-approve",
+		},
+		{
+			name: "pulumi",
+			vendorConfig: &v1beta1.VendorConfig{
+				Pulumi: &v1beta1.PulumiConfig{},
+			},
+			expectedCommand: "pulumi destroy --non-interactive --yes",
+		},
+		{
+			name:            "empty vendor config",
+			vendorConfig:    &v1beta1.VendorConfig{},
+			expectedCommand: "terraform destroy -auto-approve",
+		},
+		{
+			name: "custom workflow tool",
+			vendorConfig: &v1beta1.VendorConfig{
+				Terraform: &v1beta1.TerraformConfig{WorkflowTool: utils.AddressOf("CUSTOM")},
+			},
+			expectedErr: "destroying resources is not supported for stacks using a custom workflow tool",
+		},
+		{
+			name: "unsupported vendor",
+			vendorConfig: &v1beta1.VendorConfig{
+				Kubernetes: &v1beta1.KubernetesConfig{},
+			},
+			expectedErr: "destroying resources is only supported for Terraform, OpenTofu, Terragrunt and Pulumi stacks",
+		},
+	}
+
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	var fakeClient *mocks.Client
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+	repo := NewRunRepository(nil)
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			fakeClient = mocks.NewClient(t)
+			fakeStack := &v1beta1.Stack{
+				Spec: v1beta1.StackSpec{
+					VendorConfig: testCase.vendorConfig,
+				},
+				Status: v1beta1.StackStatus{
+					Id: "stack-id",
+				},
+			}
+
+			if testCase.expectedErr != "" {
+				_, err := repo.CreateDestroyTask(context.Background(), fakeStack)
+				assert.EqualError(t, err, testCase.expectedErr)
+				return
+			}
+
+			var actualVars map[string]any
+			fakeClient.EXPECT().
+				Mutate(mock.Anything, mock.AnythingOfType("*repository.createTaskMutation"), mock.Anything).
+				Run(func(_ context.Context, mutation any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+					actualVars = vars
+					taskMutation := mutation.(*createTaskMutation)
+					taskMutation.TaskCreate.ID = "run-id"
+					taskMutation.TaskCreate.State = "QUEUED"
+				}).Return(nil)
+			fakeClient.EXPECT().URL("/stack/%s/run/%s", "stack-id", "run-id").Return("run-url")
+
+			run, err := repo.CreateDestroyTask(context.Background(), fakeStack)
+			assert.NoError(t, err)
+			assert.Equal(t, graphql.ID("stack-id"), actualVars["stack"])
+			assert.Equal(t, testCase.expectedCommand, actualVars["command"])
+			assert.Equal(t, "run-id", run.Id)
+			assert.Equal(t, "QUEUED", run.State)
+			assert.Equal(t, "stack-id", run.StackId)
+		})
+	}
+}
+
+func Test_runRepository_GetStates(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	var actualVars map[string]any
+	var actualTags []string
+	fakeClient.EXPECT().
+		Query(mock.Anything, mock.Anything, mock.Anything).
+		Run(func(_ context.Context, query any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			actualVars = vars
+			// Fill the query as the graphql client would, stack-2 and run-id-2 don't exist
+			stacks := reflect.ValueOf(query).Elem()
+			for i := 0; i < stacks.NumField(); i++ {
+				actualTags = append(actualTags, stacks.Type().Field(i).Tag.Get("graphql"))
+				runs := reflect.New(stacks.Field(i).Type().Elem()).Elem()
+				for j := 0; j < runs.NumField(); j++ {
+					actualTags = append(actualTags, runs.Type().Field(j).Tag.Get("graphql"))
+				}
+				if vars[fmt.Sprintf("stack%d", i)] == graphql.ID("stack-2") {
+					continue
+				}
+				runs.Field(0).Set(reflect.ValueOf(&batchRun{ID: "run-id-1", State: "FINISHED"}))
+				stacks.Field(i).Set(runs.Addr())
+			}
+		}).Return(nil)
+
+	repo := NewRunRepository(nil)
+	runs, err := repo.GetStates(context.Background(), spaceliftclient.Account{Namespace: "default"}, []models.Run{
+		{Id: "run-id-1", StackId: "stack-1"},
+		{Id: "run-id-3", StackId: "stack-2"},
+		{Id: "run-id-2", StackId: "stack-1"},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"stack0": graphql.ID("stack-1"),
+		"run0_0": graphql.ID("run-id-1"),
+		"run0_1": graphql.ID("run-id-2"),
+		"stack1": graphql.ID("stack-2"),
+		"run1_0": graphql.ID("run-id-3"),
+	}, actualVars)
+	assert.Equal(t, []string{
+		"s0: stack(id: $stack0)",
+		"r0: run(id: $run0_0)",
+		"r1: run(id: $run0_1)",
+		"s1: stack(id: $stack1)",
+		"r0: run(id: $run1_0)",
+	}, actualTags)
+	assert.Equal(t, map[string]*models.Run{
+		"run-id-1": {Id: "run-id-1", State: "FINISHED", StackId: "stack-1"},
+	}, runs)
+}
+
+func Test_runRepository_Get(t *testing.T) {
+	testCases := []struct {
+		name      string
+		response  string
+		wantErr   error
+		wantState string
+	}{
+		{
+			name:      "run exists",
+			response:  `{"stack": {"run": {"state": "FINISHED"}}}`,
+			wantState: "FINISHED",
+		},
+		{
+			name:     "run does not exist",
+			response: `{"stack": {"run": null}}`,
+			wantErr:  ErrRunNotFound,
+		},
+		{
+			name:     "stack does not exist",
+			response: `{"stack": null}`,
+			wantErr:  ErrRunNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			originalClient := spaceliftclient.DefaultClient
+			defer func() { spaceliftclient.DefaultClient = originalClient }()
+			fakeClient := mocks.NewClient(t)
+			spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+				return fakeClient, nil
+			}
+
+			var actualVars map[string]any
+			fakeClient.EXPECT().
+				Query(mock.Anything, mock.Anything, mock.Anything).
+				Run(func(_ context.Context, query any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+					actualVars = vars
+					require.NoError(t, json.Unmarshal([]byte(tc.response), query))
+				}).Return(nil)
+			if tc.wantErr == nil {
+				fakeClient.EXPECT().URL("/stack/%s/run/%s", "stack-id", "run-id").Return("run-url")
+			}
+
+			fakeRun := &v1beta1.Run{
+				Status: v1beta1.RunStatus{
+					Id:      "run-id",
+					StackId: "stack-id",
+				},
+			}
+			repo := NewRunRepository(nil)
+			run, err := repo.Get(context.Background(), fakeRun)
+
+			assert.Equal(t, map[string]any{
+				"stackId": graphql.ID("stack-id"),
+				"runId":   graphql.ID("run-id"),
+			}, actualVars)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, &models.Run{
+				Id:      "run-id",
+				Url:     "run-url",
+				State:   tc.wantState,
+				StackId: "stack-id",
+			}, run)
+		})
+	}
+}
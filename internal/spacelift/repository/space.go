@@ -20,6 +20,7 @@ type SpaceRepository interface {
 	Create(context.Context, *v1beta1.Space) (*models.Space, error)
 	Update(context.Context, *v1beta1.Space) (*models.Space, error)
 	Get(context.Context, *v1beta1.Space) (*models.Space, error)
+	Delete(context.Context, *v1beta1.Space) error
 }
 
 type spaceRepository struct {
@@ -37,7 +38,7 @@ type spaceCreateMutation struct {
 }
 
 func (r *spaceRepository) Create(ctx context.Context, space *v1beta1.Space) (*models.Space, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, space.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(space))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating run")
 	}
@@ -63,7 +64,7 @@ type spaceUpdateMutation struct {
 }
 
 func (r *spaceRepository) Update(ctx context.Context, space *v1beta1.Space) (*models.Space, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, space.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(space))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while updating space")
 	}
@@ -86,7 +87,7 @@ func (r *spaceRepository) Update(ctx context.Context, space *v1beta1.Space) (*mo
 }
 
 func (r *spaceRepository) Get(ctx context.Context, space *v1beta1.Space) (*models.Space, error) {
-	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, space.Namespace)
+	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, spaceliftclient.AccountOf(space))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while getting a space")
 	}
@@ -122,3 +123,25 @@ func (r *spaceRepository) Get(ctx context.Context, space *v1beta1.Space) (*model
 		URL:             c.URL("/spaces/%s", spaceQuery.Space.ID),
 	}, nil
 }
+
+type spaceDeleteMutation struct {
+	SpaceDelete struct {
+		ID string `graphql:"id"`
+	} `graphql:"spaceDelete(space: $space)"`
+}
+
+func (r *spaceRepository) Delete(ctx context.Context, space *v1beta1.Space) error {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(space))
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch spacelift client while deleting space")
+	}
+
+	var mutation spaceDeleteMutation
+	vars := map[string]any{"space": graphql.ID(space.Status.Id)}
+
+	if err := c.Mutate(ctx, &mutation, vars); err != nil {
+		return errors.Wrap(err, "unable to delete space")
+	}
+
+	return nil
+}
@@ -65,7 +65,7 @@ func Test_spaceRepository_Create(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewSpaceRepository(nil)
@@ -94,7 +94,7 @@ func Test_spaceRepository_Update(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	fakeClient := mocks.NewClient(t)
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 
@@ -2,15 +2,12 @@ package repository
 
 import (
 	"context"
-	"slices"
 
 	"github.com/pkg/errors"
 	"github.com/shurcooL/graphql"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
-	"github.com/spacelift-io/spacelift-operator/internal/logging"
 	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/slug"
@@ -26,6 +23,7 @@ type StackRepository interface {
 	Create(context.Context, *v1beta1.Stack) (*models.Stack, error)
 	Update(context.Context, *v1beta1.Stack) (*models.Stack, error)
 	Get(context.Context, *v1beta1.Stack) (*models.Stack, error)
+	Delete(context.Context, *v1beta1.Stack) error
 }
 
 type stackRepository struct {
@@ -46,7 +44,7 @@ type stackCreateMutation struct {
 }
 
 func (r *stackRepository) Create(ctx context.Context, stack *v1beta1.Stack) (*models.Stack, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, stack.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(stack))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while creating stack")
 	}
@@ -68,11 +66,8 @@ func (r *stackRepository) Create(ctx context.Context, stack *v1beta1.Stack) (*mo
 	}
 	url := c.URL("/stack/%s", mutation.StackCreate.ID)
 
-	stack.Status.Id = mutation.StackCreate.ID
-	if stack.Spec.AWSIntegration != nil {
-		if err := r.attachAWSIntegration(ctx, stack); err != nil {
-			return nil, errors.Wrap(err, "unable to attach AWS integration to stack")
-		}
+	if err := r.syncCloudIntegrations(ctx, c, stack, mutation.StackCreate.ID, nil, nil, nil); err != nil {
+		return nil, errors.Wrap(err, "unable to attach cloud integrations to stack")
 	}
 
 	if stack.Spec.CommitSHA != nil && *stack.Spec.CommitSHA != "" {
@@ -81,78 +76,39 @@ func (r *stackRepository) Create(ctx context.Context, stack *v1beta1.Stack) (*mo
 		}
 	}
 
-	return &models.Stack{
-		Id:  mutation.StackCreate.ID,
-		Url: url,
-	}, nil
-}
-
-type awsIntegrationAttachMutation struct {
-	AWSIntegrationAttach struct {
-		Id string `graphql:"id"`
-	} `graphql:"awsIntegrationAttach(id: $id, stack: $stack, read: $read, write: $write)"`
-}
-
-func (r *stackRepository) attachAWSIntegration(ctx context.Context, stack *v1beta1.Stack) error {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, stack.Namespace)
-	if err != nil {
-		return errors.Wrap(err, "unable to fetch spacelift client while creating stack")
-	}
-	var mutation awsIntegrationAttachMutation
-	awsIntegrationAttachVars := map[string]any{
-		"id":    stack.Spec.AWSIntegration.Id,
-		"stack": stack.Status.Id,
-		"read":  graphql.Boolean(stack.Spec.AWSIntegration.Read),
-		"write": graphql.Boolean(stack.Spec.AWSIntegration.Write),
-	}
-
-	if err := c.Mutate(ctx, &mutation, awsIntegrationAttachVars); err != nil {
-		return err
+	if err := r.syncDependencies(ctx, c, stack, mutation.StackCreate.ID, nil); err != nil {
+		return nil, errors.Wrap(err, "unable to set stack dependencies")
 	}
 
-	return nil
-}
-
-type awsIntegrationDetachMutation struct {
-	AWSIntegrationDetach struct {
-		ID string `graphql:"id"`
-	} `graphql:"awsIntegrationDetach(id: $id)"`
-}
-
-func (r *stackRepository) detachAWSIntegration(ctx context.Context, stack *v1beta1.Stack, id string) error {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, stack.Namespace)
-	if err != nil {
-		return errors.Wrap(err, "unable to fetch spacelift client while detaching AWS integration")
-	}
-	var mutation awsIntegrationDetachMutation
-	vars := map[string]any{
-		"id": graphql.ID(id),
+	if err := r.syncConfig(ctx, c, stack, mutation.StackCreate.ID, nil); err != nil {
+		return nil, errors.Wrap(err, "unable to set stack environment")
 	}
 
-	if err := c.Mutate(ctx, &mutation, vars); err != nil {
-		return err
+	if err := r.syncContexts(ctx, c, stack, mutation.StackCreate.ID, nil); err != nil {
+		return nil, errors.Wrap(err, "unable to attach contexts to stack")
 	}
 
-	return nil
-}
-
-type stackUpdateMutationAWSIntegration struct {
-	ID            string `graphql:"id"`
-	IntegrationID string `graphql:"integrationId"`
-	Read          bool   `graphql:"read"`
-	Write         bool   `graphql:"write"`
+	return &models.Stack{
+		Id:  mutation.StackCreate.ID,
+		Url: url,
+	}, nil
 }
 
 type stackUpdateMutation struct {
 	StackUpdate struct {
-		ID                      string                              `graphql:"id"`
-		State                   string                              `graphql:"state"`
-		AttachedAWSIntegrations []stackUpdateMutationAWSIntegration `graphql:"attachedAwsIntegrations"`
+		ID                        string                              `graphql:"id"`
+		State                     string                              `graphql:"state"`
+		AttachedAWSIntegrations   []stackUpdateMutationAWSIntegration `graphql:"attachedAwsIntegrations"`
+		AttachedAzureIntegrations []stackAzureIntegration             `graphql:"attachedAzureIntegrations"`
+		Integrations              *stackIntegrations                  `graphql:"integrations"`
+		DependsOn                 []stackDependency                   `graphql:"dependsOn"`
+		Config                    []stackConfig                       `graphql:"config"`
+		AttachedContexts          []attachedContext                   `graphql:"attachedContexts"`
 	} `graphql:"stackUpdate(id: $id, input: $input)"`
 }
 
 func (r *stackRepository) Update(ctx context.Context, stack *v1beta1.Stack) (*models.Stack, error) {
-	c, err := spaceliftclient.DefaultClient(ctx, r.client, stack.Namespace)
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(stack))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while updating stack")
 	}
@@ -161,7 +117,7 @@ func (r *stackRepository) Update(ctx context.Context, stack *v1beta1.Stack) (*mo
 
 	stackInput := structs.FromStackSpec(stack)
 	vars := map[string]interface{}{
-		"id":    slug.SafeSlug(stack.Name()),
+		"id":    stackId(stack),
 		"input": stackInput,
 	}
 
@@ -169,37 +125,21 @@ func (r *stackRepository) Update(ctx context.Context, stack *v1beta1.Stack) (*mo
 		return nil, errors.Wrap(err, "unable to create stack")
 	}
 
-	logger := log.FromContext(ctx).WithValues(logging.StackId, mutation.StackUpdate.ID)
-
-	// First we check if there are any integrations to detach
-	// Any existing attachment that does not match spec.AWSIntegration will be detached.
-	attachedIntegrations := mutation.StackUpdate.AttachedAWSIntegrations
-	for i, integration := range attachedIntegrations {
-		if stack.Spec.AWSIntegration == nil ||
-			stack.Spec.AWSIntegration.Id != integration.IntegrationID ||
-			(stack.Spec.AWSIntegration.Id == integration.IntegrationID &&
-				(stack.Spec.AWSIntegration.Read != integration.Read ||
-					stack.Spec.AWSIntegration.Write != integration.Write)) {
-			if err := r.detachAWSIntegration(ctx, stack, integration.ID); err != nil {
-				return nil, errors.Wrap(err, "unable to detach AWS integration from stack")
-			}
-			logger.Info("Detached AWS integration from stack", logging.StackAWSIntegrationId, integration.IntegrationID)
-			// If we are detaching an integration, we also reflect this change to the mutation array.
-			// This allows an integration to be detached and reattached in a row if a read or write attribute has been changed.
-			mutation.StackUpdate.AttachedAWSIntegrations = append(mutation.StackUpdate.AttachedAWSIntegrations[:i], mutation.StackUpdate.AttachedAWSIntegrations[i+1:]...)
-		}
+	if err := r.syncCloudIntegrations(ctx, c, stack, mutation.StackUpdate.ID, mutation.StackUpdate.AttachedAWSIntegrations,
+		mutation.StackUpdate.AttachedAzureIntegrations, mutation.StackUpdate.Integrations); err != nil {
+		return nil, errors.Wrap(err, "unable to update stack cloud integrations")
 	}
 
-	if stack.Spec.AWSIntegration != nil {
-		shouldAttachInteration := !slices.ContainsFunc(mutation.StackUpdate.AttachedAWSIntegrations, func(s stackUpdateMutationAWSIntegration) bool {
-			return s.IntegrationID == stack.Spec.AWSIntegration.Id
-		})
-		if shouldAttachInteration {
-			if err := r.attachAWSIntegration(ctx, stack); err != nil {
-				return nil, errors.Wrap(err, "unable to attach AWS integration to stack")
-			}
-			logger.Info("Attached AWS integration to stack", logging.StackAWSIntegrationId, stack.Spec.AWSIntegration.Id)
-		}
+	if err := r.syncDependencies(ctx, c, stack, mutation.StackUpdate.ID, mutation.StackUpdate.DependsOn); err != nil {
+		return nil, errors.Wrap(err, "unable to update stack dependencies")
+	}
+
+	if err := r.syncConfig(ctx, c, stack, mutation.StackUpdate.ID, mutation.StackUpdate.Config); err != nil {
+		return nil, errors.Wrap(err, "unable to update stack environment")
+	}
+
+	if err := r.syncContexts(ctx, c, stack, mutation.StackUpdate.ID, mutation.StackUpdate.AttachedContexts); err != nil {
+		return nil, errors.Wrap(err, "unable to update stack contexts")
 	}
 
 	// TODO(michalg): URL can never change here, should we still generate it for k8s api?
@@ -211,7 +151,7 @@ func (r *stackRepository) Update(ctx context.Context, stack *v1beta1.Stack) (*mo
 }
 
 func (r *stackRepository) Get(ctx context.Context, stack *v1beta1.Stack) (*models.Stack, error) {
-	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, stack.Namespace)
+	c, err := spaceliftclient.GetSpaceliftClient(ctx, r.client, spaceliftclient.AccountOf(stack))
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch spacelift client while getting a stack")
 	}
@@ -222,10 +162,28 @@ func (r *stackRepository) Get(ctx context.Context, stack *v1beta1.Stack) (*model
 				Id    string `graphql:"id"`
 				Value string `graphql:"value"`
 			} `graphql:"outputs"`
+			Name             string            `graphql:"name"`
+			Description      string            `graphql:"description"`
+			Branch           string            `graphql:"branch"`
+			Namespace        string            `graphql:"namespace"`
+			Repository       string            `graphql:"repository"`
+			ProjectRoot      string            `graphql:"projectRoot"`
+			Labels           []string          `graphql:"labels"`
+			Administrative   bool              `graphql:"administrative"`
+			Autodeploy       bool              `graphql:"autodeploy"`
+			Space            string            `graphql:"space"`
+			State            string            `graphql:"state"`
+			DependsOn        []stackDependency `graphql:"dependsOn"`
+			Config           []stackConfig     `graphql:"config"`
+			AttachedContexts []attachedContext `graphql:"attachedContexts"`
+
+			AttachedAWSIntegrations   []stackUpdateMutationAWSIntegration `graphql:"attachedAwsIntegrations"`
+			AttachedAzureIntegrations []stackAzureIntegration             `graphql:"attachedAzureIntegrations"`
+			Integrations              *stackIntegrations                  `graphql:"integrations"`
 		} `graphql:"stack(id: $stackId)"`
 	}
 	vars := map[string]any{
-		"stackId": graphql.ID(slug.SafeSlug(stack.Name())),
+		"stackId": graphql.ID(stackId(stack)),
 	}
 	if err := c.Query(ctx, &query, vars); err != nil {
 		return nil, errors.Wrap(err, "unable to get stack")
@@ -236,8 +194,27 @@ func (r *stackRepository) Get(ctx context.Context, stack *v1beta1.Stack) (*model
 	}
 
 	s := &models.Stack{
-		Id:      query.Stack.Id,
-		Outputs: make([]models.StackOutput, 0, len(query.Stack.Outputs)),
+		Id:               query.Stack.Id,
+		Url:              c.URL("/stack/%s", query.Stack.Id),
+		Outputs:          make([]models.StackOutput, 0, len(query.Stack.Outputs)),
+		Name:             query.Stack.Name,
+		Description:      query.Stack.Description,
+		Branch:           query.Stack.Branch,
+		Namespace:        query.Stack.Namespace,
+		Repository:       query.Stack.Repository,
+		ProjectRoot:      query.Stack.ProjectRoot,
+		Labels:           query.Stack.Labels,
+		Administrative:   query.Stack.Administrative,
+		Autodeploy:       query.Stack.Autodeploy,
+		SpaceId:          query.Stack.Space,
+		State:            query.Stack.State,
+		DependsOn:        dependenciesModel(query.Stack.DependsOn),
+		Config:           configModel(query.Stack.Config),
+		AttachedContexts: attachedContextsModel(query.Stack.AttachedContexts),
+
+		AttachedAWSIntegrations:   awsIntegrationsModel(query.Stack.AttachedAWSIntegrations),
+		AttachedAzureIntegrations: azureIntegrationsModel(query.Stack.AttachedAzureIntegrations),
+		GCPIntegration:            gcpIntegrationModel(query.Stack.Integrations),
 	}
 
 	for _, output := range query.Stack.Outputs {
@@ -250,6 +227,43 @@ func (r *stackRepository) Get(ctx context.Context, stack *v1beta1.Stack) (*model
 	return s, nil
 }
 
+// stackId returns the ID of the stack in spacelift.
+// Synced stacks are identified by the ID in their status, so they are still found once renamed.
+// Before the first sync, stacks to adopt are identified by the adopt annotation, the others by the slug of their name.
+func stackId(stack *v1beta1.Stack) string {
+	if stack.Status.Id != "" {
+		return stack.Status.Id
+	}
+	if id := v1beta1.AdoptId(stack); id != "" {
+		return id
+	}
+	return slug.SafeSlug(stack.Name())
+}
+
+type stackDeleteMutation struct {
+	StackDelete struct {
+		ID string `graphql:"id"`
+	} `graphql:"stackDelete(id: $id)"`
+}
+
+func (r *stackRepository) Delete(ctx context.Context, stack *v1beta1.Stack) error {
+	c, err := spaceliftclient.DefaultClient(ctx, r.client, spaceliftclient.AccountOf(stack))
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch spacelift client while deleting stack")
+	}
+
+	var mutation stackDeleteMutation
+	vars := map[string]any{
+		"id": graphql.ID(stack.Status.Id),
+	}
+
+	if err := c.Mutate(ctx, &mutation, vars); err != nil {
+		return errors.Wrap(err, "unable to delete stack")
+	}
+
+	return nil
+}
+
 type setTrackedCommitMutation struct {
 	Stack struct {
 		ID string `graphql:"id"`
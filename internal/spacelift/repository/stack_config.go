@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/graphql"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/structs"
+)
+
+type stackConfig struct {
+	Id        string  `graphql:"id"`
+	Type      string  `graphql:"type"`
+	Value     *string `graphql:"value"`
+	WriteOnly bool    `graphql:"writeOnly"`
+}
+
+// ConfigType is the type of a config element, either an environment variable or a mounted file.
+type ConfigType string
+
+// ConfigInput represents the input required to set an environment variable or a mounted file on a stack.
+type ConfigInput struct {
+	Id          graphql.ID      `json:"id"`
+	Type        ConfigType      `json:"type"`
+	Value       graphql.String  `json:"value"`
+	WriteOnly   graphql.Boolean `json:"writeOnly"`
+	Description *graphql.String `json:"description"`
+}
+
+type stackConfigAddMutation struct {
+	StackConfigAdd struct {
+		Id string `graphql:"id"`
+	} `graphql:"stackConfigAdd(stack: $stack, config: $config)"`
+}
+
+type stackConfigDeleteMutation struct {
+	StackConfigDelete struct {
+		Id string `graphql:"id"`
+	} `graphql:"stackConfigDelete(stack: $stack, id: $id)"`
+}
+
+// syncConfig makes the environment variables and mounted files of the stack in spacelift match the spec.
+// Config elements that are not in the spec anymore are deleted, the declared ones are all set since
+// the values of write only elements can't be compared.
+func (r *stackRepository) syncConfig(ctx context.Context, c spaceliftclient.Client, stack *v1beta1.Stack, stackId string, existing []stackConfig) error {
+	logger := log.FromContext(ctx).WithValues(logging.StackId, stackId)
+
+	inputs, err := configInputs(stack)
+	if err != nil {
+		return err
+	}
+
+	for _, config := range existing {
+		if slices.ContainsFunc(inputs, func(i ConfigInput) bool { return i.Id == graphql.ID(config.Id) }) {
+			continue
+		}
+		var mutation stackConfigDeleteMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"stack": graphql.ID(stackId), "id": graphql.ID(config.Id)}); err != nil {
+			return errors.Wrapf(err, "unable to delete config element %s", config.Id)
+		}
+		logger.WithValues(logging.StackConfigId, config.Id).Info("Deleted stack config element")
+	}
+
+	for _, input := range inputs {
+		var mutation stackConfigAddMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"stack": graphql.ID(stackId), "config": input}); err != nil {
+			return errors.Wrapf(err, "unable to set config element %s", input.Id)
+		}
+	}
+
+	return nil
+}
+
+// configInputs returns the config elements declared in the spec, the values read from secrets must already be set.
+func configInputs(stack *v1beta1.Stack) ([]ConfigInput, error) {
+	inputs := make([]ConfigInput, 0, len(stack.Spec.Environment)+len(stack.Spec.MountedFiles))
+	add := func(configType ConfigType, id string, value *string, secret *bool, description *string) error {
+		// This should never happen because we don't reach this code if the secret is not found.
+		if value == nil {
+			return errors.Errorf("config value cannot be null for '%s'", id)
+		}
+		input := ConfigInput{
+			Id:        graphql.ID(id),
+			Type:      configType,
+			Value:     graphql.String(*value),
+			WriteOnly: graphql.Boolean(secret != nil && *secret),
+		}
+		if description != nil {
+			input.Description = graphql.NewString(graphql.String(*description))
+		}
+		inputs = append(inputs, input)
+		return nil
+	}
+	for _, env := range stack.Spec.Environment {
+		if err := add(structs.ConfigAttachmentTypeEnvVar, env.Id, env.Value, env.Secret, env.Description); err != nil {
+			return nil, err
+		}
+	}
+	for _, mountedFile := range stack.Spec.MountedFiles {
+		if err := add(structs.ConfigAttachmentTypeFileMount, mountedFile.Id, mountedFile.Value, mountedFile.Secret, mountedFile.Description); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+func configModel(configs []stackConfig) []models.ContextConfig {
+	result := make([]models.ContextConfig, 0, len(configs))
+	for _, config := range configs {
+		result = append(result, models.ContextConfig{
+			Id:        config.Id,
+			Type:      config.Type,
+			Value:     config.Value,
+			WriteOnly: config.WriteOnly,
+		})
+	}
+	return result
+}
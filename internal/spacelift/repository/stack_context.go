@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/graphql"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+)
+
+type attachedContext struct {
+	Id             string `graphql:"id"`
+	ContextId      string `graphql:"contextId"`
+	Priority       int    `graphql:"priority"`
+	IsAutoattached bool   `graphql:"isAutoattached"`
+}
+
+type contextAttachMutation struct {
+	ContextAttach struct {
+		Id string `graphql:"id"`
+	} `graphql:"contextAttach(id: $id, stack: $stack, priority: $priority)"`
+}
+
+type contextDetachMutation struct {
+	ContextDetach struct {
+		Id string `graphql:"id"`
+	} `graphql:"contextDetach(id: $id)"`
+}
+
+// syncContexts attaches the contexts of spec.contexts to the stack in spacelift.
+// Only the contexts previously attached through the spec, listed in status.attachedContextIds, are detached once removed
+// from it, attachments made by contexts themselves are left untouched.
+func (r *stackRepository) syncContexts(ctx context.Context, c spaceliftclient.Client, stack *v1beta1.Stack, stackId string, attached []attachedContext) error {
+	logger := log.FromContext(ctx).WithValues(logging.StackId, stackId)
+
+	for _, attachment := range findContextsToDetach(stack, attached) {
+		var mutation contextDetachMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"id": graphql.ID(attachment.Id)}); err != nil {
+			return errors.Wrapf(err, "unable to detach context %s", attachment.ContextId)
+		}
+		logger.WithValues(logging.StackContextId, attachment.ContextId).Info("Detached context from stack")
+	}
+
+	for _, stackContext := range findContextsToAttach(stack, attached) {
+		var mutation contextAttachMutation
+		vars := map[string]any{
+			"id":       graphql.ID(*stackContext.ContextId),
+			"stack":    graphql.ID(stackId),
+			"priority": graphql.Int(contextPriority(stackContext)),
+		}
+		if err := c.Mutate(ctx, &mutation, vars); err != nil {
+			return errors.Wrapf(err, "unable to attach context %s", *stackContext.ContextId)
+		}
+		logger.WithValues(logging.StackContextId, *stackContext.ContextId).Info("Attached context to stack")
+	}
+
+	return nil
+}
+
+// findContextsToDetach returns the attachments of the contexts removed from spec.contexts,
+// and the ones whose priority changed so they can be attached again.
+func findContextsToDetach(stack *v1beta1.Stack, attached []attachedContext) []attachedContext {
+	var attachmentsToDetach []attachedContext
+	for _, attachment := range attached {
+		if attachment.IsAutoattached {
+			continue
+		}
+		i := slices.IndexFunc(stack.Spec.Contexts, func(c v1beta1.StackContext) bool {
+			return c.ContextId != nil && *c.ContextId == attachment.ContextId
+		})
+		if i < 0 && slices.Contains(stack.Status.AttachedContextIds, attachment.ContextId) ||
+			i >= 0 && contextPriority(stack.Spec.Contexts[i]) != attachment.Priority {
+			attachmentsToDetach = append(attachmentsToDetach, attachment)
+		}
+	}
+	return attachmentsToDetach
+}
+
+// findContextsToAttach returns the contexts of spec.contexts that are not attached with their priority yet.
+func findContextsToAttach(stack *v1beta1.Stack, attached []attachedContext) []v1beta1.StackContext {
+	var contextsToAttach []v1beta1.StackContext
+	for _, stackContext := range stack.Spec.Contexts {
+		if stackContext.ContextId == nil {
+			continue
+		}
+		if slices.ContainsFunc(attached, func(a attachedContext) bool {
+			return a.ContextId == *stackContext.ContextId && (a.IsAutoattached || a.Priority == contextPriority(stackContext))
+		}) {
+			continue
+		}
+		contextsToAttach = append(contextsToAttach, stackContext)
+	}
+	return contextsToAttach
+}
+
+func contextPriority(stackContext v1beta1.StackContext) int {
+	if stackContext.Priority == nil {
+		return 0
+	}
+	return *stackContext.Priority
+}
+
+func attachedContextsModel(attached []attachedContext) []models.StackContextAttachment {
+	result := make([]models.StackContextAttachment, 0, len(attached))
+	for _, attachment := range attached {
+		result = append(result, models.StackContextAttachment{
+			Id:             attachment.Id,
+			ContextId:      attachment.ContextId,
+			Priority:       attachment.Priority,
+			IsAutoattached: attachment.IsAutoattached,
+		})
+	}
+	return result
+}
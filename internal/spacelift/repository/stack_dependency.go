@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/graphql"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+)
+
+type stackDependency struct {
+	Id             string `graphql:"id"`
+	DependsOnStack struct {
+		Id string `graphql:"id"`
+	} `graphql:"dependsOnStack"`
+	References []stackDependencyReference `graphql:"references"`
+}
+
+type stackDependencyReference struct {
+	Id         string `graphql:"id"`
+	OutputName string `graphql:"outputName"`
+	InputName  string `graphql:"inputName"`
+}
+
+// StackDependencyInput represents the input required to create a dependency between two stacks.
+type StackDependencyInput struct {
+	StackId          graphql.ID `json:"stackId"`
+	DependsOnStackId graphql.ID `json:"dependsOnStackId"`
+}
+
+// StackDependencyReferenceInput represents the input required to pass an output of a dependency to an input of the stack.
+type StackDependencyReferenceInput struct {
+	StackDependencyId graphql.ID     `json:"stackDependencyId"`
+	OutputName        graphql.String `json:"outputName"`
+	InputName         graphql.String `json:"inputName"`
+}
+
+type stackDependencyCreateMutation struct {
+	StackDependencyCreate struct {
+		Id string `graphql:"id"`
+	} `graphql:"stackDependencyCreate(input: $input)"`
+}
+
+type stackDependencyDeleteMutation struct {
+	StackDependencyDelete struct {
+		Id string `graphql:"id"`
+	} `graphql:"stackDependencyDelete(id: $id)"`
+}
+
+type stackDependencyReferenceUpsertMutation struct {
+	StackDependencyReferenceUpsert struct {
+		Id string `graphql:"id"`
+	} `graphql:"stackDependencyReferenceUpsert(input: $input)"`
+}
+
+type stackDependencyReferenceDeleteMutation struct {
+	StackDependencyReferenceDelete struct {
+		Id string `graphql:"id"`
+	} `graphql:"stackDependencyReferenceDelete(id: $id)"`
+}
+
+// syncDependencies makes the dependencies of the stack in spacelift match spec.dependsOn.
+// Dependencies and references that are not in the spec anymore are deleted, the missing ones are created.
+func (r *stackRepository) syncDependencies(ctx context.Context, c spaceliftclient.Client, stack *v1beta1.Stack, stackId string, existing []stackDependency) error {
+	logger := log.FromContext(ctx).WithValues(logging.StackId, stackId)
+
+	for _, dependencyId := range findDependenciesToDelete(stack, existing) {
+		var mutation stackDependencyDeleteMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"id": graphql.ID(dependencyId)}); err != nil {
+			return errors.Wrapf(err, "unable to delete stack dependency %s", dependencyId)
+		}
+		logger.WithValues(logging.StackDependencyId, dependencyId).Info("Deleted stack dependency")
+	}
+
+	for _, dependency := range stack.Spec.DependsOn {
+		if dependency.StackId == nil {
+			continue
+		}
+		i := slices.IndexFunc(existing, func(d stackDependency) bool { return d.DependsOnStack.Id == *dependency.StackId })
+		var dependencyId string
+		var references []stackDependencyReference
+		if i < 0 {
+			var mutation stackDependencyCreateMutation
+			if err := c.Mutate(ctx, &mutation, map[string]any{"input": StackDependencyInput{
+				StackId:          graphql.ID(stackId),
+				DependsOnStackId: graphql.ID(*dependency.StackId),
+			}}); err != nil {
+				return errors.Wrapf(err, "unable to create dependency on stack %s", *dependency.StackId)
+			}
+			dependencyId = mutation.StackDependencyCreate.Id
+			logger.WithValues(logging.StackDependencyId, dependencyId).Info("Created stack dependency")
+		} else {
+			dependencyId = existing[i].Id
+			references = existing[i].References
+		}
+
+		for _, referenceId := range findReferencesToDelete(dependency, references) {
+			var mutation stackDependencyReferenceDeleteMutation
+			if err := c.Mutate(ctx, &mutation, map[string]any{"id": graphql.ID(referenceId)}); err != nil {
+				return errors.Wrapf(err, "unable to delete reference %s of stack dependency %s", referenceId, dependencyId)
+			}
+		}
+		for _, reference := range findReferencesToUpsert(dependency, references) {
+			var mutation stackDependencyReferenceUpsertMutation
+			if err := c.Mutate(ctx, &mutation, map[string]any{"input": StackDependencyReferenceInput{
+				StackDependencyId: graphql.ID(dependencyId),
+				OutputName:        graphql.String(reference.Output),
+				InputName:         graphql.String(reference.Input),
+			}}); err != nil {
+				return errors.Wrapf(err, "unable to set reference %s of stack dependency %s", reference.Input, dependencyId)
+			}
+		}
+	}
+
+	return nil
+}
+
+// findDependenciesToDelete returns the IDs of the dependencies on stacks that are not in spec.dependsOn anymore.
+func findDependenciesToDelete(stack *v1beta1.Stack, existing []stackDependency) []string {
+	var dependenciesToDelete []string
+	for _, dependency := range existing {
+		if slices.ContainsFunc(stack.Spec.DependsOn, func(d v1beta1.StackDependency) bool {
+			return d.StackId != nil && *d.StackId == dependency.DependsOnStack.Id
+		}) {
+			continue
+		}
+		dependenciesToDelete = append(dependenciesToDelete, dependency.Id)
+	}
+	return dependenciesToDelete
+}
+
+// findReferencesToDelete returns the IDs of the references of a dependency that are not in the spec anymore.
+func findReferencesToDelete(dependency v1beta1.StackDependency, existing []stackDependencyReference) []string {
+	var referencesToDelete []string
+	for _, reference := range existing {
+		if slices.ContainsFunc(dependency.References, func(r v1beta1.StackDependencyReference) bool {
+			return r.Output == reference.OutputName && r.Input == reference.InputName
+		}) {
+			continue
+		}
+		referencesToDelete = append(referencesToDelete, reference.Id)
+	}
+	return referencesToDelete
+}
+
+// findReferencesToUpsert returns the references of the spec that don't exist on the dependency yet.
+func findReferencesToUpsert(dependency v1beta1.StackDependency, existing []stackDependencyReference) []v1beta1.StackDependencyReference {
+	var referencesToUpsert []v1beta1.StackDependencyReference
+	for _, reference := range dependency.References {
+		if slices.ContainsFunc(existing, func(r stackDependencyReference) bool {
+			return r.OutputName == reference.Output && r.InputName == reference.Input
+		}) {
+			continue
+		}
+		referencesToUpsert = append(referencesToUpsert, reference)
+	}
+	return referencesToUpsert
+}
+
+func dependenciesModel(dependencies []stackDependency) []models.StackDependency {
+	result := make([]models.StackDependency, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		d := models.StackDependency{
+			Id:               dependency.Id,
+			DependsOnStackId: dependency.DependsOnStack.Id,
+			References:       make([]models.StackDependencyReference, 0, len(dependency.References)),
+		}
+		for _, reference := range dependency.References {
+			d.References = append(d.References, models.StackDependencyReference{
+				Id:         reference.Id,
+				OutputName: reference.OutputName,
+				InputName:  reference.InputName,
+			})
+		}
+		result = append(result, d)
+	}
+	return result
+}
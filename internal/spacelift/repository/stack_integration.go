@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/graphql"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+)
+
+type stackUpdateMutationAWSIntegration struct {
+	ID            string `graphql:"id"`
+	IntegrationID string `graphql:"integrationId"`
+	Read          bool   `graphql:"read"`
+	Write         bool   `graphql:"write"`
+}
+
+type stackAzureIntegration struct {
+	ID             string  `graphql:"id"`
+	IntegrationID  string  `graphql:"integrationId"`
+	Read           bool    `graphql:"read"`
+	Write          bool    `graphql:"write"`
+	SubscriptionID *string `graphql:"subscriptionId"`
+}
+
+type stackIntegrations struct {
+	GCP struct {
+		Activated   bool     `graphql:"activated"`
+		TokenScopes []string `graphql:"tokenScopes"`
+	} `graphql:"gcp"`
+}
+
+type awsIntegrationAttachMutation struct {
+	AWSIntegrationAttach struct {
+		Id string `graphql:"id"`
+	} `graphql:"awsIntegrationAttach(id: $id, stack: $stack, read: $read, write: $write)"`
+}
+
+type awsIntegrationDetachMutation struct {
+	AWSIntegrationDetach struct {
+		ID string `graphql:"id"`
+	} `graphql:"awsIntegrationDetach(id: $id)"`
+}
+
+type azureIntegrationAttachMutation struct {
+	AzureIntegrationAttach struct {
+		ID string `graphql:"id"`
+	} `graphql:"azureIntegrationAttach(id: $id, stack: $stack, read: $read, write: $write, subscriptionId: $subscriptionId)"`
+}
+
+type azureIntegrationDetachMutation struct {
+	AzureIntegrationDetach struct {
+		ID string `graphql:"id"`
+	} `graphql:"azureIntegrationDetach(id: $id)"`
+}
+
+type gcpIntegrationCreateMutation struct {
+	StackIntegrationGcpCreate struct {
+		Activated bool `graphql:"activated"`
+	} `graphql:"stackIntegrationGcpCreate(id: $id, tokenScopes: $tokenScopes)"`
+}
+
+type gcpIntegrationDeleteMutation struct {
+	StackIntegrationGcpDelete struct {
+		Activated bool `graphql:"activated"`
+	} `graphql:"stackIntegrationGcpDelete(id: $id)"`
+}
+
+// syncCloudIntegrations makes the cloud integrations of the stack in spacelift match spec.awsIntegration and spec.cloudIntegrations.
+// Integrations that are not declared in the spec are detached, and the ones whose settings changed are detached and attached again.
+func (r *stackRepository) syncCloudIntegrations(ctx context.Context, c spaceliftclient.Client, stack *v1beta1.Stack, stackId string,
+	attachedAWS []stackUpdateMutationAWSIntegration, attachedAzure []stackAzureIntegration, integrations *stackIntegrations) error {
+	logger := log.FromContext(ctx).WithValues(logging.StackId, stackId)
+
+	desiredAWS := awsIntegrations(stack)
+	for _, attached := range attachedAWS {
+		if slices.ContainsFunc(desiredAWS, func(i v1beta1.AWSIntegration) bool { return sameAWSIntegration(i, attached) }) {
+			continue
+		}
+		var mutation awsIntegrationDetachMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"id": graphql.ID(attached.ID)}); err != nil {
+			return errors.Wrap(err, "unable to detach AWS integration from stack")
+		}
+		logger.Info("Detached AWS integration from stack", logging.StackAWSIntegrationId, attached.IntegrationID)
+	}
+	for _, integration := range desiredAWS {
+		if slices.ContainsFunc(attachedAWS, func(a stackUpdateMutationAWSIntegration) bool { return sameAWSIntegration(integration, a) }) {
+			continue
+		}
+		var mutation awsIntegrationAttachMutation
+		vars := map[string]any{
+			"id":    integration.Id,
+			"stack": stackId,
+			"read":  graphql.Boolean(integration.Read),
+			"write": graphql.Boolean(integration.Write),
+		}
+		if err := c.Mutate(ctx, &mutation, vars); err != nil {
+			return errors.Wrap(err, "unable to attach AWS integration to stack")
+		}
+		logger.Info("Attached AWS integration to stack", logging.StackAWSIntegrationId, integration.Id)
+	}
+
+	desiredAzure := azureIntegrations(stack)
+	for _, attached := range attachedAzure {
+		if slices.ContainsFunc(desiredAzure, func(i v1beta1.AzureIntegration) bool { return sameAzureIntegration(i, attached) }) {
+			continue
+		}
+		var mutation azureIntegrationDetachMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"id": graphql.ID(attached.ID)}); err != nil {
+			return errors.Wrap(err, "unable to detach Azure integration from stack")
+		}
+		logger.Info("Detached Azure integration from stack", logging.StackAzureIntegrationId, attached.IntegrationID)
+	}
+	for _, integration := range desiredAzure {
+		if slices.ContainsFunc(attachedAzure, func(a stackAzureIntegration) bool { return sameAzureIntegration(integration, a) }) {
+			continue
+		}
+		var mutation azureIntegrationAttachMutation
+		vars := map[string]any{
+			"id":             graphql.ID(integration.Id),
+			"stack":          graphql.ID(stackId),
+			"read":           graphql.Boolean(integration.Read),
+			"write":          graphql.Boolean(integration.Write),
+			"subscriptionId": (*graphql.String)(integration.SubscriptionId),
+		}
+		if err := c.Mutate(ctx, &mutation, vars); err != nil {
+			return errors.Wrap(err, "unable to attach Azure integration to stack")
+		}
+		logger.Info("Attached Azure integration to stack", logging.StackAzureIntegrationId, integration.Id)
+	}
+
+	// A stack has a single GCP service account, changing its token scopes creates it again
+	desiredGCP := gcpIntegration(stack)
+	activated := integrations != nil && integrations.GCP.Activated
+	upToDate := activated && desiredGCP != nil && sameTokenScopes(desiredGCP.TokenScopes, integrations.GCP.TokenScopes)
+	if activated && !upToDate {
+		var mutation gcpIntegrationDeleteMutation
+		if err := c.Mutate(ctx, &mutation, map[string]any{"id": graphql.ID(stackId)}); err != nil {
+			return errors.Wrap(err, "unable to delete GCP integration of stack")
+		}
+		logger.Info("Deleted GCP integration of stack")
+	}
+	if desiredGCP != nil && !upToDate {
+		tokenScopes := make([]graphql.String, 0, len(desiredGCP.TokenScopes))
+		for _, scope := range desiredGCP.TokenScopes {
+			tokenScopes = append(tokenScopes, graphql.String(scope))
+		}
+		var mutation gcpIntegrationCreateMutation
+		vars := map[string]any{
+			"id":          graphql.ID(stackId),
+			"tokenScopes": tokenScopes,
+		}
+		if err := c.Mutate(ctx, &mutation, vars); err != nil {
+			return errors.Wrap(err, "unable to create GCP integration of stack")
+		}
+		logger.Info("Created GCP integration of stack")
+	}
+
+	return nil
+}
+
+func awsIntegrationsModel(integrations []stackUpdateMutationAWSIntegration) []models.StackAWSIntegration {
+	result := make([]models.StackAWSIntegration, 0, len(integrations))
+	for _, integration := range integrations {
+		result = append(result, models.StackAWSIntegration{
+			Id:            integration.ID,
+			IntegrationId: integration.IntegrationID,
+			Read:          integration.Read,
+			Write:         integration.Write,
+		})
+	}
+	return result
+}
+
+func azureIntegrationsModel(integrations []stackAzureIntegration) []models.StackAzureIntegration {
+	result := make([]models.StackAzureIntegration, 0, len(integrations))
+	for _, integration := range integrations {
+		result = append(result, models.StackAzureIntegration{
+			Id:             integration.ID,
+			IntegrationId:  integration.IntegrationID,
+			Read:           integration.Read,
+			Write:          integration.Write,
+			SubscriptionId: integration.SubscriptionID,
+		})
+	}
+	return result
+}
+
+// gcpIntegrationModel returns the GCP service account of the stack, nil when it is not activated.
+func gcpIntegrationModel(integrations *stackIntegrations) *models.StackGCPIntegration {
+	if integrations == nil || !integrations.GCP.Activated {
+		return nil
+	}
+	return &models.StackGCPIntegration{TokenScopes: integrations.GCP.TokenScopes}
+}
+
+// awsIntegrations returns spec.awsIntegration along with the AWS integrations of spec.cloudIntegrations.
+func awsIntegrations(stack *v1beta1.Stack) []v1beta1.AWSIntegration {
+	var integrations []v1beta1.AWSIntegration
+	if stack.Spec.AWSIntegration != nil {
+		integrations = append(integrations, *stack.Spec.AWSIntegration)
+	}
+	for _, integration := range stack.Spec.CloudIntegrations {
+		if integration.AWS != nil {
+			integrations = append(integrations, *integration.AWS)
+		}
+	}
+	return integrations
+}
+
+func azureIntegrations(stack *v1beta1.Stack) []v1beta1.AzureIntegration {
+	var integrations []v1beta1.AzureIntegration
+	for _, integration := range stack.Spec.CloudIntegrations {
+		if integration.Azure != nil {
+			integrations = append(integrations, *integration.Azure)
+		}
+	}
+	return integrations
+}
+
+func gcpIntegration(stack *v1beta1.Stack) *v1beta1.GCPIntegration {
+	for _, integration := range stack.Spec.CloudIntegrations {
+		if integration.GCP != nil {
+			return integration.GCP
+		}
+	}
+	return nil
+}
+
+func sameAWSIntegration(integration v1beta1.AWSIntegration, attached stackUpdateMutationAWSIntegration) bool {
+	return integration.Id == attached.IntegrationID && integration.Read == attached.Read && integration.Write == attached.Write
+}
+
+// sameAzureIntegration compares the integrations, an unset subscription ID being the default subscription of the integration.
+func sameAzureIntegration(integration v1beta1.AzureIntegration, attached stackAzureIntegration) bool {
+	var subscriptionId, attachedSubscriptionId string
+	if integration.SubscriptionId != nil {
+		subscriptionId = *integration.SubscriptionId
+	}
+	if attached.SubscriptionID != nil {
+		attachedSubscriptionId = *attached.SubscriptionID
+	}
+	return integration.Id == attached.IntegrationID && integration.Read == attached.Read && integration.Write == attached.Write &&
+		subscriptionId == attachedSubscriptionId
+}
+
+// sameTokenScopes compares the scopes regardless of their order.
+func sameTokenScopes(scopes, attachedScopes []string) bool {
+	return slices.Equal(slices.Sorted(slices.Values(scopes)), slices.Sorted(slices.Values(attachedScopes)))
+}
@@ -44,7 +44,7 @@ func Test_stackRepository_Create(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewStackRepository(nil)
@@ -72,7 +72,7 @@ func Test_stackRepository_Create_WithCommitSHA(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewStackRepository(nil)
@@ -112,7 +112,7 @@ func Test_stackRepository_Create_WithAWSIntegration(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	var fakeClient *mocks.Client
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 	repo := NewStackRepository(nil)
@@ -158,7 +158,7 @@ func Test_stackRepository_Update(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	fakeClient := mocks.NewClient(t)
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 
@@ -188,15 +188,89 @@ func Test_stackRepository_Update(t *testing.T) {
 	}
 	_, err := repo.Update(context.Background(), fakeStack)
 	require.NoError(t, err)
-	assert.Equal(t, "stack-name", actualVars["id"])
+	assert.Equal(t, fakeStackId, actualVars["id"])
 	assert.IsType(t, structs.StackInput{}, actualVars["input"])
 }
 
+func Test_stackRepository_Update_Adopted(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	var actualVars map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			actualVars = vars
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = "existing-stack-id"
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", "existing-stack-id").Return("")
+
+	repo := NewStackRepository(nil)
+
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "stack-name",
+			Annotations: map[string]string{
+				v1beta1.AdoptAnnotation: "existing-stack-id",
+			},
+		},
+		Spec: v1beta1.StackSpec{
+			SpaceId: utils.AddressOf("space-id"),
+		},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+	assert.Equal(t, "existing-stack-id", actualVars["id"])
+}
+
+func Test_stackRepository_Update_Synced(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	var actualVars map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			actualVars = vars
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = "stack-id"
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", "stack-id").Return("")
+
+	repo := NewStackRepository(nil)
+
+	// The stack was renamed since it has been created, it is still updated through the ID of its status
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "renamed-stack",
+			Annotations: map[string]string{
+				v1beta1.AdoptAnnotation: "adopted-stack-id",
+			},
+		},
+		Spec: v1beta1.StackSpec{
+			SpaceId: utils.AddressOf("space-id"),
+		},
+		Status: v1beta1.StackStatus{Id: "stack-id"},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+	assert.Equal(t, "stack-id", actualVars["id"])
+}
+
 func Test_stackRepository_Update_WithAWSIntegration(t *testing.T) {
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	fakeClient := mocks.NewClient(t)
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 
@@ -252,7 +326,7 @@ func Test_stackRepository_Update_WithAWSIntegration(t *testing.T) {
 	}
 	_, err := repo.Update(context.Background(), fakeStack)
 	require.NoError(t, err)
-	assert.Equal(t, "stack-name", actualVars["id"])
+	assert.Equal(t, fakeStackId, actualVars["id"])
 	assert.IsType(t, structs.StackInput{}, actualVars["input"])
 	assert.Equal(t, map[string]any{
 		"id": graphql.ID("attachment-id"),
@@ -269,7 +343,7 @@ func Test_stackRepository_Update_WithAWSIntegration_UpdateExistingIntegration(t
 	originalClient := spaceliftclient.DefaultClient
 	defer func() { spaceliftclient.DefaultClient = originalClient }()
 	fakeClient := mocks.NewClient(t)
-	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ string) (spaceliftclient.Client, error) {
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
 		return fakeClient, nil
 	}
 
@@ -327,7 +401,7 @@ func Test_stackRepository_Update_WithAWSIntegration_UpdateExistingIntegration(t
 	}
 	_, err := repo.Update(context.Background(), fakeStack)
 	require.NoError(t, err)
-	assert.Equal(t, "stack-name", actualVars["id"])
+	assert.Equal(t, fakeStackId, actualVars["id"])
 	assert.IsType(t, structs.StackInput{}, actualVars["input"])
 	assert.Equal(t, map[string]any{
 		"id": graphql.ID("attachment-id"),
@@ -339,3 +413,351 @@ func Test_stackRepository_Update_WithAWSIntegration_UpdateExistingIntegration(t
 		"write": graphql.Boolean(false),
 	}, attachVars)
 }
+
+func Test_stackRepository_Update_WithDependencies(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	fakeStackId := "stack-id"
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, _ map[string]interface{}, _ ...graphql.RequestOption) {
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = fakeStackId
+			cluster := stackDependency{Id: "cluster-dependency", References: []stackDependencyReference{
+				{Id: "kubeconfig-reference", OutputName: "kubeconfig", InputName: "KUBECONFIG"},
+				{Id: "stale-reference", OutputName: "token", InputName: "TOKEN"},
+			}}
+			cluster.DependsOnStack.Id = "cluster"
+			removed := stackDependency{Id: "removed-dependency"}
+			removed.DependsOnStack.Id = "removed"
+			updateMutation.StackUpdate.DependsOn = []stackDependency{cluster, removed}
+		}).Return(nil)
+
+	var deleted, referencesDeleted []any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackDependencyDeleteMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			deleted = append(deleted, vars["id"])
+		}).Return(nil)
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackDependencyReferenceDeleteMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			referencesDeleted = append(referencesDeleted, vars["id"])
+		}).Return(nil)
+	var created []StackDependencyInput
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackDependencyCreateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			created = append(created, vars["input"].(StackDependencyInput))
+			mutation.(*stackDependencyCreateMutation).StackDependencyCreate.Id = "network-dependency"
+		}).Return(nil)
+	var upserted []StackDependencyReferenceInput
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackDependencyReferenceUpsertMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			upserted = append(upserted, vars["input"].(StackDependencyReferenceInput))
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", fakeStackId).Return("")
+
+	repo := NewStackRepository(nil)
+
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "stack-name",
+		},
+		Spec: v1beta1.StackSpec{
+			DependsOn: []v1beta1.StackDependency{
+				{StackId: utils.AddressOf("cluster"), References: []v1beta1.StackDependencyReference{
+					{Output: "kubeconfig", Input: "KUBECONFIG"},
+					{Output: "endpoint", Input: "TF_VAR_endpoint"},
+				}},
+				{StackId: utils.AddressOf("network"), References: []v1beta1.StackDependencyReference{
+					{Output: "vpc_id", Input: "TF_VAR_vpc_id"},
+				}},
+			},
+		},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+	assert.Equal(t, []any{graphql.ID("removed-dependency")}, deleted)
+	assert.Equal(t, []any{graphql.ID("stale-reference")}, referencesDeleted)
+	assert.Equal(t, []StackDependencyInput{{StackId: "stack-id", DependsOnStackId: "network"}}, created)
+	assert.Equal(t, []StackDependencyReferenceInput{
+		{StackDependencyId: "cluster-dependency", OutputName: "endpoint", InputName: "TF_VAR_endpoint"},
+		{StackDependencyId: "network-dependency", OutputName: "vpc_id", InputName: "TF_VAR_vpc_id"},
+	}, upserted)
+}
+
+func Test_stackRepository_Update_WithConfig(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	fakeStackId := "stack-id"
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, _ map[string]interface{}, _ ...graphql.RequestOption) {
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = fakeStackId
+			updateMutation.StackUpdate.Config = []stackConfig{
+				{Id: "REGION", Type: structs.ConfigAttachmentTypeEnvVar},
+				{Id: "UNDECLARED", Type: structs.ConfigAttachmentTypeEnvVar},
+			}
+		}).Return(nil)
+
+	var deleted []any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackConfigDeleteMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			assert.Equal(t, graphql.ID(fakeStackId), vars["stack"])
+			deleted = append(deleted, vars["id"])
+		}).Return(nil)
+	var added []ConfigInput
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackConfigAddMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			assert.Equal(t, graphql.ID(fakeStackId), vars["stack"])
+			added = append(added, vars["config"].(ConfigInput))
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", fakeStackId).Return("")
+
+	repo := NewStackRepository(nil)
+
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "stack-name",
+		},
+		Spec: v1beta1.StackSpec{
+			Environment: []v1beta1.Environment{
+				{Id: "REGION", Value: utils.AddressOf("eu-west-1")},
+				{Id: "TOKEN", Value: utils.AddressOf("secret"), Secret: utils.AddressOf(true)},
+			},
+			MountedFiles: []v1beta1.MountedFile{
+				{Id: "config.json", Value: utils.AddressOf("{}"), Description: utils.AddressOf("config")},
+			},
+		},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+	assert.Equal(t, []any{graphql.ID("UNDECLARED")}, deleted)
+	assert.Equal(t, []ConfigInput{
+		{Id: "REGION", Type: structs.ConfigAttachmentTypeEnvVar, Value: "eu-west-1"},
+		{Id: "TOKEN", Type: structs.ConfigAttachmentTypeEnvVar, Value: "secret", WriteOnly: true},
+		{Id: "config.json", Type: structs.ConfigAttachmentTypeFileMount, Value: "{}", Description: graphql.NewString("config")},
+	}, added)
+}
+
+func Test_stackRepository_Update_WithContexts(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	fakeStackId := "stack-id"
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, _ map[string]interface{}, _ ...graphql.RequestOption) {
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = fakeStackId
+			updateMutation.StackUpdate.AttachedContexts = []attachedContext{
+				{Id: "unchanged-attachment", ContextId: "unchanged", Priority: 1},
+				{Id: "reprioritized-attachment", ContextId: "reprioritized", Priority: 1},
+				{Id: "removed-attachment", ContextId: "removed"},
+				{Id: "foreign-attachment", ContextId: "attached-by-context"},
+				{Id: "auto-attachment", ContextId: "autoattached", IsAutoattached: true},
+			}
+		}).Return(nil)
+
+	var detached []any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.contextDetachMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			detached = append(detached, vars["id"])
+		}).Return(nil)
+	var attached []map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.contextAttachMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			attached = append(attached, vars)
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", fakeStackId).Return("")
+
+	repo := NewStackRepository(nil)
+
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "stack-name",
+		},
+		Spec: v1beta1.StackSpec{
+			Contexts: []v1beta1.StackContext{
+				{ContextId: utils.AddressOf("unchanged"), Priority: utils.AddressOf(1)},
+				{ContextId: utils.AddressOf("reprioritized"), Priority: utils.AddressOf(2)},
+				{ContextId: utils.AddressOf("autoattached")},
+				{ContextId: utils.AddressOf("new")},
+			},
+		},
+		Status: v1beta1.StackStatus{
+			AttachedContextIds: []string{"unchanged", "reprioritized", "removed"},
+		},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+	assert.Equal(t, []any{graphql.ID("reprioritized-attachment"), graphql.ID("removed-attachment")}, detached)
+	assert.Equal(t, []map[string]any{
+		{"id": graphql.ID("reprioritized"), "stack": graphql.ID(fakeStackId), "priority": graphql.Int(2)},
+		{"id": graphql.ID("new"), "stack": graphql.ID(fakeStackId), "priority": graphql.Int(0)},
+	}, attached)
+}
+
+func Test_stackRepository_Update_WithCloudIntegrations(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	fakeStackId := "stack-id"
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, _ map[string]interface{}, _ ...graphql.RequestOption) {
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = fakeStackId
+			updateMutation.StackUpdate.AttachedAWSIntegrations = []stackUpdateMutationAWSIntegration{
+				{ID: "legacy-attachment", IntegrationID: "legacy", Read: true, Write: true},
+				{ID: "removed-attachment", IntegrationID: "removed", Read: true},
+			}
+			updateMutation.StackUpdate.AttachedAzureIntegrations = []stackAzureIntegration{
+				{ID: "azure-unchanged-attachment", IntegrationID: "azure-unchanged", Read: true},
+				{ID: "azure-subscription-attachment", IntegrationID: "azure-subscription", Read: true, SubscriptionID: utils.AddressOf("old")},
+			}
+			updateMutation.StackUpdate.Integrations = &stackIntegrations{}
+			updateMutation.StackUpdate.Integrations.GCP.Activated = true
+			updateMutation.StackUpdate.Integrations.GCP.TokenScopes = []string{"scope-a"}
+		}).Return(nil)
+
+	var awsDetached []any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.awsIntegrationDetachMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			awsDetached = append(awsDetached, vars["id"])
+		}).Return(nil)
+	var awsAttached []any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.awsIntegrationAttachMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			awsAttached = append(awsAttached, vars["id"])
+		}).Return(nil)
+	var azureDetached []any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.azureIntegrationDetachMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			azureDetached = append(azureDetached, vars["id"])
+		}).Return(nil)
+	var azureAttached []map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.azureIntegrationAttachMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			azureAttached = append(azureAttached, vars)
+		}).Return(nil)
+	var gcpDeleteVars map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.gcpIntegrationDeleteMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			gcpDeleteVars = vars
+		}).Return(nil)
+	var gcpCreateVars map[string]any
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.gcpIntegrationCreateMutation"), mock.Anything).
+		Run(func(_ context.Context, _ any, vars map[string]interface{}, _ ...graphql.RequestOption) {
+			gcpCreateVars = vars
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", fakeStackId).Return("")
+
+	repo := NewStackRepository(nil)
+
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "stack-name",
+		},
+		Spec: v1beta1.StackSpec{
+			// The legacy field is kept along with the list
+			AWSIntegration: &v1beta1.AWSIntegration{Id: "legacy", Read: true, Write: true},
+			CloudIntegrations: []v1beta1.CloudIntegration{
+				{AWS: &v1beta1.AWSIntegration{Id: "new", Read: true}},
+				{Azure: &v1beta1.AzureIntegration{Id: "azure-unchanged", Read: true}},
+				{Azure: &v1beta1.AzureIntegration{Id: "azure-subscription", Read: true, SubscriptionId: utils.AddressOf("new")}},
+				{GCP: &v1beta1.GCPIntegration{TokenScopes: []string{"scope-a", "scope-b"}}},
+			},
+		},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+	assert.Equal(t, []any{graphql.ID("removed-attachment")}, awsDetached)
+	assert.Equal(t, []any{"new"}, awsAttached)
+	assert.Equal(t, []any{graphql.ID("azure-subscription-attachment")}, azureDetached)
+	assert.Equal(t, []map[string]any{{
+		"id":             graphql.ID("azure-subscription"),
+		"stack":          graphql.ID(fakeStackId),
+		"read":           graphql.Boolean(true),
+		"write":          graphql.Boolean(false),
+		"subscriptionId": graphql.NewString("new"),
+	}}, azureAttached)
+	// Changing the token scopes creates the service account again
+	assert.Equal(t, map[string]any{"id": graphql.ID(fakeStackId)}, gcpDeleteVars)
+	assert.Equal(t, map[string]any{
+		"id":          graphql.ID(fakeStackId),
+		"tokenScopes": []graphql.String{"scope-a", "scope-b"},
+	}, gcpCreateVars)
+}
+
+func Test_stackRepository_Update_WithCloudIntegrations_Unchanged(t *testing.T) {
+	originalClient := spaceliftclient.DefaultClient
+	defer func() { spaceliftclient.DefaultClient = originalClient }()
+	fakeClient := mocks.NewClient(t)
+	spaceliftclient.DefaultClient = func(_ context.Context, _ client.Client, _ spaceliftclient.Account) (spaceliftclient.Client, error) {
+		return fakeClient, nil
+	}
+
+	fakeStackId := "stack-id"
+	fakeClient.EXPECT().
+		Mutate(mock.Anything, mock.AnythingOfType("*repository.stackUpdateMutation"), mock.Anything).
+		Run(func(_ context.Context, mutation any, _ map[string]interface{}, _ ...graphql.RequestOption) {
+			updateMutation := mutation.(*stackUpdateMutation)
+			updateMutation.StackUpdate.ID = fakeStackId
+			updateMutation.StackUpdate.AttachedAzureIntegrations = []stackAzureIntegration{
+				{ID: "azure-attachment", IntegrationID: "azure", Write: true, SubscriptionID: utils.AddressOf("subscription")},
+			}
+			updateMutation.StackUpdate.Integrations = &stackIntegrations{}
+			updateMutation.StackUpdate.Integrations.GCP.Activated = true
+			updateMutation.StackUpdate.Integrations.GCP.TokenScopes = []string{"scope-b", "scope-a"}
+		}).Return(nil)
+	fakeClient.EXPECT().URL("/stack/%s", fakeStackId).Return("")
+
+	repo := NewStackRepository(nil)
+
+	// Nothing but the update mutation is expected, the order of the token scopes does not matter
+	fakeStack := &v1beta1.Stack{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "stack-name",
+		},
+		Spec: v1beta1.StackSpec{
+			CloudIntegrations: []v1beta1.CloudIntegration{
+				{Azure: &v1beta1.AzureIntegration{Id: "azure", Write: true, SubscriptionId: utils.AddressOf("subscription")}},
+				{GCP: &v1beta1.GCPIntegration{TokenScopes: []string{"scope-a", "scope-b"}}},
+			},
+		},
+	}
+	_, err := repo.Update(context.Background(), fakeStack)
+	require.NoError(t, err)
+}
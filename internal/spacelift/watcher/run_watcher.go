@@ -2,9 +2,11 @@ package watcher
 
 import (
 	"context"
+	"slices"
 	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
@@ -13,37 +15,95 @@ import (
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
 	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/metrics"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 	spaceliftRepository "github.com/spacelift-io/spacelift-operator/internal/spacelift/repository"
 )
 
+// pollResult tells the poller what to do after a run state has been fetched
+type pollResult int
+
+const (
+	// pollNext waits for the interval of the run state before polling again
+	pollNext pollResult = iota
+	// pollError waits for the error interval before polling again
+	pollError
+	// pollRetry polls again on the next tick
+	pollRetry
+	// pollStop stops watching the run
+	pollStop
+)
+
+// RunWatcher polls the state of runs and destroy tasks until they are terminated.
+// A single poller goroutine fetches the state of every due run in batched queries,
+// it is started with the first watched run and stops once no run is watched anymore.
 type RunWatcher struct {
 	Watcher
 
 	lock             sync.Mutex
-	watchedRuns      map[string]struct{}
+	watchedRuns      map[string]*watchedRun
+	polling          bool
+	now              func() time.Time
 	k8sRunRepo       *repository.RunRepository
+	k8sStackRepo     *repository.StackRepository
+	k8sNamespaceRepo *repository.NamespaceRepository
 	spaceliftRunRepo spaceliftRepository.RunRepository
 }
 
-func NewRunWatcher(k8sRunRepo *repository.RunRepository, spaceliftRunRepo spaceliftRepository.RunRepository) *RunWatcher {
+// watchedRun is a run or destroy task polled by the watcher
+type watchedRun struct {
+	account            spaceliftclient.Account
+	stackId, runId     string
+	logger             logr.Logger
+	deadline, nextPoll time.Time
+	state              string
+	stateSince         time.Time
+	// update reports the run fetched from spacelift on the kubernetes resource
+	update func(context.Context, *models.Run) pollResult
+}
+
+func NewRunWatcher(
+	k8sRunRepo *repository.RunRepository,
+	k8sStackRepo *repository.StackRepository,
+	k8sNamespaceRepo *repository.NamespaceRepository,
+	spaceliftRunRepo spaceliftRepository.RunRepository,
+) *RunWatcher {
 	return &RunWatcher{
 		Watcher:          DefaultWatcher,
 		lock:             sync.Mutex{},
-		watchedRuns:      map[string]struct{}{},
+		watchedRuns:      map[string]*watchedRun{},
+		now:              time.Now,
 		k8sRunRepo:       k8sRunRepo,
+		k8sStackRepo:     k8sStackRepo,
+		k8sNamespaceRepo: k8sNamespaceRepo,
 		spaceliftRunRepo: spaceliftRunRepo,
 	}
 }
 
 func (w *RunWatcher) IsWatched(run *v1beta1.Run) bool {
-	if run != nil && run.Status.Id != "" {
-		w.lock.Lock()
-		defer w.lock.Unlock()
-		if _, found := w.watchedRuns[run.Status.Id]; found {
-			return true
-		}
+	if run == nil {
+		return false
+	}
+	return w.isWatched(run.Status.Id)
+}
+
+// IsDestroyWatched returns true if the destroy task of the stack is being watched
+func (w *RunWatcher) IsDestroyWatched(stack *v1beta1.Stack) bool {
+	if stack == nil {
+		return false
+	}
+	return w.isWatched(stack.Status.DestroyRunId)
+}
+
+func (w *RunWatcher) isWatched(runId string) bool {
+	if runId == "" {
+		return false
 	}
-	return false
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, found := w.watchedRuns[runId]
+	return found
 }
 
 func (w *RunWatcher) Start(ctx context.Context, run *v1beta1.Run) error {
@@ -57,62 +117,238 @@ func (w *RunWatcher) Start(ctx context.Context, run *v1beta1.Run) error {
 		WithName("run_watcher").WithValues(
 		logging.RunId, run.Status.Id,
 	)
-	runId := run.Status.Id
-	w.lock.Lock()
-	w.watchedRuns[runId] = struct{}{}
-	w.lock.Unlock()
-	logger.Info("Starting watch")
-	go func() {
-		ctxWithTimeout, cancel := context.WithTimeout(ctx, w.Timeout)
-		defer func() {
-			cancel()
-			w.lock.Lock()
-			delete(w.watchedRuns, runId)
-			w.lock.Unlock()
-		}()
-		for {
-			select {
-			case <-ctxWithTimeout.Done():
-				logger.WithValues(logging.RunState, run.Status.State).Info("Timeout watching for run changes")
-				return
-			default:
-				var err error
-				run, err = w.k8sRunRepo.Get(ctxWithTimeout, types.NamespacedName{Namespace: run.Namespace, Name: run.Name})
-				if err != nil {
-					if k8sErrors.IsNotFound(err) {
-						logger.Info("Stopping run watcher since run has been removed from kube API")
-						return
-					}
-					logger.Error(err, "Error fetching run from k8s API")
-					time.Sleep(w.ErrInterval)
-					continue
+	name := types.NamespacedName{Namespace: run.Namespace, Name: run.Name}
+	w.watch(ctx, &watchedRun{
+		account: spaceliftclient.AccountOf(run),
+		stackId: run.Status.StackId,
+		runId:   run.Status.Id,
+		logger:  logger,
+		state:   string(run.Status.State),
+		update: func(ctx context.Context, spaceliftRun *models.Run) pollResult {
+			run, err := w.k8sRunRepo.Get(ctx, name)
+			if err != nil {
+				if k8sErrors.IsNotFound(err) {
+					logger.Info("Stopping run watcher since run has been removed from kube API")
+					return pollStop
 				}
-				spaceliftRun, err := w.spaceliftRunRepo.Get(ctxWithTimeout, run)
-				if err != nil {
-					logger.Error(err, "Error fetching run from spacelift API")
-					time.Sleep(w.ErrInterval)
-					continue
+				logger.Error(err, "Error fetching run from k8s API")
+				return pollError
+			}
+
+			// The run is watched again by the run controller once resumed
+			paused, err := w.isPaused(ctx, run)
+			if err != nil {
+				logger.Error(err, "Error fetching namespace from k8s API")
+				return pollError
+			}
+			if paused {
+				logger.Info("Run is paused, stopping run watcher")
+				return pollStop
+			}
+
+			run.SetRun(spaceliftRun)
+			if err := w.k8sRunRepo.UpdateStatus(ctx, run); err != nil {
+				if k8sErrors.IsConflict(err) {
+					logger.Info("Conflict updating run status, retrying on next tick")
+					return pollRetry
 				}
+				logger.Error(err, "Error updating run status")
+				return pollError
+			}
+
+			if run.IsTerminated() {
+				logger.WithValues(logging.RunState, run.Status.State).Info("Run is terminated, stopping run watcher")
+				return pollStop
+			}
+			return pollNext
+		},
+	})
+	return nil
+}
 
-				run.SetRun(spaceliftRun)
-				if err := w.k8sRunRepo.UpdateStatus(ctxWithTimeout, run); err != nil {
-					if k8sErrors.IsConflict(err) {
-						logger.Info("Conflict updating run status, retrying immediately")
-						continue
-					}
-					logger.Error(err, "Error updating run status")
-					time.Sleep(w.ErrInterval)
-					continue
+// isPaused returns true if the run status must not be updated, see v1beta1.PausedAnnotation
+func (w *RunWatcher) isPaused(ctx context.Context, run *v1beta1.Run) (bool, error) {
+	if v1beta1.IsPaused(run) {
+		return true, nil
+	}
+	return w.k8sNamespaceRepo.IsPaused(ctx, run.Namespace)
+}
+
+// StartDestroy watches the destroy task of a stack and reports its state in the stack status
+func (w *RunWatcher) StartDestroy(ctx context.Context, stack *v1beta1.Stack) error {
+	if stack.Status.DestroyRunId == "" {
+		return errors.New("Can't watch a destroy task that does not have any status.destroyRunId")
+	}
+	if w.IsDestroyWatched(stack) {
+		return errors.New("Cannot watch destroy task because it is already being watched")
+	}
+	logger := log.FromContext(ctx).
+		WithName("run_watcher").WithValues(
+		logging.RunId, stack.Status.DestroyRunId,
+		logging.StackId, stack.Status.Id,
+	)
+	name := types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}
+	w.watch(ctx, &watchedRun{
+		account: spaceliftclient.AccountOf(stack),
+		stackId: stack.Status.Id,
+		runId:   stack.Status.DestroyRunId,
+		logger:  logger,
+		state:   string(stack.Status.DestroyRunState),
+		update: func(ctx context.Context, spaceliftRun *models.Run) pollResult {
+			stack, err := w.k8sStackRepo.Get(ctx, name)
+			if err != nil {
+				if k8sErrors.IsNotFound(err) {
+					logger.Info("Stopping run watcher since stack has been removed from kube API")
+					return pollStop
 				}
+				logger.Error(err, "Error fetching stack from k8s API")
+				return pollError
+			}
 
-				if run.IsTerminated() {
-					logger.WithValues(logging.RunState, run.Status.State).Info("Run is terminated, stopping run watcher")
-					return
+			stack.Status.DestroyRunState = v1beta1.RunState(spaceliftRun.State)
+			if err := w.k8sStackRepo.UpdateStatus(ctx, stack); err != nil {
+				if k8sErrors.IsConflict(err) {
+					logger.Info("Conflict updating stack status, retrying on next tick")
+					return pollRetry
 				}
+				logger.Error(err, "Error updating stack status")
+				return pollError
+			}
 
-				time.Sleep(w.Interval)
+			if stack.IsDestroyTerminated() {
+				logger.WithValues(logging.RunState, stack.Status.DestroyRunState).Info("Destroy task is terminated, stopping run watcher")
+				return pollStop
 			}
-		}
-	}()
+			return pollNext
+		},
+	})
 	return nil
 }
+
+// watch adds the run to the watched runs, and starts the poller if it is not running
+func (w *RunWatcher) watch(ctx context.Context, run *watchedRun) {
+	now := w.now()
+	run.deadline = now.Add(w.Timeout)
+	run.nextPoll = now
+	run.stateSince = now
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.watchedRuns[run.runId] = run
+	metrics.WatchedRuns.Inc()
+	run.logger.Info("Starting watch")
+	if !w.polling {
+		w.polling = true
+		go w.poll(ctx)
+	}
+}
+
+// unwatch removes a run from the watched runs, unless it has been watched again since, the lock must be held by the caller
+func (w *RunWatcher) unwatch(run *watchedRun) {
+	if w.watchedRuns[run.runId] != run {
+		return
+	}
+	delete(w.watchedRuns, run.runId)
+	metrics.WatchedRuns.Dec()
+}
+
+// poll fetches the state of the due runs on every tick, until no run is watched anymore or the context is done
+func (w *RunWatcher) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.Tick)
+	defer ticker.Stop()
+	for {
+		if !w.pollDue(ctx) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			for _, run := range w.watchedRuns {
+				run.logger.Info("Stopping run watcher", "reason", ctx.Err().Error())
+				w.unwatch(run)
+			}
+			w.polling = false
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollDue polls the runs that are due, grouped by account since a query can only be sent with the credentials of one account.
+// It returns false once no run is watched anymore, so the poller stops.
+func (w *RunWatcher) pollDue(ctx context.Context) bool {
+	now := w.now()
+	due := map[spaceliftclient.Account][]*watchedRun{}
+
+	w.lock.Lock()
+	if len(w.watchedRuns) == 0 {
+		w.polling = false
+		w.lock.Unlock()
+		return false
+	}
+	for _, run := range w.watchedRuns {
+		if now.After(run.deadline) {
+			run.logger.Info("Timeout watching for run changes")
+			w.unwatch(run)
+			continue
+		}
+		if !run.nextPoll.After(now) {
+			due[run.account] = append(due[run.account], run)
+		}
+	}
+	w.lock.Unlock()
+
+	for account, runs := range due {
+		for batch := range slices.Chunk(runs, max(w.BatchSize, 1)) {
+			w.pollBatch(ctx, account, batch)
+		}
+	}
+	return true
+}
+
+// pollBatch fetches the state of the runs in a single query, and reports it on their kubernetes resources
+func (w *RunWatcher) pollBatch(ctx context.Context, account spaceliftclient.Account, batch []*watchedRun) {
+	runs := make([]models.Run, 0, len(batch))
+	for _, run := range batch {
+		runs = append(runs, models.Run{Id: run.runId, StackId: run.stackId, State: run.state})
+	}
+	spaceliftRuns, err := w.spaceliftRunRepo.GetStates(ctx, account, runs)
+
+	for _, run := range batch {
+		if err != nil {
+			run.logger.Error(err, "Error fetching run from spacelift API")
+			w.schedule(run, pollError)
+			continue
+		}
+		spaceliftRun, found := spaceliftRuns[run.runId]
+		if !found {
+			run.logger.Info("Run not found in spacelift API")
+			w.schedule(run, pollError)
+			continue
+		}
+		if spaceliftRun.State != run.state {
+			run.state = spaceliftRun.State
+			run.stateSince = w.now()
+		}
+		w.schedule(run, run.update(ctx, spaceliftRun))
+	}
+}
+
+// schedule computes when the run should be polled again, or stops watching it
+func (w *RunWatcher) schedule(run *watchedRun, result pollResult) {
+	now := w.now()
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	switch result {
+	case pollStop:
+		w.unwatch(run)
+	case pollRetry:
+		run.nextPoll = now
+	case pollError:
+		run.nextPoll = now.Add(w.ErrInterval)
+	default:
+		run.nextPoll = now.Add(w.interval(run.state, now.Sub(run.stateSince)))
+	}
+}
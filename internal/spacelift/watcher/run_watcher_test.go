@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/repository/mocks"
+)
+
+func TestWatcher_interval(t *testing.T) {
+	w := DefaultWatcher
+
+	assert.Equal(t, DefaultInterval, w.interval("QUEUED", time.Hour))
+	assert.Equal(t, DefaultInterval, w.interval("PLANNING", time.Hour))
+	assert.Equal(t, DefaultInterval, w.interval("APPLYING", time.Minute))
+	assert.Equal(t, DefaultSlowInterval, w.interval("APPLYING", DefaultSlowAfter))
+	assert.Equal(t, DefaultSlowInterval, w.interval("UNCONFIRMED", 0))
+
+	// The slow interval never makes polling faster
+	w.Interval = time.Minute
+	assert.Equal(t, time.Minute, w.interval("UNCONFIRMED", 0))
+
+	// The fallback interval is used whatever the run state
+	w.FallbackInterval = 5 * time.Minute
+	assert.Equal(t, 5*time.Minute, w.interval("QUEUED", 0))
+	assert.Equal(t, 5*time.Minute, w.interval("UNCONFIRMED", 0))
+}
+
+func TestRunWatcher_BatchesRuns(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+
+	run := func(name, runId, stackId string) *v1beta1.Run {
+		return &v1beta1.Run{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Status:     v1beta1.RunStatus{Id: runId, StackId: stackId, State: v1beta1.RunStateQueued},
+		}
+	}
+	runs := []*v1beta1.Run{
+		run("run-1", "run-id-1", "stack-1"),
+		run("run-2", "run-id-2", "stack-1"),
+		run("run-3", "run-id-3", "stack-2"),
+	}
+	objects := make([]client.Object, 0, len(runs))
+	for _, run := range runs {
+		objects = append(objects, run)
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&v1beta1.Run{}).
+		WithObjects(objects...).
+		Build()
+
+	spaceliftRunRepo := mocks.NewRunRepository(t)
+	spaceliftRunRepo.EXPECT().
+		GetStates(mock.Anything, spaceliftclient.Account{Namespace: "default"}, mock.MatchedBy(func(runs []models.Run) bool {
+			return len(runs) == 3
+		})).
+		RunAndReturn(func(_ context.Context, _ spaceliftclient.Account, runs []models.Run) (map[string]*models.Run, error) {
+			result := map[string]*models.Run{}
+			for _, run := range runs {
+				result[run.Id] = &models.Run{Id: run.Id, StackId: run.StackId, State: string(v1beta1.RunStateFinished)}
+			}
+			return result, nil
+		}).
+		Once()
+
+	w := NewRunWatcher(repository.NewRunRepository(k8sClient, scheme), nil, repository.NewNamespaceRepository(k8sClient), spaceliftRunRepo)
+	w.Tick = 10 * time.Millisecond
+	// Start the poller once all runs are watched, so they are all due on the first tick
+	w.polling = true
+	for _, run := range runs {
+		require.NoError(t, w.Start(context.Background(), run))
+	}
+	go w.poll(context.Background())
+
+	assert.Eventually(t, func() bool {
+		return !w.IsWatched(runs[0]) && !w.IsWatched(runs[1]) && !w.IsWatched(runs[2])
+	}, time.Second, 10*time.Millisecond)
+	for _, run := range runs {
+		var updated v1beta1.Run
+		require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, &updated))
+		assert.Equal(t, v1beta1.RunStateFinished, updated.Status.State)
+	}
+}
+
+func TestRunWatcher_StopsPausedRuns(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+
+	run := &v1beta1.Run{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "paused", Name: "run"},
+		Status:     v1beta1.RunStatus{Id: "run-id", StackId: "stack-id", State: v1beta1.RunStateQueued},
+	}
+	namespace := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "paused", Annotations: map[string]string{v1beta1.PausedAnnotation: "true"}},
+	}
+	k8sClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&v1beta1.Run{}).
+		WithObjects(run, namespace).
+		Build()
+
+	spaceliftRunRepo := mocks.NewRunRepository(t)
+	spaceliftRunRepo.EXPECT().
+		GetStates(mock.Anything, mock.Anything, mock.Anything).
+		Return(map[string]*models.Run{"run-id": {Id: "run-id", StackId: "stack-id", State: string(v1beta1.RunStateFinished)}}, nil).
+		Once()
+
+	w := NewRunWatcher(repository.NewRunRepository(k8sClient, scheme), nil, repository.NewNamespaceRepository(k8sClient), spaceliftRunRepo)
+	w.Tick = 10 * time.Millisecond
+	w.polling = true
+	require.NoError(t, w.Start(context.Background(), run))
+	go w.poll(context.Background())
+
+	assert.Eventually(t, func() bool {
+		return !w.IsWatched(run)
+	}, time.Second, 10*time.Millisecond)
+	var updated v1beta1.Run
+	require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, &updated))
+	assert.Equal(t, v1beta1.RunStateQueued, updated.Status.State)
+}
@@ -3,18 +3,56 @@ package watcher
 import "time"
 
 const (
-	DefaultTimeout     = 70 * time.Minute
-	DefaultInterval    = 3 * time.Second
-	DefaultErrInterval = 10 * time.Second
+	DefaultTimeout      = 70 * time.Minute
+	DefaultInterval     = 3 * time.Second
+	DefaultErrInterval  = 10 * time.Second
+	DefaultSlowInterval = 30 * time.Second
+	DefaultSlowAfter    = 2 * time.Minute
+	DefaultTick         = time.Second
+	DefaultBatchSize    = 50
 )
 
 type Watcher struct {
 	Interval, ErrInterval time.Duration
 	Timeout               time.Duration
+	// SlowInterval is used for runs waiting for a confirmation, or applying for longer than SlowAfter
+	SlowInterval, SlowAfter time.Duration
+	// FallbackInterval replaces the intervals above when webhooks push run states,
+	// runs are then only polled in case a notification is lost
+	FallbackInterval time.Duration
+	// Tick is how often the poller looks for runs to poll
+	Tick time.Duration
+	// BatchSize is the maximum number of runs fetched in a single query
+	BatchSize int
 }
 
 var DefaultWatcher = Watcher{
-	ErrInterval: DefaultErrInterval,
-	Interval:    DefaultInterval,
-	Timeout:     DefaultTimeout,
+	ErrInterval:  DefaultErrInterval,
+	Interval:     DefaultInterval,
+	Timeout:      DefaultTimeout,
+	SlowInterval: DefaultSlowInterval,
+	SlowAfter:    DefaultSlowAfter,
+	Tick:         DefaultTick,
+	BatchSize:    DefaultBatchSize,
+}
+
+// slowStates are polled at the slow interval once the run has been in them for SlowAfter
+var slowStates = map[string]struct{}{
+	"APPLYING":   {},
+	"PERFORMING": {},
+	"DESTROYING": {},
+}
+
+// interval returns how long to wait before polling again a run that has been in the given state for the given duration.
+// Runs that are about to change state, like QUEUED or PLANNING, are polled at the regular interval,
+// unless a fallback interval is set.
+func (w Watcher) interval(state string, since time.Duration) time.Duration {
+	if w.FallbackInterval > 0 {
+		return w.FallbackInterval
+	}
+	_, slow := slowStates[state]
+	if state == "UNCONFIRMED" || (slow && since >= w.SlowAfter) {
+		return max(w.SlowInterval, w.Interval)
+	}
+	return w.Interval
 }
@@ -0,0 +1,244 @@
+// Package webhook receives spacelift notification webhooks, so run states are updated as soon as they change
+// instead of waiting for the run watcher to poll them.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
+)
+
+const (
+	// SignatureHeader holds the hex encoded HMAC SHA256 of the request body, prefixed with sha256=
+	SignatureHeader = "X-Signature-256"
+	// SecretKey is the key of the webhook secret holding the value configured on the spacelift webhook
+	SecretKey = "SPACELIFT_WEBHOOK_SECRET" //nolint:gosec
+
+	signaturePrefix = "sha256="
+	maxBodySize     = 1 << 20
+)
+
+// Payload is the part of the spacelift run state change notification used by the operator
+type Payload struct {
+	State string `json:"state"`
+	Run   struct {
+		Id string `json:"id"`
+	} `json:"run"`
+	Stack struct {
+		Id string `json:"id"`
+	} `json:"stack"`
+}
+
+// Receiver is an HTTP server updating Run and Stack statuses from spacelift webhooks.
+// It serves on every replica of the manager, as it doesn't need the leader election.
+type Receiver struct {
+	addr          string
+	secretName    types.NamespacedName
+	secretRepo    *repository.SecretRepository
+	runRepo       *repository.RunRepository
+	stackRepo     *repository.StackRepository
+	namespaceRepo *repository.NamespaceRepository
+}
+
+func NewReceiver(
+	addr string,
+	secretName types.NamespacedName,
+	secretRepo *repository.SecretRepository,
+	runRepo *repository.RunRepository,
+	stackRepo *repository.StackRepository,
+	namespaceRepo *repository.NamespaceRepository,
+) *Receiver {
+	return &Receiver{
+		addr:          addr,
+		secretName:    secretName,
+		secretRepo:    secretRepo,
+		runRepo:       runRepo,
+		stackRepo:     stackRepo,
+		namespaceRepo: namespaceRepo,
+	}
+}
+
+// Start serves webhooks until the context is done, it implements manager.Runnable
+func (r *Receiver) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("webhook")
+	server := &http.Server{
+		Addr:              r.addr,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return log.IntoContext(ctx, logger) },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "Unable to shutdown webhook receiver")
+		}
+	}()
+
+	logger.Info("Starting webhook receiver", "addr", r.addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (r *Receiver) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	logger := log.FromContext(ctx)
+
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		logger.Error(err, "Unable to read webhook body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	secret, err := r.secret(ctx)
+	if err != nil {
+		logger.Error(err, "Unable to get webhook secret", logging.SecretName, r.secretName.String())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !validSignature(secret, body, req.Header.Get(SignatureHeader)) {
+		logger.Info("Rejecting webhook with an invalid signature")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		logger.Error(err, "Unable to decode webhook body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// Other notifications don't carry a run state, there is nothing to update
+	if payload.Run.Id == "" || payload.State == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	logger = logger.WithValues(logging.RunId, payload.Run.Id, logging.RunState, payload.State)
+	if err := r.update(log.IntoContext(ctx, logger), payload); err != nil {
+		logger.Error(err, "Unable to update run state from webhook")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// secret is read on each request so a rotated secret is picked up without restarting the operator
+func (r *Receiver) secret(ctx context.Context) ([]byte, error) {
+	secret, err := r.secretRepo.Get(ctx, r.secretName)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := secret.Data[SecretKey]
+	if !ok || len(value) == 0 {
+		return nil, errors.Errorf("key %s not found in secret", SecretKey)
+	}
+	return value, nil
+}
+
+func validSignature(secret, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	received, err := hex.DecodeString(strings.TrimPrefix(signature, signaturePrefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(received, mac.Sum(nil))
+}
+
+// update sets the new state on the runs and destroy tasks bound to the spacelift run.
+// Terminated runs are never updated, so a late notification can't bring them back to an active state.
+// Paused runs are not updated either, their state is polled again once they are resumed.
+func (r *Receiver) update(ctx context.Context, payload Payload) error {
+	logger := log.FromContext(ctx)
+
+	runs, err := r.runRepo.ListByRunId(ctx, payload.Run.Id)
+	if err != nil {
+		return errors.Wrap(err, "unable to list runs")
+	}
+	for _, run := range runs {
+		name := types.NamespacedName{Namespace: run.Namespace, Name: run.Name}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			run, err := r.runRepo.Get(ctx, name)
+			if err != nil {
+				return err
+			}
+			if run.IsTerminated() || run.Status.State == v1beta1.RunState(payload.State) {
+				return nil
+			}
+			paused, err := r.namespaceRepo.IsPaused(ctx, run.Namespace)
+			if err != nil {
+				return err
+			}
+			if paused || v1beta1.IsPaused(run) {
+				return nil
+			}
+			run.SetRun(&models.Run{Id: payload.Run.Id, State: payload.State, StackId: payload.Stack.Id})
+			return r.runRepo.UpdateStatus(ctx, run)
+		})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "unable to update run status")
+		}
+		logger.Info("Run state received from webhook", "run", name.String())
+	}
+
+	stacks, err := r.stackRepo.ListByDestroyRunId(ctx, payload.Run.Id)
+	if err != nil {
+		return errors.Wrap(err, "unable to list stacks")
+	}
+	for _, stack := range stacks {
+		name := types.NamespacedName{Namespace: stack.Namespace, Name: stack.ObjectMeta.Name}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			stack, err := r.stackRepo.Get(ctx, name)
+			if err != nil {
+				return err
+			}
+			if stack.IsDestroyTerminated() || stack.Status.DestroyRunState == v1beta1.RunState(payload.State) {
+				return nil
+			}
+			stack.Status.DestroyRunState = v1beta1.RunState(payload.State)
+			return r.stackRepo.UpdateStatus(ctx, stack)
+		})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "unable to update stack status")
+		}
+		logger.Info("Destroy task state received from webhook", "stack", name.String())
+	}
+
+	return nil
+}
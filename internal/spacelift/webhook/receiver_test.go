@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	"github.com/spacelift-io/spacelift-operator/internal/k8s/repository"
+	"github.com/spacelift-io/spacelift-operator/tests/integration"
+)
+
+const (
+	webhookSecret = "webhook-secret"
+	payload       = `{"state":"FINISHED","run":{"id":"run-id"},"stack":{"id":"stack-id"}}`
+)
+
+func sign(body string) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(body))
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newReceiver(t *testing.T, objects ...client.Object) (*Receiver, client.Client) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1beta1.AddToScheme(scheme))
+
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&v1beta1.Run{}, &v1beta1.Stack{}).
+		WithObjects(objects...)
+	require.NoError(t, repository.IndexStacks(context.Background(), integration.FakeIndexer{Builder: builder}))
+	k8sClient := builder.Build()
+	secretName := types.NamespacedName{Namespace: "operator", Name: "spacelift-webhook"}
+	receiver := NewReceiver(
+		":0",
+		secretName,
+		repository.NewSecretRepository(k8sClient),
+		repository.NewRunRepository(k8sClient, scheme),
+		repository.NewStackRepository(k8sClient, scheme),
+		repository.NewNamespaceRepository(k8sClient),
+	)
+	return receiver, k8sClient
+}
+
+func secret() *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "operator", Name: "spacelift-webhook"},
+		Data:       map[string][]byte{SecretKey: []byte(webhookSecret)},
+	}
+}
+
+func run(name string, state v1beta1.RunState) *v1beta1.Run {
+	return &v1beta1.Run{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Status:     v1beta1.RunStatus{Id: "run-id", StackId: "stack-id", State: state},
+	}
+}
+
+func send(receiver *Receiver, method, body, signature string) int {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(SignatureHeader, signature)
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+func TestReceiver_Signature(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		signature string
+		objects   []client.Object
+		want      int
+	}{
+		{name: "valid", method: http.MethodPost, signature: sign(payload), objects: []client.Object{secret()}, want: http.StatusNoContent},
+		{name: "invalid", method: http.MethodPost, signature: sign("other body"), objects: []client.Object{secret()}, want: http.StatusUnauthorized},
+		{name: "missing", method: http.MethodPost, objects: []client.Object{secret()}, want: http.StatusUnauthorized},
+		{name: "not hex", method: http.MethodPost, signature: signaturePrefix + "zz", objects: []client.Object{secret()}, want: http.StatusUnauthorized},
+		{name: "secret not found", method: http.MethodPost, signature: sign(payload), want: http.StatusInternalServerError},
+		{name: "wrong method", method: http.MethodGet, signature: sign(payload), objects: []client.Object{secret()}, want: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver, _ := newReceiver(t, tt.objects...)
+			assert.Equal(t, tt.want, send(receiver, tt.method, payload, tt.signature))
+		})
+	}
+}
+
+func TestReceiver_UpdatesRuns(t *testing.T) {
+	stack := &v1beta1.Stack{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "stack"},
+		Status:     v1beta1.StackStatus{Id: "stack-id", DestroyRunId: "run-id", DestroyRunState: v1beta1.RunState("PREPARING")},
+	}
+	other := run("other", v1beta1.RunStateQueued)
+	other.Status.Id = "other-run-id"
+	receiver, k8sClient := newReceiver(t,
+		secret(),
+		run("active", v1beta1.RunState("APPLYING")),
+		run("terminated", v1beta1.RunStateFailed),
+		other,
+		stack,
+	)
+
+	require.Equal(t, http.StatusNoContent, send(receiver, http.MethodPost, payload, sign(payload)))
+
+	state := func(name string) v1beta1.RunState {
+		var run v1beta1.Run
+		require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: name}, &run))
+		return run.Status.State
+	}
+	assert.Equal(t, v1beta1.RunStateFinished, state("active"))
+	assert.Equal(t, v1beta1.RunStateFailed, state("terminated"))
+	assert.Equal(t, v1beta1.RunStateQueued, state("other"))
+
+	require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "stack"}, stack))
+	assert.Equal(t, v1beta1.RunStateFinished, stack.Status.DestroyRunState)
+}
+
+func TestReceiver_IgnoresOtherNotifications(t *testing.T) {
+	receiver, _ := newReceiver(t, secret())
+	body := `{"moduleVersion":{"id":"version-id"}}`
+
+	assert.Equal(t, http.StatusNoContent, send(receiver, http.MethodPost, body, sign(body)))
+	assert.Equal(t, http.StatusBadRequest, send(receiver, http.MethodPost, "not json", sign("not json")))
+}
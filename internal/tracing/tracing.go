@@ -0,0 +1,137 @@
+// Package tracing exports OpenTelemetry traces of the reconciliations and of the requests sent to the spacelift API.
+// Tracing is disabled unless an exporter is configured, spans are then recorded by a no-op tracer.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/spacelift-io/spacelift-operator/internal/logging"
+)
+
+const (
+	ExporterNone     = ""
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterOTLPHTTP = "otlp-http"
+
+	serviceName = "spacelift-operator"
+)
+
+// Tracer records the spans of the operator, it uses the tracer provider set by Setup.
+var Tracer = otel.Tracer("github.com/spacelift-io/spacelift-operator")
+
+// Options configures the export of the traces.
+type Options struct {
+	// Exporter is one of the Exporter constants, traces are not exported with ExporterNone
+	Exporter string
+	// Endpoint is the host and port of the collector, the OTEL_EXPORTER_OTLP_* environment variables are used when empty
+	Endpoint string
+	// Insecure disables TLS when sending traces to the collector
+	Insecure bool
+	// SampleRatio is the ratio of reconciliations traced, between 0 and 1
+	SampleRatio float64
+}
+
+// Setup registers the global tracer provider exporting spans as configured.
+// The returned function flushes the remaining spans and must be called before the operator exits.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create trace exporter")
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create trace resource")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, error) {
+	switch opts.Exporter {
+	case ExporterOTLPGRPC:
+		var clientOpts []otlptracegrpc.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracegrpc.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, clientOpts...)
+	case ExporterOTLPHTTP:
+		var clientOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, clientOpts...)
+	default:
+		return nil, fmt.Errorf("unknown exporter %q, must be %s or %s", opts.Exporter, ExporterOTLPGRPC, ExporterOTLPHTTP)
+	}
+}
+
+// Start starts a span. The trace ID of root spans is added to the logger of the context,
+// so the logs of a reconciliation can be correlated with its trace.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	root := !trace.SpanContextFromContext(ctx).IsValid()
+	ctx, span := Tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+	if spanContext := span.SpanContext(); root && spanContext.IsSampled() {
+		ctx = log.IntoContext(ctx, log.FromContext(ctx).WithValues(logging.TraceId, spanContext.TraceID().String()))
+	}
+	return ctx, span
+}
+
+// End records the error returned by the operation of the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Reconciler wraps a reconciler to record a span for each reconciliation of a resource of the given kind.
+func Reconciler(kind string, reconciler reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		ctx, span := Start(ctx, "Reconcile "+kind,
+			attribute.String("k8s.resource.kind", kind),
+			semconv.K8SNamespaceName(req.Namespace),
+			attribute.String("k8s.resource.name", req.Name),
+		)
+		result, err := reconciler.Reconcile(ctx, req)
+		if result.RequeueAfter > 0 {
+			span.SetAttributes(attribute.String("reconcile.requeue_after", result.RequeueAfter.String()))
+		}
+		End(span, err)
+		return result, err
+	})
+}
@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconciler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	var logs []string
+	logger := funcr.New(func(_, args string) { logs = append(logs, args) }, funcr.Options{})
+
+	reconciler := Reconciler("Stack", reconcile.Func(func(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+		log.FromContext(ctx).Info("Reconciling")
+		// Child spans don't add the trace ID to the logger again
+		ctx, span := Start(ctx, "query stack")
+		log.FromContext(ctx).Info("Querying")
+		End(span, nil)
+		return reconcile.Result{}, errors.New("failed")
+	}))
+	ctx := log.IntoContext(context.Background(), logger)
+	_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "stack"}})
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	child, parent := spans[0], spans[1]
+	assert.Equal(t, "Reconcile Stack", parent.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID())
+	assert.Equal(t, codes.Error, parent.Status().Code)
+	assert.Contains(t, parent.Attributes(), attribute.String("k8s.resource.name", "stack"))
+	assert.Contains(t, parent.Attributes(), attribute.String("k8s.namespace.name", "default"))
+
+	traceId := parent.SpanContext().TraceID().String()
+	require.Len(t, logs, 2)
+	for _, line := range logs {
+		assert.Contains(t, line, `"trace.id"="`+traceId+`"`)
+		assert.Equal(t, 1, strings.Count(line, "trace.id"))
+	}
+}
+
+func TestStart_NotSampled(t *testing.T) {
+	var logs []string
+	logger := funcr.New(func(_, args string) { logs = append(logs, args) }, funcr.Options{})
+
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+	ctx, span := tracer.Tracer("test").Start(log.IntoContext(context.Background(), logger), "parent")
+	defer span.End()
+
+	ctx, child := Start(ctx, "child")
+	defer child.End()
+	log.FromContext(ctx).Info("Not traced")
+	require.Len(t, logs, 1)
+	assert.NotContains(t, logs[0], "trace.id")
+}
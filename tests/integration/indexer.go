@@ -0,0 +1,19 @@
+package integration
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// FakeIndexer registers field indexes on a fake client being built, so repositories listing resources through
+// field indexes can be used with it.
+type FakeIndexer struct {
+	Builder *fake.ClientBuilder
+}
+
+func (i FakeIndexer) IndexField(_ context.Context, obj client.Object, field string, extract client.IndexerFunc) error {
+	i.Builder.WithIndex(obj, field, extract)
+	return nil
+}
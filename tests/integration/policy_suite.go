@@ -1,7 +1,9 @@
 package integration
 
 import (
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
 )
@@ -37,3 +39,11 @@ func (s *WithPolicySuiteHelper) CreatePolicy(policy *v1beta1.Policy) error {
 func (s *WithPolicySuiteHelper) DeletePolicy(policy *v1beta1.Policy) error {
 	return s.Client().Delete(s.Context(), policy)
 }
+
+func (s *WithPolicySuiteHelper) WaitUntilPolicyRemoved(policy *v1beta1.Policy) bool {
+	return s.Eventually(func() bool {
+		st := &v1beta1.Policy{}
+		err := s.Client().Get(s.Context(), types.NamespacedName{Namespace: policy.Namespace, Name: policy.ObjectMeta.Name}, st)
+		return k8sErrors.IsNotFound(err)
+	}, DefaultTimeout, DefaultInterval)
+}
@@ -1,15 +1,18 @@
 package integration
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/stretchr/testify/mock"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/spacelift-io/spacelift-operator/api/v1beta1"
+	spaceliftclient "github.com/spacelift-io/spacelift-operator/internal/spacelift/client"
 	"github.com/spacelift-io/spacelift-operator/internal/spacelift/models"
 )
 
@@ -55,11 +58,43 @@ func (s *WithRunSuiteHelper) CreateRun(run *v1beta1.Run) error {
 	return nil
 }
 
+// RunsInState matches the GetStates calls for runs last seen by the watcher in the given state
+func RunsInState(state v1beta1.RunState) any {
+	return mock.MatchedBy(func(runs []models.Run) bool {
+		return len(runs) > 0 && runs[0].State == string(state)
+	})
+}
+
+// ReturnRunStates mocks GetStates by reporting every requested run in the given state
+func ReturnRunStates(state v1beta1.RunState) func(context.Context, spaceliftclient.Account, []models.Run) (map[string]*models.Run, error) {
+	return func(_ context.Context, _ spaceliftclient.Account, runs []models.Run) (map[string]*models.Run, error) {
+		result := make(map[string]*models.Run, len(runs))
+		for _, run := range runs {
+			result[run.Id] = &models.Run{Id: run.Id, StackId: run.StackId, State: string(state)}
+		}
+		return result, nil
+	}
+}
+
 func (s *WithRunSuiteHelper) CreateTestRun() (*v1beta1.Run, error) {
 	run := DefaultValidRun
 	return &run, s.CreateRun(&run)
 }
 
+func (s *WithRunSuiteHelper) DeleteRun(run *v1beta1.Run) {
+	err := s.Client().Delete(s.Context(), run)
+	s.Require().NoError(err)
+	s.WaitUntilRunRemoved(run)
+}
+
+func (s *WithRunSuiteHelper) WaitUntilRunRemoved(run *v1beta1.Run) bool {
+	return s.Eventually(func() bool {
+		r := &v1beta1.Run{}
+		err := s.Client().Get(s.Context(), types.NamespacedName{Namespace: run.Namespace, Name: run.Name}, r)
+		return k8sErrors.IsNotFound(err)
+	}, DefaultTimeout, DefaultInterval)
+}
+
 func (s *WithRunSuiteHelper) AssertRunState(run *v1beta1.Run, status v1beta1.RunState, timeParams ...time.Duration) *v1beta1.Run {
 	var refreshedRun *v1beta1.Run
 	timeout := DefaultTimeout
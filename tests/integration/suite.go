@@ -56,6 +56,8 @@ type IntegrationTestSuite struct {
 	ContextRepo *repository.ContextRepository
 	SecretRepo  *repository.SecretRepository
 	PolicyRepo  *repository.PolicyRepository
+
+	NamespaceRepo *repository.NamespaceRepository
 }
 
 func (s *IntegrationTestSuite) SetupSuite() {
@@ -112,6 +114,8 @@ func (s *IntegrationTestSuite) SetupSuite() {
 	})
 	s.Require().NoError(err)
 
+	s.Require().NoError(repository.IndexStacks(s.ctx, mgr.GetFieldIndexer()))
+
 	s.Require().NotNil(s.SetupManager, "SetupManager should be defined")
 	s.SetupManager(mgr)
 